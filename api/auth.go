@@ -1,48 +1,51 @@
 package api
 
 import (
-	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/Yu-Qi/GoAuth/domain"
 	"github.com/Yu-Qi/GoAuth/pkg/code"
 	"github.com/Yu-Qi/GoAuth/pkg/jwt"
+	"github.com/Yu-Qi/GoAuth/pkg/repository"
 	"github.com/Yu-Qi/GoAuth/pkg/service/accounts"
 	"github.com/Yu-Qi/GoAuth/pkg/service/crypto"
 	"github.com/Yu-Qi/GoAuth/pkg/service/email"
+	"github.com/Yu-Qi/GoAuth/pkg/service/password"
+	"github.com/Yu-Qi/GoAuth/pkg/service/risk"
 	"github.com/Yu-Qi/GoAuth/pkg/util"
 )
 
 type registerParams struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	// Locale is the language of the emails, the Accept-Language header is used when it is empty
+	Locale string `json:"locale"`
 }
 
 func (r *registerParams) AfterValidate() error {
-	if valid := util.ValidatePassword(r.Password); !valid {
-		return fmt.Errorf("invalid password")
-	}
-
-	return nil
+	return password.GetPolicy().Validate(r.Password)
 }
 
 // Register registers a new account
 func Register(c *gin.Context) {
 	params := registerParams{}
 	if customErr := util.ToGinContextExt(c).BindJson(&params); customErr != nil {
-		c.JSON(customErr.HttpStatus, map[string]interface{}{
-			"status":  customErr.HttpStatus,
-			"code":    customErr.Code,
-			"message": customErr.Error.Error(),
-		})
+		c.JSON(customErr.HttpStatus, passwordParamErrorResp(customErr))
 		return
 	}
 
+	locale := params.Locale
+	if locale == "" {
+		locale = c.GetHeader("Accept-Language")
+	}
 	customErr := accounts.Register(c, &accounts.RegisterParams{
 		Email:    params.Email,
 		Password: params.Password,
-	}, crypto.GetService(), email.GetService())
+		Locale:   util.ParseLocale(locale),
+	}, repository.GetAccountRepository(), crypto.GetService(), email.GetService(), password.GetService())
 	if customErr != nil {
 		c.JSON(customErr.HttpStatus, map[string]interface{}{
 			"status":  customErr.HttpStatus,
@@ -80,7 +83,7 @@ func Login(c *gin.Context) {
 	uid, customErr := accounts.Login(c, &accounts.LoginParams{
 		Email:    params.Email,
 		Password: params.Password,
-	})
+	}, repository.GetAccountRepository(), password.GetService())
 	if customErr != nil {
 		c.JSON(customErr.HttpStatus, map[string]interface{}{
 			"status":  customErr.HttpStatus,
@@ -90,8 +93,14 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	startSession(c, uid, params.Email)
+}
+
+// startSession creates a session of a logged in account, and responds its access token
+func startSession(c *gin.Context, uid, email string) {
+	jti := util.UUID()
 	strat := jwt.NewJwtService()
-	accessToken, err := strat.CreateToken(uid)
+	accessToken, err := strat.CreateToken(jwt.TokenData{UID: uid, JTI: jti})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, map[string]interface{}{
 			"status":  http.StatusInternalServerError,
@@ -101,6 +110,32 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	customErr := accounts.CreateSession(c, &accounts.CreateSessionParams{
+		UID:       uid,
+		JTI:       jti,
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}, repository.GetAccountRepository())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	if riskSvc := risk.GetService(); riskSvc != nil {
+		riskSvc.AssessAsync(&domain.LoginAttempt{
+			UID:       uid,
+			Email:     email,
+			JTI:       jti,
+			UserAgent: c.Request.UserAgent(),
+			IP:        c.ClientIP(),
+			At:        time.Now(),
+		})
+	}
+
 	resp := loginResp{
 		AccessToken: accessToken,
 	}
@@ -126,7 +161,7 @@ func VerifyEmail(c *gin.Context) {
 		return
 	}
 
-	customErr := accounts.VerifyEmail(c, params.VerificationCode, crypto.GetService())
+	customErr := accounts.VerifyEmail(c, params.VerificationCode, repository.GetAccountRepository(), crypto.GetService())
 	if customErr != nil {
 		c.JSON(customErr.HttpStatus, map[string]interface{}{
 			"status":  customErr.HttpStatus,
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/Yu-Qi/GoAuth/pkg/db"
 	"github.com/Yu-Qi/GoAuth/pkg/db/model"
+	"github.com/Yu-Qi/GoAuth/pkg/service/accounts"
 	"github.com/Yu-Qi/GoAuth/pkg/service/crypto"
 	"github.com/Yu-Qi/GoAuth/pkg/service/email"
 	"github.com/Yu-Qi/GoAuth/pkg/util"
@@ -29,7 +31,11 @@ func (suite *registerSuite) SetupSuite() {
 	}
 	// dependency injection
 	verificationCodeExpireSec := 600
-	email.InitService(email.NewPrintEmailService())
+	renderer, err := email.NewRenderer(email.RendererParams{})
+	if err != nil {
+		panic(err)
+	}
+	email.InitService(email.NewTemplateEmailService(renderer, email.NewPrintEmailService()))
 	crypto.InitService("your-strong-password", "your-salt-string", 4096, verificationCodeExpireSec)
 }
 
@@ -37,6 +43,23 @@ func TestRegister(t *testing.T) {
 	suite.Run(t, new(registerSuite))
 }
 
+func (suite *registerSuite) TestLocale() {
+	address := util.RandEmail()
+	headers := http.Header{
+		"Accept-Language": []string{"zh-tw,zh;q=0.9,en;q=0.8"},
+	}
+	httpStatus, _, err := util.PostWithHeaderForTest(suite.Url, headers, map[string]interface{}{
+		"email":    address,
+		"password": "Password123!",
+	}, Register)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+
+	account := &model.Account{}
+	assert.Nil(suite.T(), db.Get().Where("email = ?", address).First(account).Error)
+	assert.Equal(suite.T(), "zh-TW", account.Locale)
+}
+
 func (suite *registerSuite) TestNormal() {
 	body := map[string]interface{}{
 		"email":    util.RandEmail(),
@@ -148,7 +171,7 @@ func (suite *registerSuite) TestInvalidPassword() {
 		},
 		{
 			name:       "Password too long",
-			password:   "Password123!Password123!",
+			password:   strings.Repeat("Password123!", 11),
 			httpStatus: http.StatusBadRequest,
 			errCode:    1000,
 		},
@@ -173,6 +196,11 @@ func (suite *registerSuite) TestInvalidPassword() {
 	}
 	var resp struct {
 		Code int `json:"code"`
+		Data struct {
+			Reasons []struct {
+				Rule string `json:"rule"`
+			} `json:"reasons"`
+		} `json:"data"`
 	}
 	for _, tt := range tests {
 		suite.T().Run(tt.name, func(t *testing.T) {
@@ -186,10 +214,21 @@ func (suite *registerSuite) TestInvalidPassword() {
 			err = json.Unmarshal(respBody, &resp)
 			assert.Nil(suite.T(), err)
 			assert.Equal(suite.T(), tt.errCode, resp.Code)
+			assert.NotEmpty(suite.T(), resp.Data.Reasons)
 		})
 	}
 }
 
+func (suite *registerSuite) TestPassphrase() {
+	body := map[string]interface{}{
+		"email":    util.RandEmail(),
+		"password": "Correct horse battery staple, Tr0ub4dor",
+	}
+	httpStatus, _, err := suite.Request(body)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+}
+
 type loginSuite struct {
 	suite.Suite
 	Url      string
@@ -384,7 +423,7 @@ func (suite *verifyEmailSuite) SetupSuite() {
 	if err != nil {
 		panic(err)
 	}
-	db.Get().Create(&model.Account{UID: suite.UID, Email: suite.Email, HashedPassword: string(hashedPassword), IsActive: true})
+	db.Get().Create(&model.Account{UID: suite.UID, Email: suite.Email, HashedPassword: string(hashedPassword)})
 
 	// dependency injection
 	verificationCodeExpireSec := 600
@@ -475,3 +514,82 @@ func (suite *verifyEmailSuite) TestUserNotFound() {
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), 1005, resp.Code)
 }
+
+func (suite *verifyEmailSuite) TestAlreadyActive() {
+	uid := util.UUID()
+	db.Get().Create(&model.Account{UID: uid, Email: util.RandEmail(), HashedPassword: "hashed", IsActive: true})
+	verificationCode, err := crypto.GetService().GenerateCode(uid)
+	assert.Nil(suite.T(), err)
+	body := map[string]interface{}{
+		"verification_code": verificationCode,
+	}
+	httpStatus, respBody, err := suite.Request(body)
+	var resp struct {
+		Code int `json:"code"`
+	}
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, httpStatus)
+	err = json.Unmarshal(respBody, &resp)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2003, resp.Code)
+}
+
+type enumerationProtectionSuite struct {
+	suite.Suite
+}
+
+func (suite *enumerationProtectionSuite) SetupSuite() {
+	// dependency injection
+	verificationCodeExpireSec := 600
+	renderer, err := email.NewRenderer(email.RendererParams{})
+	if err != nil {
+		panic(err)
+	}
+	email.InitService(email.NewTemplateEmailService(renderer, email.NewPrintEmailService()))
+	crypto.InitService("your-strong-password", "your-salt-string", 4096, verificationCodeExpireSec)
+	accounts.Init(accounts.InitParam{EnumerationProtection: true})
+}
+
+func (suite *enumerationProtectionSuite) TearDownSuite() {
+	accounts.Init(accounts.InitParam{})
+}
+
+func TestEnumerationProtection(t *testing.T) {
+	suite.Run(t, new(enumerationProtectionSuite))
+}
+
+func (suite *enumerationProtectionSuite) TestDuplicateEmail() {
+	body := map[string]interface{}{
+		"email":    util.RandEmail(),
+		"password": "Password1!",
+	}
+	// first request
+	_, _, _ = util.PostForTest("/register", body, Register)
+	// second request with the same email looks like a new registration
+	httpStatus, respBody, err := util.PostForTest("/register", body, Register)
+	var resp struct {
+		Code int `json:"code"`
+	}
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	err = json.Unmarshal(respBody, &resp)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, resp.Code)
+}
+
+func (suite *enumerationProtectionSuite) TestUnknownEmail() {
+	body := map[string]interface{}{
+		"email":    util.RandEmail(),
+		"password": "Password1!",
+	}
+	httpStatus, respBody, err := util.PostForTest("/login", body, Login)
+	var resp struct {
+		Code int `json:"code"`
+	}
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, httpStatus)
+	err = json.Unmarshal(respBody, &resp)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 2001, resp.Code)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Yu-Qi/GoAuth/pkg/cache"
+	"github.com/Yu-Qi/GoAuth/pkg/db"
+	"github.com/Yu-Qi/GoAuth/pkg/db/migrations"
+	"github.com/Yu-Qi/GoAuth/pkg/repository"
+	"github.com/Yu-Qi/GoAuth/pkg/service/interactions"
+	"github.com/Yu-Qi/GoAuth/pkg/service/recommend"
+)
+
+func TestMain(m *testing.M) {
+	// a sqlite database starts empty, such as DB_DRIVER=sqlite SQLITE_PATH=:memory:
+	if db.Driver() == db.DriverSQLite {
+		if _, err := migrations.NewRunner(db.Get(), migrations.All()).Up(context.Background()); err != nil {
+			panic(err)
+		}
+	}
+	cfg, err := cache.ConfigFromEnv()
+	if err != nil {
+		panic(err)
+	}
+	if err := cache.Init(context.Background(), cfg); err != nil {
+		panic(err)
+	}
+	layered := cache.NewLayered(cache.Client, cache.LayeredParams{Capacity: 1000, TTL: time.Minute})
+	if err := layered.Start(context.Background()); err != nil {
+		panic(err)
+	}
+	cache.InitLayered(layered)
+	// the handlers of every suite use the database repositories
+	repository.InitAccountRepository(db.NewAccountRepository())
+	repository.InitProductRepository(db.NewProductRepository())
+	repository.InitInteractionRepository(db.NewInteractionRepository())
+	counter := interactions.NewTrendingCounter(cache.Client, 24*time.Hour)
+	interactions.InitCounter(counter)
+	buffer := interactions.NewBuffer(interactions.BufferParams{
+		Repo:          repository.GetInteractionRepository(),
+		Counter:       counter,
+		Capacity:      100,
+		BatchSize:     10,
+		FlushInterval: 10 * time.Millisecond,
+	})
+	buffer.Start()
+	interactions.InitBuffer(buffer)
+	strategies := recommend.NewStrategies(recommend.Params{
+		ProductRepo:     repository.GetProductRepository(),
+		InteractionRepo: repository.GetInteractionRepository(),
+	})
+	if err := recommend.Init(strategies, recommend.StrategyCategoryAffinity); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/Yu-Qi/GoAuth/pkg/repository"
+	"github.com/Yu-Qi/GoAuth/pkg/service/accounts"
+)
+
+// RequireAdmin is the middleware to allow only admin accounts, it follows AuthToken
+func RequireAdmin(c *gin.Context) {
+	if customErr := accounts.CheckAdmin(c, c.GetString("uid"), repository.GetAccountRepository()); customErr != nil {
+		c.AbortWithStatusJSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.Next()
+}
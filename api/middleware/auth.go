@@ -9,6 +9,8 @@ import (
 
 	"github.com/Yu-Qi/GoAuth/pkg/code"
 	jwtSvc "github.com/Yu-Qi/GoAuth/pkg/jwt"
+	"github.com/Yu-Qi/GoAuth/pkg/repository"
+	"github.com/Yu-Qi/GoAuth/pkg/service/accounts"
 )
 
 // AuthToken is the middleware to authenticate the token
@@ -38,7 +40,16 @@ func AuthToken(c *gin.Context) {
 	claims := claimsI.(*jwt.StandardClaims)
 
 	userID := claims.Subject
+	if customErr := accounts.CheckSession(c, userID, claims.Id, repository.GetAccountRepository()); customErr != nil {
+		c.AbortWithStatusJSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
 	c.Set("uid", userID)
+	c.Set("jti", claims.Id)
 	c.Next()
 	return
 }
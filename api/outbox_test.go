@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/db"
+	"github.com/Yu-Qi/GoAuth/pkg/db/model"
+	"github.com/Yu-Qi/GoAuth/pkg/service/crypto"
+	"github.com/Yu-Qi/GoAuth/pkg/service/email"
+	"github.com/Yu-Qi/GoAuth/pkg/service/outbox"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
+)
+
+// flakySendEmailService fails the emails to failTo and records the others
+type flakySendEmailService struct {
+	failTo string
+	sent   []*domain.EmailMessage
+}
+
+func (s *flakySendEmailService) SendEmail(ctx context.Context, msg *domain.EmailMessage) error {
+	if msg.To == s.failTo {
+		return errors.New("connection refused")
+	}
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+type outboxSuite struct {
+	suite.Suite
+}
+
+func (suite *outboxSuite) SetupSuite() {
+	// dependency injection
+	verificationCodeExpireSec := 600
+	email.InitService(email.NewPrintEmailService())
+	crypto.InitService("your-strong-password", "your-salt-string", 4096, verificationCodeExpireSec)
+}
+
+func TestOutbox(t *testing.T) {
+	suite.Run(t, new(outboxSuite))
+}
+
+func (suite *outboxSuite) register() (string, *model.EmailOutbox) {
+	address := util.RandEmail()
+	httpStatus, _, err := util.PostForTest("/register", map[string]interface{}{
+		"email":    address,
+		"password": "Password123!",
+	}, Register)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 200, httpStatus)
+
+	account := &model.Account{}
+	assert.Nil(suite.T(), db.Get().Where("email = ?", address).First(account).Error)
+	row := &model.EmailOutbox{}
+	assert.Nil(suite.T(), db.Get().Where("idempotency_key = ?", domain.EmailTemplateVerifyEmail+"/"+account.UID).First(row).Error)
+	return address, row
+}
+
+func (suite *outboxSuite) reload(row *model.EmailOutbox) {
+	assert.Nil(suite.T(), db.Get().First(row, row.ID).Error)
+}
+
+func (suite *outboxSuite) TestRetryAndDeadLetter() {
+	address, row := suite.register()
+	assert.Equal(suite.T(), model.EmailOutboxPending, row.Status)
+
+	sender := &flakySendEmailService{failTo: address}
+	dispatcher := outbox.NewDispatcher(outbox.DispatcherParams{
+		SendEmailSvc: sender,
+		BatchSize:    100,
+		Lease:        time.Minute,
+		MaxAttempts:  2,
+		Backoff:      outbox.Backoff{Base: time.Hour, Max: time.Hour},
+	})
+
+	_, err := dispatcher.DispatchOnce(context.Background())
+	assert.Nil(suite.T(), err)
+	suite.reload(row)
+	assert.Equal(suite.T(), model.EmailOutboxPending, row.Status)
+	assert.Equal(suite.T(), 1, row.Attempts)
+	assert.Equal(suite.T(), "connection refused", row.LastError)
+	assert.True(suite.T(), row.NextAttemptAt.After(time.Now().Add(30*time.Minute)))
+
+	// not due yet
+	_, err = dispatcher.DispatchOnce(context.Background())
+	assert.Nil(suite.T(), err)
+	suite.reload(row)
+	assert.Equal(suite.T(), 1, row.Attempts)
+
+	// the last attempt fails
+	assert.Nil(suite.T(), db.Get().Model(row).Update("next_attempt_at", time.Now().Add(-time.Second)).Error)
+	_, err = dispatcher.DispatchOnce(context.Background())
+	assert.Nil(suite.T(), err)
+	suite.reload(row)
+	assert.Equal(suite.T(), model.EmailOutboxDead, row.Status)
+	assert.Equal(suite.T(), 2, row.Attempts)
+}
+
+func (suite *outboxSuite) TestDeliver() {
+	address, row := suite.register()
+
+	sender := &flakySendEmailService{}
+	dispatcher := outbox.NewDispatcher(outbox.DispatcherParams{
+		SendEmailSvc: sender,
+		BatchSize:    100,
+		Lease:        time.Minute,
+		MaxAttempts:  2,
+		Backoff:      outbox.Backoff{Base: time.Hour, Max: time.Hour},
+	})
+	_, err := dispatcher.DispatchOnce(context.Background())
+	assert.Nil(suite.T(), err)
+
+	suite.reload(row)
+	assert.Equal(suite.T(), model.EmailOutboxSent, row.Status)
+	assert.NotNil(suite.T(), row.SentAt)
+	account := &model.Account{}
+	assert.Nil(suite.T(), db.Get().Where("uid = ?", row.AccountUID).First(account).Error)
+	assert.NotNil(suite.T(), account.SentAt)
+
+	var delivered *domain.EmailMessage
+	for _, msg := range sender.sent {
+		if msg.To == address {
+			delivered = msg
+		}
+	}
+	if assert.NotNil(suite.T(), delivered) {
+		assert.Equal(suite.T(), domain.EmailTemplateVerifyEmail, delivered.Template)
+		assert.NotEmpty(suite.T(), delivered.Data["Code"])
+	}
+
+	// queuing the same email again is ignored
+	assert.Nil(suite.T(), db.EnqueueEmail(context.Background(), delivered, row.AccountUID))
+	var count int64
+	db.Get().Model(&model.EmailOutbox{}).Where("idempotency_key = ?", delivered.ID).Count(&count)
+	assert.Equal(suite.T(), int64(1), count)
+}
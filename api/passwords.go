@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/repository"
+	"github.com/Yu-Qi/GoAuth/pkg/service/accounts"
+	"github.com/Yu-Qi/GoAuth/pkg/service/crypto"
+	"github.com/Yu-Qi/GoAuth/pkg/service/email"
+	"github.com/Yu-Qi/GoAuth/pkg/service/password"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
+)
+
+// passwordParamErrorResp is the response of a binding error, which lists every rule a new password fails
+func passwordParamErrorResp(customErr *code.CustomError) map[string]interface{} {
+	resp := map[string]interface{}{
+		"status":  customErr.HttpStatus,
+		"code":    customErr.Code,
+		"message": customErr.Error.Error(),
+	}
+	policyErr := &password.PolicyError{}
+	if errors.As(customErr.Error, &policyErr) {
+		resp["data"] = map[string]interface{}{
+			"reasons": policyErr.Violations,
+		}
+	}
+	return resp
+}
+
+type changePasswordParams struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+func (r *changePasswordParams) AfterValidate() error {
+	return password.GetPolicy().Validate(r.NewPassword)
+}
+
+// ChangePassword changes the password of the current account
+func ChangePassword(c *gin.Context) {
+	params := changePasswordParams{}
+	if customErr := util.ToGinContextExt(c).BindJson(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, passwordParamErrorResp(customErr))
+		return
+	}
+
+	customErr := accounts.ChangePassword(c, &accounts.ChangePasswordParams{
+		UID:         c.GetString("uid"),
+		CurrentJTI:  c.GetString("jti"),
+		OldPassword: params.OldPassword,
+		NewPassword: params.NewPassword,
+	}, repository.GetAccountRepository(), password.GetService())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+	})
+}
+
+type forgotPasswordParams struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ForgotPassword emails a reset password code
+func ForgotPassword(c *gin.Context) {
+	params := forgotPasswordParams{}
+	if customErr := util.ToGinContextExt(c).BindJson(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	customErr := accounts.ForgotPassword(c, params.Email, repository.GetAccountRepository(), crypto.GetService(), email.GetService())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+	})
+}
+
+type resetPasswordParams struct {
+	Code        string `json:"code" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+func (r *resetPasswordParams) AfterValidate() error {
+	return password.GetPolicy().Validate(r.NewPassword)
+}
+
+// ResetPassword sets a new password with the code from ForgotPassword
+func ResetPassword(c *gin.Context) {
+	params := resetPasswordParams{}
+	if customErr := util.ToGinContextExt(c).BindJson(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, passwordParamErrorResp(customErr))
+		return
+	}
+
+	customErr := accounts.ResetPassword(c, params.Code, params.NewPassword, repository.GetAccountRepository(), crypto.GetService(), password.GetService())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+	})
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/Yu-Qi/GoAuth/api/middleware"
+	"github.com/Yu-Qi/GoAuth/pkg/db"
+	"github.com/Yu-Qi/GoAuth/pkg/db/model"
+	"github.com/Yu-Qi/GoAuth/pkg/service/accounts"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
+)
+
+type changePasswordSuite struct {
+	suite.Suite
+	Email    string
+	Password string
+	Token    string
+}
+
+func (suite *changePasswordSuite) SetupSuite() {
+	// setup a new account in the database
+	suite.Email = util.RandEmail()
+	suite.Password = "Password1!" + util.RandString(3)
+	hashedPassword, err := util.GenerateBcryptPassword(suite.Password)
+	if err != nil {
+		panic(err)
+	}
+	db.Get().Create(&model.Account{UID: util.UUID(), Email: suite.Email, HashedPassword: string(hashedPassword), IsActive: true})
+
+	accounts.Init(accounts.InitParam{PasswordHistoryDepth: 3})
+
+	_, respBody, err := util.PostForTest("/login", map[string]interface{}{
+		"email":    suite.Email,
+		"password": suite.Password,
+	}, Login)
+	if err != nil {
+		panic(err)
+	}
+	var resp struct {
+		Data struct {
+			AccessToken string `json:"access_token"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		panic(err)
+	}
+	suite.Token = resp.Data.AccessToken
+}
+
+func (suite *changePasswordSuite) TearDownSuite() {
+	accounts.Init(accounts.InitParam{})
+}
+
+func TestChangePassword(t *testing.T) {
+	suite.Run(t, new(changePasswordSuite))
+}
+
+func (suite *changePasswordSuite) changePassword(oldPassword, newPassword string) (int, int) {
+	headers := http.Header{
+		"Authorization": []string{"Bearer " + suite.Token},
+	}
+	httpStatus, respBody, err := util.PostWithHeaderForTest("/account/password", headers, map[string]interface{}{
+		"old_password": oldPassword,
+		"new_password": newPassword,
+	}, middleware.AuthToken, ChangePassword)
+	assert.Nil(suite.T(), err)
+	var resp struct {
+		Code int `json:"code"`
+	}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &resp))
+	return httpStatus, resp.Code
+}
+
+func (suite *changePasswordSuite) TestReuse() {
+	first := suite.Password
+	second := "Password2!" + util.RandString(3)
+
+	httpStatus, errCode := suite.changePassword(first, second)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	assert.Equal(suite.T(), 0, errCode)
+
+	// cycling back to the previous password is rejected
+	httpStatus, errCode = suite.changePassword(second, first)
+	assert.Equal(suite.T(), http.StatusBadRequest, httpStatus)
+	assert.Equal(suite.T(), 2007, errCode)
+
+	// the current password too
+	httpStatus, errCode = suite.changePassword(second, second)
+	assert.Equal(suite.T(), http.StatusBadRequest, httpStatus)
+	assert.Equal(suite.T(), 2007, errCode)
+
+	suite.Password = second
+}
+
+func (suite *changePasswordSuite) TestWrongOldPassword() {
+	httpStatus, errCode := suite.changePassword("Wrong1!"+util.RandString(3), "Password3!"+util.RandString(3))
+	assert.Equal(suite.T(), http.StatusBadRequest, httpStatus)
+	assert.Equal(suite.T(), 2001, errCode)
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Yu-Qi/GoAuth/pkg/repository"
+	"github.com/Yu-Qi/GoAuth/pkg/service/accounts"
+	"github.com/Yu-Qi/GoAuth/pkg/service/otp"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
+)
+
+type phoneParams struct {
+	Phone string `json:"phone" binding:"required,e164"`
+}
+
+type phoneCodeParams struct {
+	Phone string `json:"phone" binding:"required,e164"`
+	Code  string `json:"code" binding:"required"`
+}
+
+// StartPhoneVerification sends a verification code to the phone number of the current account
+func StartPhoneVerification(c *gin.Context) {
+	params := phoneParams{}
+	if customErr := util.ToGinContextExt(c).BindJson(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	customErr := accounts.StartPhoneVerification(c, c.GetString("uid"), params.Phone, repository.GetAccountRepository(), otp.GetService())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+	})
+}
+
+// VerifyPhone sets the phone number of the current account with the verification code
+func VerifyPhone(c *gin.Context) {
+	params := phoneCodeParams{}
+	if customErr := util.ToGinContextExt(c).BindJson(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	customErr := accounts.VerifyPhone(c, c.GetString("uid"), params.Phone, params.Code, repository.GetAccountRepository(), otp.GetService())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+	})
+}
+
+// SendLoginCode sends a login code to a verified phone number
+func SendLoginCode(c *gin.Context) {
+	params := phoneParams{}
+	if customErr := util.ToGinContextExt(c).BindJson(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	customErr := accounts.SendLoginCode(c, params.Phone, repository.GetAccountRepository(), otp.GetService())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+	})
+}
+
+// LoginWithPhone logs in with a phone number and the login code sent to it, and returns an access token
+func LoginWithPhone(c *gin.Context) {
+	params := phoneCodeParams{}
+	if customErr := util.ToGinContextExt(c).BindJson(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	account, customErr := accounts.LoginWithCode(c, params.Phone, params.Code, repository.GetAccountRepository(), otp.GetService())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	startSession(c, account.UID, account.Email)
+}
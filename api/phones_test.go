@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/Yu-Qi/GoAuth/api/middleware"
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/cache"
+	"github.com/Yu-Qi/GoAuth/pkg/db"
+	"github.com/Yu-Qi/GoAuth/pkg/db/model"
+	"github.com/Yu-Qi/GoAuth/pkg/service/otp"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
+)
+
+var smsCodePattern = regexp.MustCompile(`\d{6}`)
+
+// captureSMSService records the sent messages
+type captureSMSService struct {
+	mu   sync.Mutex
+	sent []*domain.SMSMessage
+}
+
+func (s *captureSMSService) SendSMS(msg *domain.SMSMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+// count returns the number of the messages sent to phone
+func (s *captureSMSService) count(phone string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, msg := range s.sent {
+		if msg.To == phone {
+			n++
+		}
+	}
+	return n
+}
+
+// lastCode returns the code in the last message sent to phone
+func (s *captureSMSService) lastCode(phone string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.sent) - 1; i >= 0; i-- {
+		if s.sent[i].To == phone {
+			return smsCodePattern.FindString(s.sent[i].Text)
+		}
+	}
+	return ""
+}
+
+type phoneSuite struct {
+	suite.Suite
+	Token string
+	SMS   *captureSMSService
+}
+
+func (suite *phoneSuite) SetupSuite() {
+	// setup a new account in the database
+	address := util.RandEmail()
+	password := "Password1!" + util.RandString(3)
+	hashedPassword, err := util.GenerateBcryptPassword(password)
+	if err != nil {
+		panic(err)
+	}
+	db.Get().Create(&model.Account{UID: util.UUID(), Email: address, HashedPassword: string(hashedPassword), IsActive: true})
+
+	suite.SMS = &captureSMSService{}
+	otp.InitService(otp.NewService(otp.Params{
+		Client:       cache.Client,
+		SMSSvc:       suite.SMS,
+		CodeLength:   6,
+		TTL:          time.Minute,
+		MaxAttempts:  3,
+		SendInterval: time.Second,
+		SendLimit:    5,
+		SendWindow:   time.Hour,
+	}))
+
+	_, respBody, err := util.PostForTest("/login", map[string]interface{}{
+		"email":    address,
+		"password": password,
+	}, Login)
+	if err != nil {
+		panic(err)
+	}
+	var resp struct {
+		Data struct {
+			AccessToken string `json:"access_token"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		panic(err)
+	}
+	suite.Token = resp.Data.AccessToken
+}
+
+func TestPhone(t *testing.T) {
+	suite.Run(t, new(phoneSuite))
+}
+
+// randPhone returns a random E.164 phone number, so that the rate limits of earlier runs do not apply
+func randPhone() string {
+	return fmt.Sprintf("+8869%08d", time.Now().UnixNano()%100000000)
+}
+
+func (suite *phoneSuite) post(url string, body map[string]interface{}, handleFuncs ...gin.HandlerFunc) (int, int, []byte) {
+	headers := http.Header{
+		"Authorization": []string{"Bearer " + suite.Token},
+	}
+	httpStatus, respBody, err := util.PostWithHeaderForTest(url, headers, body, handleFuncs...)
+	assert.Nil(suite.T(), err)
+	var resp struct {
+		Code int `json:"code"`
+	}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &resp))
+	return httpStatus, resp.Code, respBody
+}
+
+func (suite *phoneSuite) TestVerifyAndLogin() {
+	phone := randPhone()
+
+	httpStatus, errCode, _ := suite.post("/account/phone", map[string]interface{}{"phone": phone}, middleware.AuthToken, StartPhoneVerification)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	assert.Equal(suite.T(), 0, errCode)
+	verifyCode := suite.SMS.lastCode(phone)
+	assert.Len(suite.T(), verifyCode, 6)
+
+	// a wrong code is rejected
+	wrongCode := "000000"
+	if verifyCode == wrongCode {
+		wrongCode = "111111"
+	}
+	httpStatus, errCode, _ = suite.post("/account/phone/verify", map[string]interface{}{"phone": phone, "code": wrongCode}, middleware.AuthToken, VerifyPhone)
+	assert.Equal(suite.T(), http.StatusBadRequest, httpStatus)
+	assert.Equal(suite.T(), 2008, errCode)
+
+	httpStatus, errCode, _ = suite.post("/account/phone/verify", map[string]interface{}{"phone": phone, "code": verifyCode}, middleware.AuthToken, VerifyPhone)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	assert.Equal(suite.T(), 0, errCode)
+
+	// the code can be used only once
+	httpStatus, errCode, _ = suite.post("/account/phone/verify", map[string]interface{}{"phone": phone, "code": verifyCode}, middleware.AuthToken, VerifyPhone)
+	assert.Equal(suite.T(), http.StatusBadRequest, httpStatus)
+	assert.Equal(suite.T(), 2008, errCode)
+
+	// login with a code sent to the verified phone, after the send interval
+	time.Sleep(1100 * time.Millisecond)
+	httpStatus, _, err := util.PostForTest("/login/phone/code", map[string]interface{}{"phone": phone}, SendLoginCode)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	// the code is sent in the background
+	assert.Eventually(suite.T(), func() bool {
+		return suite.SMS.count(phone) == 2
+	}, time.Second, 10*time.Millisecond)
+	loginCode := suite.SMS.lastCode(phone)
+	assert.Len(suite.T(), loginCode, 6)
+
+	httpStatus, respBody, err := util.PostForTest("/login/phone", map[string]interface{}{"phone": phone, "code": loginCode}, LoginWithPhone)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	var resp struct {
+		Data struct {
+			AccessToken string `json:"access_token"`
+		} `json:"data"`
+	}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &resp))
+	assert.NotEmpty(suite.T(), resp.Data.AccessToken)
+}
+
+func (suite *phoneSuite) TestUnknownPhone() {
+	phone := randPhone()
+
+	// the response does not tell whether the phone belongs to an account
+	httpStatus, _, err := util.PostForTest("/login/phone/code", map[string]interface{}{"phone": phone}, SendLoginCode)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	assert.Empty(suite.T(), suite.SMS.lastCode(phone))
+
+	httpStatus, _, err = util.PostForTest("/login/phone", map[string]interface{}{"phone": phone, "code": "123456"}, LoginWithPhone)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, httpStatus)
+
+	// nor does the rate limit
+	httpStatus, _, err = util.PostForTest("/login/phone/code", map[string]interface{}{"phone": phone}, SendLoginCode)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusTooManyRequests, httpStatus)
+}
+
+func (suite *phoneSuite) TestRateLimit() {
+	phone := randPhone()
+
+	httpStatus, errCode, _ := suite.post("/account/phone", map[string]interface{}{"phone": phone}, middleware.AuthToken, StartPhoneVerification)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	assert.Equal(suite.T(), 0, errCode)
+
+	// another code within the send interval
+	httpStatus, errCode, _ = suite.post("/account/phone", map[string]interface{}{"phone": phone}, middleware.AuthToken, StartPhoneVerification)
+	assert.Equal(suite.T(), http.StatusTooManyRequests, httpStatus)
+	assert.Equal(suite.T(), 1012, errCode)
+}
+
+func (suite *phoneSuite) TestInvalidPhone() {
+	for _, phone := range []string{"0912345678", "+0912345678", "+886 912 345 678", "phone"} {
+		httpStatus, _, _ := suite.post("/account/phone", map[string]interface{}{"phone": phone}, middleware.AuthToken, StartPhoneVerification)
+		assert.Equal(suite.T(), http.StatusBadRequest, httpStatus, phone)
+	}
+}
@@ -1,15 +1,473 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
-	"github.com/Yu-Qi/GoAuth/pkg/service/products"
 	"github.com/gin-gonic/gin"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/repository"
+	"github.com/Yu-Qi/GoAuth/pkg/service/interactions"
+	"github.com/Yu-Qi/GoAuth/pkg/service/products"
+	"github.com/Yu-Qi/GoAuth/pkg/service/recommend"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
 )
 
-// GetRecommendations returns a list of recommended products
+type recommendationParams struct {
+	// Strategy is the name of the recommendation strategy, the configured one if empty
+	Strategy string `form:"strategy"`
+	// Cursor is the next_cursor of the previous page
+	Cursor   string `form:"cursor"`
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Category string `form:"category"`
+	MinPrice *int64 `form:"min_price" binding:"omitempty,min=0"`
+	MaxPrice *int64 `form:"max_price" binding:"omitempty,min=0"`
+	// Fields are the comma separated fields of the products in the response, every field if empty
+	Fields string `form:"fields"`
+}
+
+// AfterValidate checks the price range
+func (p *recommendationParams) AfterValidate() error {
+	if p.MinPrice != nil && p.MaxPrice != nil && *p.MinPrice > *p.MaxPrice {
+		return fmt.Errorf("min_price is greater than max_price")
+	}
+	return nil
+}
+
+// GetRecommendations returns a page of the products recommended to the current user. The response has a strong ETag,
+// and the request with a matching If-None-Match gets 304 without the body.
 func GetRecommendations(c *gin.Context) {
-	products, customErr := products.GetRecommendations(c, c.GetString("uid"))
+	params := recommendationParams{}
+	if customErr := util.ToGinContextExt(c).BindQuery(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	if params.Limit == 0 {
+		params.Limit = products.RecommendationLimit
+	}
+	fields, customErr := recommend.ParseFields(params.Fields)
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	uid := c.GetString("uid")
+	recommender, variant, ok := recommend.Choose(uid, params.Strategy)
+	if !ok {
+		c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status":  http.StatusBadRequest,
+			"code":    code.ParamIncorrect,
+			"message": fmt.Sprintf("unknown strategy %s", params.Strategy),
+		})
+		return
+	}
+
+	recommendations, customErr := products.GetRecommendations(c, uid, repository.GetAccountRepository(), recommender)
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	experiment := recommend.GetExperiment()
+	if variant != "" {
+		products.RecordExposure(c, experiment, variant, uid, recommend.GetExposureCounter())
+	} else if experiment != nil {
+		// the user named a strategy, the later interactions are not of the variant
+		products.RemoveExposure(c, experiment, uid, recommend.GetExposureCounter())
+	}
+
+	page, nextCursor, customErr := recommend.Page(recommendations.Products, &recommend.PageParams{
+		Cursor:   params.Cursor,
+		Limit:    params.Limit,
+		Category: params.Category,
+		MinPrice: params.MinPrice,
+		MaxPrice: params.MaxPrice,
+	})
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	data, err := recommend.SelectFields(page, fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status":  http.StatusInternalServerError,
+			"code":    code.JsonMarshalError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// the body only depends on the cached payload, the query and the variant
+	etagParts := []string{recommendations.Version, c.Request.URL.Query().Encode()}
+	if variant != "" {
+		etagParts = append(etagParts, experiment.Name, variant)
+	}
+	etag := util.StrongETag(etagParts...)
+	c.Header("ETag", etag)
+	// the recommendations are of the user, the client revalidates them before reusing
+	c.Header("Cache-Control", "private, no-cache")
+	if util.ETagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"code": 0,
+		"data": data,
+	}
+	if nextCursor != "" {
+		resp["next_cursor"] = nextCursor
+	}
+	// a user in an experiment is told the variant, so that the client can attribute its analytics
+	if variant != "" {
+		resp["experiment"] = experiment.Name
+		resp["variant"] = variant
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+type productIDParams struct {
+	ID int `uri:"id" binding:"required,min=1"`
+}
+
+type createProductParams struct {
+	Name        string `json:"name" binding:"required,max=256"`
+	Description string `json:"description" binding:"max=4096"`
+	Price       int64  `json:"price" binding:"min=0"`
+	Category    string `json:"category" binding:"max=64"`
+	Stock       int    `json:"stock" binding:"min=0"`
+	Status      string `json:"status" binding:"omitempty,oneof=active archived"`
+}
+
+type updateProductParams struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=256"`
+	Description *string `json:"description" binding:"omitempty,max=4096"`
+	Price       *int64  `json:"price" binding:"omitempty,min=0"`
+	Category    *string `json:"category" binding:"omitempty,max=64"`
+	Stock       *int    `json:"stock" binding:"omitempty,min=0"`
+	Status      *string `json:"status" binding:"omitempty,oneof=active archived"`
+}
+
+type listProductsParams struct {
+	Page     int    `form:"page" binding:"min=0"`
+	PageSize int    `form:"page_size" binding:"min=0"`
+	Status   string `form:"status" binding:"omitempty,oneof=active archived"`
+	Category string `form:"category"`
+}
+
+type listProductsResp struct {
+	Products []domain.Product `json:"products"`
+	*products.Paging
+}
+
+// CreateProduct creates a product, it is for admins
+func CreateProduct(c *gin.Context) {
+	params := createProductParams{}
+	if customErr := util.ToGinContextExt(c).BindJson(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	product, customErr := products.CreateProduct(c, &domain.CreateProductParams{
+		Name:        params.Name,
+		Description: params.Description,
+		Price:       params.Price,
+		Category:    params.Category,
+		Stock:       params.Stock,
+		Status:      params.Status,
+	}, repository.GetProductRepository())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+		"data": product,
+	})
+}
+
+// GetProduct returns a product, it is for admins
+func GetProduct(c *gin.Context) {
+	uriParams := productIDParams{}
+	if customErr := util.ToGinContextExt(c).BindUri(&uriParams); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	product, customErr := products.GetProduct(c, uriParams.ID, repository.GetProductRepository())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+		"data": product,
+	})
+}
+
+// UpdateProduct updates the given fields of a product, it is for admins
+func UpdateProduct(c *gin.Context) {
+	uriParams := productIDParams{}
+	if customErr := util.ToGinContextExt(c).BindUri(&uriParams); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	params := updateProductParams{}
+	if customErr := util.ToGinContextExt(c).BindJson(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	product, customErr := products.UpdateProduct(c, uriParams.ID, &domain.UpdateProductParams{
+		Name:        params.Name,
+		Description: params.Description,
+		Price:       params.Price,
+		Category:    params.Category,
+		Stock:       params.Stock,
+		Status:      params.Status,
+	}, repository.GetProductRepository())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+		"data": product,
+	})
+}
+
+// ArchiveProduct archives a product so that it is no longer recommended, it is for admins
+func ArchiveProduct(c *gin.Context) {
+	uriParams := productIDParams{}
+	if customErr := util.ToGinContextExt(c).BindUri(&uriParams); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	product, customErr := products.ArchiveProduct(c, uriParams.ID, repository.GetProductRepository())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+		"data": product,
+	})
+}
+
+// ListProducts returns a page of the products, the latest first, it is for admins
+func ListProducts(c *gin.Context) {
+	params := listProductsParams{}
+	if customErr := util.ToGinContextExt(c).BindQuery(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	list, paging, customErr := products.ListProducts(c, &products.ListProductsParams{
+		Page:     params.Page,
+		PageSize: params.PageSize,
+		Status:   params.Status,
+		Category: params.Category,
+	}, repository.GetProductRepository())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+		"data": listProductsResp{
+			Products: list,
+			Paging:   paging,
+		},
+	})
+}
+
+type recordInteractionParams struct {
+	Type string `json:"type" binding:"required,oneof=view click add_to_cart"`
+}
+
+// RecordInteraction records a view, click or add to cart of a product by the current user
+func RecordInteraction(c *gin.Context) {
+	uriParams := productIDParams{}
+	if customErr := util.ToGinContextExt(c).BindUri(&uriParams); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	params := recordInteractionParams{}
+	if customErr := util.ToGinContextExt(c).BindJson(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	customErr := products.RecordInteraction(c, c.GetString("uid"), uriParams.ID, params.Type, recommend.GetExperiment(), recommend.GetExposureCounter(), interactions.GetBuffer(), interactions.GetLimiter(), repository.GetProductRepository())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+	})
+}
+
+type trendingParams struct {
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"`
+	// Hours is the window of the interactions
+	Hours int `form:"hours" binding:"omitempty,min=1"`
+}
+
+// GetTrending returns the products with the most interactions recently
+func GetTrending(c *gin.Context) {
+	params := trendingParams{}
+	if customErr := util.ToGinContextExt(c).BindQuery(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	if params.Limit == 0 {
+		params.Limit = products.RecommendationLimit
+	}
+	if params.Hours == 0 {
+		params.Hours = 24
+	}
+
+	trending, customErr := products.GetTrending(c, time.Duration(params.Hours)*time.Hour, params.Limit, interactions.GetCounter(), repository.GetProductRepository())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+		"data": trending,
+	})
+}
+
+// GetExperimentReport returns the exposures and the outcomes of each variant of the running recommendation experiment
+func GetExperimentReport(c *gin.Context) {
+	report, customErr := products.GetExperimentReport(c, recommend.GetExperiment(), recommend.GetExposureCounter(), repository.GetInteractionRepository())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+		"data": report,
+	})
+}
+
+type purchaseProductParams struct {
+	// Quantity is 1 if empty
+	Quantity int `json:"quantity" binding:"omitempty,min=1,max=100"`
+}
+
+// PurchaseProduct takes a product from the stock for the current user and records the purchase
+func PurchaseProduct(c *gin.Context) {
+	uriParams := productIDParams{}
+	if customErr := util.ToGinContextExt(c).BindUri(&uriParams); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	params := purchaseProductParams{}
+	if customErr := util.ToGinContextExt(c).BindJson(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	if params.Quantity == 0 {
+		params.Quantity = 1
+	}
+
+	product, customErr := products.PurchaseProduct(c, c.GetString("uid"), uriParams.ID, params.Quantity, recommend.GetExperiment(), recommend.GetExposureCounter(), interactions.GetBuffer(), repository.GetProductRepository())
 	if customErr != nil {
 		c.JSON(customErr.HttpStatus, map[string]interface{}{
 			"status":  customErr.HttpStatus,
@@ -20,6 +478,6 @@ func GetRecommendations(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{
 		"code": 0,
-		"data": products,
+		"data": product,
 	})
 }
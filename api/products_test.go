@@ -1,18 +1,25 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/Yu-Qi/GoAuth/api/middleware"
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/cache"
 	"github.com/Yu-Qi/GoAuth/pkg/db"
 	"github.com/Yu-Qi/GoAuth/pkg/db/model"
 	jwtSvc "github.com/Yu-Qi/GoAuth/pkg/jwt"
+	"github.com/Yu-Qi/GoAuth/pkg/service/products"
+	"github.com/Yu-Qi/GoAuth/pkg/service/recommend"
 	"github.com/Yu-Qi/GoAuth/pkg/util"
 )
 
@@ -34,28 +41,42 @@ func TestGetRecommendations(t *testing.T) {
 	suite.Run(t, new(getRecommendationsSuite))
 }
 
-func (suite *getRecommendationsSuite) TestNormal() {
-	// setup a new account in the database
+// tokenForTest creates an active account and returns the headers with a token of it and its uid
+func tokenForTest(t *testing.T, isAdmin bool) (http.Header, string) {
 	uid := util.UUID()
-	email := util.RandEmail()
-	password := "Password1!" + util.RandString(3)
-	hashedPassword, err := util.GenerateBcryptPassword(password)
-	assert.Nil(suite.T(), err)
-	db.Get().Create(&model.Account{UID: uid, Email: email, HashedPassword: string(hashedPassword), IsActive: true})
+	hashedPassword, err := util.GenerateBcryptPassword("Password1!" + util.RandString(3))
+	assert.Nil(t, err)
+	db.Get().Create(&model.Account{UID: uid, Email: util.RandEmail(), HashedPassword: string(hashedPassword), IsActive: true, IsAdmin: isAdmin})
 
-	// generate a token
+	// generate a token and record its session
+	jti := util.UUID()
 	strat := jwtSvc.NewJwtService()
-	token, err := strat.CreateToken(uid)
-	assert.Nil(suite.T(), err)
-	headers := http.Header{
+	token, err := strat.CreateToken(jwtSvc.TokenData{UID: uid, JTI: jti})
+	assert.Nil(t, err)
+	customErr := db.CreateSession(context.Background(), &domain.CreateSessionParams{JTI: jti, UID: uid})
+	assert.Nil(t, customErr)
+	return http.Header{
 		"Authorization": []string{"Bearer " + token},
-	}
+	}, uid
+}
+
+func (suite *getRecommendationsSuite) TestNormal() {
+	headers, uid := tokenForTest(suite.T(), false)
+	product := &model.Product{Name: "product " + util.RandString(6), Price: 1200, Category: "test-" + util.RandString(8), Stock: 1, Status: domain.ProductStatusActive}
+	assert.Nil(suite.T(), db.Get().Create(product).Error)
+	// the only product of the category the user viewed comes first
+	customErr := db.NewInteractionRepository().CreateInteractions(context.Background(), []domain.Interaction{
+		{UID: uid, ProductID: product.ID, Type: domain.InteractionView, CreatedAt: time.Now()},
+	})
+	assert.Nil(suite.T(), customErr)
+
 	httpStatus, respBody, err := suite.Request(headers)
 	var resp struct {
 		Code int `json:"code"`
 		Data []struct {
 			ProductID int    `json:"product_id"`
 			Name      string `json:"name"`
+			Price     int64  `json:"price"`
 		} `json:"data"`
 	}
 	assert.Nil(suite.T(), err)
@@ -63,9 +84,130 @@ func (suite *getRecommendationsSuite) TestNormal() {
 	err = json.Unmarshal(respBody, &resp)
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), 0, resp.Code)
-	assert.Equal(suite.T(), 1, len(resp.Data))
-	assert.Equal(suite.T(), 1, resp.Data[0].ProductID)
-	assert.Equal(suite.T(), "product1", resp.Data[0].Name)
+	assert.NotEmpty(suite.T(), resp.Data)
+	assert.Equal(suite.T(), product.ID, resp.Data[0].ProductID)
+	assert.Equal(suite.T(), product.Name, resp.Data[0].Name)
+	assert.Equal(suite.T(), int64(1200), resp.Data[0].Price)
+}
+
+func (suite *getRecommendationsSuite) TestStrategy() {
+	headers, uid := tokenForTest(suite.T(), false)
+	product := &model.Product{Name: "product " + util.RandString(6), Category: "test-" + util.RandString(8), Stock: 1, Status: domain.ProductStatusActive}
+	assert.Nil(suite.T(), db.Get().Create(product).Error)
+	customErr := db.NewInteractionRepository().CreateInteractions(context.Background(), []domain.Interaction{
+		{UID: uid, ProductID: product.ID, Type: domain.InteractionView, CreatedAt: time.Now()},
+	})
+	assert.Nil(suite.T(), customErr)
+
+	// the viewed product is recommended again
+	httpStatus, respBody, err := util.RequestWithHeaderForTest(http.MethodGet, suite.Url, suite.Url+"?strategy=recently_viewed", headers, middleware.AuthToken, GetRecommendations)
+	var resp struct {
+		Code int `json:"code"`
+		Data []struct {
+			ProductID int `json:"product_id"`
+		} `json:"data"`
+	}
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &resp))
+	assert.NotEmpty(suite.T(), resp.Data)
+	assert.Equal(suite.T(), product.ID, resp.Data[0].ProductID)
+
+	httpStatus, respBody, err = util.RequestWithHeaderForTest(http.MethodGet, suite.Url, suite.Url+"?strategy=unknown", headers, middleware.AuthToken, GetRecommendations)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusBadRequest, httpStatus)
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &resp))
+	assert.Equal(suite.T(), 1000, resp.Code)
+}
+
+func (suite *getRecommendationsSuite) TestPageAndETag() {
+	headers, uid := tokenForTest(suite.T(), false)
+	category := "test-" + util.RandString(8)
+	interactions := []domain.Interaction{}
+	for i := 0; i < 3; i++ {
+		product := &model.Product{Name: "product " + util.RandString(6), Price: int64(1000 * (i + 1)), Category: category, Stock: 1, Status: domain.ProductStatusActive}
+		assert.Nil(suite.T(), db.Get().Create(product).Error)
+		interactions = append(interactions, domain.Interaction{UID: uid, ProductID: product.ID, Type: domain.InteractionView, CreatedAt: time.Now()})
+	}
+	assert.Nil(suite.T(), db.NewInteractionRepository().CreateInteractions(context.Background(), interactions))
+
+	type pageResp struct {
+		Data       []map[string]interface{} `json:"data"`
+		NextCursor string                   `json:"next_cursor"`
+	}
+	url := suite.Url + "?category=" + category + "&max_price=3000&limit=2&fields=product_id,price"
+	w, err := util.RecordWithHeaderForTest(http.MethodGet, suite.Url, url, headers.Clone(), middleware.AuthToken, GetRecommendations)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	first := pageResp{}
+	assert.Nil(suite.T(), json.Unmarshal(w.Body.Bytes(), &first))
+	assert.Len(suite.T(), first.Data, 2)
+	assert.Len(suite.T(), first.Data[0], 2)
+	assert.Contains(suite.T(), first.Data[0], "price")
+	assert.NotContains(suite.T(), first.Data[0], "name")
+	assert.NotEmpty(suite.T(), first.NextCursor)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(suite.T(), etag)
+
+	// the same page is not sent again
+	conditional := headers.Clone()
+	conditional.Set("If-None-Match", etag)
+	w, err = util.RecordWithHeaderForTest(http.MethodGet, suite.Url, url, conditional, middleware.AuthToken, GetRecommendations)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotModified, w.Code)
+	assert.Empty(suite.T(), w.Body.Bytes())
+	assert.Equal(suite.T(), etag, w.Header().Get("ETag"))
+
+	// the next page is another representation
+	w, err = util.RecordWithHeaderForTest(http.MethodGet, suite.Url, url+"&cursor="+first.NextCursor, conditional, middleware.AuthToken, GetRecommendations)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.NotEqual(suite.T(), etag, w.Header().Get("ETag"))
+	second := pageResp{}
+	assert.Nil(suite.T(), json.Unmarshal(w.Body.Bytes(), &second))
+	assert.Len(suite.T(), second.Data, 1)
+	assert.Empty(suite.T(), second.NextCursor)
+
+	for _, query := range []string{"?fields=secret", "?cursor=%21", "?min_price=10&max_price=1", "?limit=1000"} {
+		httpStatus, _, err := util.RequestWithHeaderForTest(http.MethodGet, suite.Url, suite.Url+query, headers.Clone(), middleware.AuthToken, GetRecommendations)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusBadRequest, httpStatus, query)
+	}
+}
+
+func (suite *getRecommendationsSuite) TestInvalidation() {
+	headers, uid := tokenForTest(suite.T(), false)
+	product := &model.Product{Name: "product " + util.RandString(6), Category: "test-" + util.RandString(8), Stock: 1, Status: domain.ProductStatusActive}
+	assert.Nil(suite.T(), db.Get().Create(product).Error)
+	customErr := db.NewInteractionRepository().CreateInteractions(context.Background(), []domain.Interaction{
+		{UID: uid, ProductID: product.ID, Type: domain.InteractionView, CreatedAt: time.Now()},
+	})
+	assert.Nil(suite.T(), customErr)
+	recommended := func() []int {
+		httpStatus, respBody, err := suite.Request(headers.Clone())
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusOK, httpStatus)
+		var resp struct {
+			Data []struct {
+				ProductID int `json:"product_id"`
+			} `json:"data"`
+		}
+		assert.Nil(suite.T(), json.Unmarshal(respBody, &resp))
+		ids := []int{}
+		for _, p := range resp.Data {
+			ids = append(ids, p.ProductID)
+		}
+		return ids
+	}
+
+	// the second request is served by the local tier
+	assert.Contains(suite.T(), recommended(), product.ID)
+	assert.Contains(suite.T(), recommended(), product.ID)
+
+	// archiving drops the recommendations from both tiers
+	_, customErr = products.ArchiveProduct(context.Background(), product.ID, db.NewProductRepository())
+	assert.Nil(suite.T(), customErr)
+	assert.NotContains(suite.T(), recommended(), product.ID)
 }
 
 func (suite *getRecommendationsSuite) TestInvalidToken() {
@@ -103,3 +245,254 @@ func (suite *getRecommendationsSuite) TestNoToken() {
 	assert.Nil(suite.T(), err)
 	assert.Equal(suite.T(), 1003, resp.Code)
 }
+
+type adminProductsSuite struct {
+	suite.Suite
+	Admin http.Header
+	User  http.Header
+}
+
+func (suite *adminProductsSuite) SetupSuite() {
+	suite.Admin, _ = tokenForTest(suite.T(), true)
+	suite.User, _ = tokenForTest(suite.T(), false)
+}
+
+func TestAdminProducts(t *testing.T) {
+	suite.Run(t, new(adminProductsSuite))
+}
+
+type productResp struct {
+	Code int `json:"code"`
+	Data struct {
+		ProductID int    `json:"product_id"`
+		Name      string `json:"name"`
+		Price     int64  `json:"price"`
+		Category  string `json:"category"`
+		Stock     int    `json:"stock"`
+		Status    string `json:"status"`
+	} `json:"data"`
+}
+
+func (suite *adminProductsSuite) request(method, route, url string, headers http.Header, body map[string]interface{}, handleFunc gin.HandlerFunc) (int, []byte) {
+	httpStatus, respBody, err := util.RequestWithBodyForTest(method, route, url, headers.Clone(), body, middleware.AuthToken, middleware.RequireAdmin, handleFunc)
+	assert.Nil(suite.T(), err)
+	return httpStatus, respBody
+}
+
+func (suite *adminProductsSuite) TestCRUD() {
+	category := "test-" + util.RandString(8)
+
+	httpStatus, respBody := suite.request(http.MethodPost, "/admin/products", "/admin/products", suite.Admin, map[string]interface{}{
+		"name":     "keyboard",
+		"price":    4990,
+		"category": category,
+		"stock":    10,
+	}, CreateProduct)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	created := productResp{}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &created))
+	assert.Equal(suite.T(), "active", created.Data.Status)
+	id := created.Data.ProductID
+	url := fmt.Sprintf("/admin/products/%d", id)
+
+	httpStatus, respBody = suite.request(http.MethodPatch, "/admin/products/:id", url, suite.Admin, map[string]interface{}{
+		"stock": 0,
+	}, UpdateProduct)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	updated := productResp{}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &updated))
+	assert.Equal(suite.T(), 0, updated.Data.Stock)
+	assert.Equal(suite.T(), "keyboard", updated.Data.Name)
+
+	httpStatus, respBody = suite.request(http.MethodPost, "/admin/products/:id/archive", url+"/archive", suite.Admin, nil, ArchiveProduct)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	archived := productResp{}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &archived))
+	assert.Equal(suite.T(), "archived", archived.Data.Status)
+
+	httpStatus, respBody = suite.request(http.MethodGet, "/admin/products", "/admin/products?status=archived&category="+category, suite.Admin, nil, ListProducts)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	var list struct {
+		Data struct {
+			Products []struct {
+				ProductID int `json:"product_id"`
+			} `json:"products"`
+			Page     int   `json:"page"`
+			PageSize int   `json:"page_size"`
+			Total    int64 `json:"total"`
+		} `json:"data"`
+	}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &list))
+	assert.Equal(suite.T(), int64(1), list.Data.Total)
+	assert.Equal(suite.T(), 1, list.Data.Page)
+	assert.Equal(suite.T(), 20, list.Data.PageSize)
+	assert.Equal(suite.T(), id, list.Data.Products[0].ProductID)
+
+	httpStatus, _ = suite.request(http.MethodGet, "/admin/products/:id", "/admin/products/999999999", suite.Admin, nil, GetProduct)
+	assert.Equal(suite.T(), http.StatusNotFound, httpStatus)
+}
+
+func (suite *adminProductsSuite) TestWrongParameter() {
+	for _, body := range []map[string]interface{}{
+		{"price": 100},
+		{"name": "keyboard", "price": -1},
+		{"name": "keyboard", "status": "deleted"},
+	} {
+		httpStatus, _ := suite.request(http.MethodPost, "/admin/products", "/admin/products", suite.Admin, body, CreateProduct)
+		assert.Equal(suite.T(), http.StatusBadRequest, httpStatus, body)
+	}
+}
+
+func (suite *adminProductsSuite) TestNotAdmin() {
+	httpStatus, respBody := suite.request(http.MethodPost, "/admin/products", "/admin/products", suite.User, map[string]interface{}{
+		"name": "keyboard",
+	}, CreateProduct)
+	assert.Equal(suite.T(), http.StatusForbidden, httpStatus)
+	var resp struct {
+		Code int `json:"code"`
+	}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &resp))
+	assert.Equal(suite.T(), 1013, resp.Code)
+}
+
+func (suite *getRecommendationsSuite) TestInteractions() {
+	headers, uid := tokenForTest(suite.T(), false)
+	product := &model.Product{Name: "product " + util.RandString(6), Stock: 2, Status: domain.ProductStatusActive}
+	assert.Nil(suite.T(), db.Get().Create(product).Error)
+
+	url := fmt.Sprintf("/products/%d/events", product.ID)
+	for _, eventType := range []string{"view", "click"} {
+		httpStatus, _, err := util.RequestWithBodyForTest(http.MethodPost, "/products/:id/events", url, headers.Clone(), map[string]interface{}{"type": eventType}, middleware.AuthToken, RecordInteraction)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	}
+	// a purchase is recorded when the product is purchased, not as an event
+	for _, eventType := range []string{"like", "purchase"} {
+		httpStatus, _, err := util.RequestWithBodyForTest(http.MethodPost, "/products/:id/events", url, headers.Clone(), map[string]interface{}{"type": eventType}, middleware.AuthToken, RecordInteraction)
+		assert.Nil(suite.T(), err)
+		assert.Equal(suite.T(), http.StatusBadRequest, httpStatus)
+	}
+	purchaseURL := fmt.Sprintf("/products/%d/purchase", product.ID)
+	httpStatus, respBody, err := util.RequestWithBodyForTest(http.MethodPost, "/products/:id/purchase", purchaseURL, headers.Clone(), map[string]interface{}{}, middleware.AuthToken, PurchaseProduct)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	var purchaseResp struct {
+		Code int `json:"code"`
+		Data struct {
+			Stock int `json:"stock"`
+		} `json:"data"`
+	}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &purchaseResp))
+	assert.Equal(suite.T(), 1, purchaseResp.Data.Stock)
+	// the stock is short, and the failed purchase is not recorded
+	httpStatus, respBody, err = util.RequestWithBodyForTest(http.MethodPost, "/products/:id/purchase", purchaseURL, headers.Clone(), map[string]interface{}{"quantity": 2}, middleware.AuthToken, PurchaseProduct)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusConflict, httpStatus)
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &purchaseResp))
+	assert.Equal(suite.T(), 2013, purchaseResp.Code)
+
+	// an unknown product is not recorded
+	httpStatus, _, err = util.RequestWithBodyForTest(http.MethodPost, "/products/:id/events", fmt.Sprintf("/products/%d/events", product.ID+1000000), headers.Clone(), map[string]interface{}{"type": "view"}, middleware.AuthToken, RecordInteraction)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, httpStatus)
+
+	// the events are written by the flusher
+	assert.Eventually(suite.T(), func() bool {
+		var count int64
+		db.Get().Model(&model.ProductInteraction{}).Where("uid = ?", uid).Count(&count)
+		return count == 3
+	}, 5*time.Second, 20*time.Millisecond)
+
+	// the purchase weighs enough to make the product trend in the last hour
+	httpStatus, respBody, err = util.RequestWithHeaderForTest(http.MethodGet, "/products/trending", "/products/trending?hours=1&limit=100", headers.Clone(), middleware.AuthToken, GetTrending)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	var resp struct {
+		Data []struct {
+			ProductID int `json:"product_id"`
+		} `json:"data"`
+	}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &resp))
+	ids := []int{}
+	for _, p := range resp.Data {
+		ids = append(ids, p.ProductID)
+	}
+	assert.Contains(suite.T(), ids, product.ID)
+}
+
+func (suite *adminProductsSuite) TestExperimentReport() {
+	httpStatus, respBody := suite.request(http.MethodGet, "/admin/experiments/report", "/admin/experiments/report", suite.Admin, nil, GetExperimentReport)
+	assert.Equal(suite.T(), http.StatusNotFound, httpStatus)
+	var errResp struct {
+		Code int `json:"code"`
+	}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &errResp))
+	assert.Equal(suite.T(), 2012, errResp.Code)
+
+	counter := recommend.NewExposureCounter(cache.Client)
+	experiment, err := recommend.NewExperiment("test-"+util.RandString(8), []domain.ExperimentVariant{{Name: recommend.StrategyCoOccurrence, Weight: 1}})
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), recommend.InitExperiment(experiment, counter))
+	defer func() { assert.Nil(suite.T(), recommend.InitExperiment(nil, counter)) }()
+
+	// the user is shown the recommendations of the only variant, and then purchases
+	headers, uid := tokenForTest(suite.T(), false)
+	httpStatus, respBody, err = util.GetWithHeaderForTest("/products/recommendation", headers.Clone(), middleware.AuthToken, GetRecommendations)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	var recommendations struct {
+		Experiment string `json:"experiment"`
+		Variant    string `json:"variant"`
+	}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &recommendations))
+	assert.Equal(suite.T(), experiment.Name, recommendations.Experiment)
+	assert.Equal(suite.T(), recommend.StrategyCoOccurrence, recommendations.Variant)
+
+	product := &model.Product{Name: "product " + util.RandString(6), Stock: 2, Status: domain.ProductStatusActive}
+	assert.Nil(suite.T(), db.Get().Create(product).Error)
+	purchaseURL := fmt.Sprintf("/products/%d/purchase", product.ID)
+	httpStatus, _, err = util.RequestWithBodyForTest(http.MethodPost, "/products/:id/purchase", purchaseURL, headers.Clone(), map[string]interface{}{}, middleware.AuthToken, PurchaseProduct)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+
+	// a user who was not shown the variant is not in the experiment
+	otherHeaders, otherUID := tokenForTest(suite.T(), false)
+	httpStatus, _, err = util.RequestWithBodyForTest(http.MethodPost, "/products/:id/purchase", purchaseURL, otherHeaders, map[string]interface{}{}, middleware.AuthToken, PurchaseProduct)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	assert.Eventually(suite.T(), func() bool {
+		var count int64
+		db.Get().Model(&model.ProductInteraction{}).Where("uid IN ?", []string{uid, otherUID}).Count(&count)
+		return count == 2
+	}, 5*time.Second, 20*time.Millisecond)
+	var count int64
+	db.Get().Model(&model.ProductInteraction{}).Where("experiment = ?", experiment.Name).Count(&count)
+	assert.Equal(suite.T(), int64(1), count)
+
+	httpStatus, respBody = suite.request(http.MethodGet, "/admin/experiments/report", "/admin/experiments/report", suite.Admin, nil, GetExperimentReport)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	var report struct {
+		Data struct {
+			Experiment string `json:"experiment"`
+			Variants   []struct {
+				Name         string `json:"name"`
+				ExposedUsers int64  `json:"exposed_users"`
+				Impressions  int64  `json:"impressions"`
+				Outcomes     map[string]struct {
+					Events int64 `json:"events"`
+					Users  int64 `json:"users"`
+				} `json:"outcomes"`
+				ConversionRate float64 `json:"conversion_rate"`
+			} `json:"variants"`
+		} `json:"data"`
+	}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &report))
+	assert.Equal(suite.T(), experiment.Name, report.Data.Experiment)
+	assert.Len(suite.T(), report.Data.Variants, 1)
+	variant := report.Data.Variants[0]
+	assert.Equal(suite.T(), recommend.StrategyCoOccurrence, variant.Name)
+	assert.Equal(suite.T(), int64(1), variant.ExposedUsers)
+	assert.Equal(suite.T(), int64(1), variant.Impressions)
+	assert.Equal(suite.T(), int64(1), variant.Outcomes["purchase"].Users)
+	assert.Equal(suite.T(), 1.0, variant.ConversionRate)
+}
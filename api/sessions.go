@@ -0,0 +1,114 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Yu-Qi/GoAuth/pkg/repository"
+	"github.com/Yu-Qi/GoAuth/pkg/service/accounts"
+	"github.com/Yu-Qi/GoAuth/pkg/service/crypto"
+	"github.com/Yu-Qi/GoAuth/pkg/service/email"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
+)
+
+// ListSessions lists the active sessions of the current account
+func ListSessions(c *gin.Context) {
+	sessions, customErr := accounts.ListSessions(c, c.GetString("uid"), c.GetString("jti"), repository.GetAccountRepository())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+		"data": sessions,
+	})
+}
+
+type revokeSessionParams struct {
+	ID string `uri:"id" binding:"required"`
+}
+
+// RevokeSession revokes one session of the current account
+func RevokeSession(c *gin.Context) {
+	params := revokeSessionParams{}
+	if customErr := util.ToGinContextExt(c).BindUri(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	customErr := accounts.RevokeSession(c, c.GetString("uid"), params.ID, repository.GetAccountRepository())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+	})
+}
+
+type revokeOtherSessionsResp struct {
+	Revoked int64 `json:"revoked"`
+}
+
+// RevokeOtherSessions revokes all sessions of the current account except the current one
+func RevokeOtherSessions(c *gin.Context) {
+	revoked, customErr := accounts.RevokeOtherSessions(c, c.GetString("uid"), c.GetString("jti"), repository.GetAccountRepository())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+		"data": revokeOtherSessionsResp{
+			Revoked: revoked,
+		},
+	})
+}
+
+type reportSessionParams struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// ReportUnrecognizedSession handles the "this wasn't me" link of a new sign-in email. The link opens a front-end page,
+// which posts the code once the user confirms, so that a prefetch of the link by the mail client does not revoke anything.
+func ReportUnrecognizedSession(c *gin.Context) {
+	params := reportSessionParams{}
+	if customErr := util.ToGinContextExt(c).BindJson(&params); customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+
+	customErr := accounts.ReportUnrecognizedSession(c, params.Code, repository.GetAccountRepository(), crypto.GetService(), email.GetService())
+	if customErr != nil {
+		c.JSON(customErr.HttpStatus, map[string]interface{}{
+			"status":  customErr.HttpStatus,
+			"code":    customErr.Code,
+			"message": customErr.Error.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"code": 0,
+	})
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/Yu-Qi/GoAuth/api/middleware"
+	"github.com/Yu-Qi/GoAuth/pkg/db"
+	"github.com/Yu-Qi/GoAuth/pkg/db/model"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
+)
+
+type sessionsSuite struct {
+	suite.Suite
+	Email    string
+	Password string
+}
+
+func (suite *sessionsSuite) SetupSuite() {
+	// setup a new account in the database
+	suite.Email = util.RandEmail()
+	suite.Password = "Password1!" + util.RandString(3)
+	hashedPassword, err := util.GenerateBcryptPassword(suite.Password)
+	if err != nil {
+		panic(err)
+	}
+	db.Get().Create(&model.Account{UID: util.UUID(), Email: suite.Email, HashedPassword: string(hashedPassword), IsActive: true})
+}
+
+func TestSessions(t *testing.T) {
+	suite.Run(t, new(sessionsSuite))
+}
+
+func (suite *sessionsSuite) login() string {
+	_, respBody, err := util.PostForTest("/login", map[string]interface{}{
+		"email":    suite.Email,
+		"password": suite.Password,
+	}, Login)
+	assert.Nil(suite.T(), err)
+	var resp struct {
+		Data struct {
+			AccessToken string `json:"access_token"`
+		} `json:"data"`
+	}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &resp))
+	return resp.Data.AccessToken
+}
+
+func (suite *sessionsSuite) listSessions(token string) (int, []struct {
+	ID      string `json:"id"`
+	Current bool   `json:"current"`
+}) {
+	headers := http.Header{
+		"Authorization": []string{"Bearer " + token},
+	}
+	httpStatus, respBody, err := util.GetWithHeaderForTest("/account/sessions", headers, middleware.AuthToken, ListSessions)
+	assert.Nil(suite.T(), err)
+	var resp struct {
+		Code int `json:"code"`
+		Data []struct {
+			ID      string `json:"id"`
+			Current bool   `json:"current"`
+		} `json:"data"`
+	}
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &resp))
+	return httpStatus, resp.Data
+}
+
+func (suite *sessionsSuite) TestRevokeSessions() {
+	token := suite.login()
+	otherToken := suite.login()
+
+	httpStatus, sessions := suite.listSessions(token)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	assert.GreaterOrEqual(suite.T(), len(sessions), 2)
+	current := 0
+	for _, session := range sessions {
+		if session.Current {
+			current++
+		}
+	}
+	assert.Equal(suite.T(), 1, current)
+
+	// revoke all sessions except the current one
+	headers := http.Header{
+		"Authorization": []string{"Bearer " + token},
+	}
+	httpStatus, _, err := util.RequestWithHeaderForTest(http.MethodDelete, "/account/sessions", "/account/sessions", headers, middleware.AuthToken, RevokeOtherSessions)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+
+	// the other token is rejected
+	httpStatus, respBody, err := util.GetWithHeaderForTest("/account/sessions", http.Header{
+		"Authorization": []string{"Bearer " + otherToken},
+	}, middleware.AuthToken, ListSessions)
+	var resp struct {
+		Code int `json:"code"`
+	}
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusUnauthorized, httpStatus)
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &resp))
+	assert.Equal(suite.T(), 1010, resp.Code)
+
+	// the current token still works
+	httpStatus, sessions = suite.listSessions(token)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+	assert.Equal(suite.T(), 1, len(sessions))
+	assert.True(suite.T(), sessions[0].Current)
+}
+
+func (suite *sessionsSuite) TestRevokeSession() {
+	token := suite.login()
+	otherToken := suite.login()
+
+	_, sessions := suite.listSessions(otherToken)
+	otherID := ""
+	for _, session := range sessions {
+		if session.Current {
+			otherID = session.ID
+		}
+	}
+	assert.NotEmpty(suite.T(), otherID)
+
+	headers := http.Header{
+		"Authorization": []string{"Bearer " + token},
+	}
+	httpStatus, _, err := util.RequestWithHeaderForTest(http.MethodDelete, "/account/sessions/:id", "/account/sessions/"+otherID, headers, middleware.AuthToken, RevokeSession)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, httpStatus)
+
+	httpStatus, _ = suite.listSessions(otherToken)
+	assert.Equal(suite.T(), http.StatusUnauthorized, httpStatus)
+
+	// revoking it again is not found
+	httpStatus, respBody, err := util.RequestWithHeaderForTest(http.MethodDelete, "/account/sessions/:id", "/account/sessions/"+otherID, headers, middleware.AuthToken, RevokeSession)
+	var resp struct {
+		Code int `json:"code"`
+	}
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusNotFound, httpStatus)
+	assert.Nil(suite.T(), json.Unmarshal(respBody, &resp))
+	assert.Equal(suite.T(), 2005, resp.Code)
+}
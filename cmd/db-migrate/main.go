@@ -1,27 +1,116 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
 
 	"github.com/Yu-Qi/GoAuth/pkg/db"
-	"github.com/Yu-Qi/GoAuth/pkg/db/model"
+	"github.com/Yu-Qi/GoAuth/pkg/db/migrations"
 )
 
-func tables() []interface{} {
-	return []interface{}{
-		&model.Account{},
+// migrationsDir is where create writes new migrations, relative to the root of the repository
+const migrationsDir = "pkg/db/migrations"
+
+const usage = `usage: db-migrate <command>
+
+commands:
+  up               apply all pending migrations
+  down N           revert the latest N applied migrations, 1 if N is omitted
+  status           list the migrations and whether they are applied
+  create NAME      write an empty migration to ` + migrationsDir + `
+  force VERSION    record VERSION as the latest applied migration without running any, clears a dirty state
+`
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		logrus.Fatal(err)
 	}
 }
 
-func create() error {
-	// Create tables.
-	if err := db.Get().AutoMigrate(tables()...); err != nil {
+func run(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("no command")
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := runner().Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %d %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no pending migration")
+		}
 		return err
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("invalid N %s", args[1])
+			}
+		}
+		reverted, err := runner().Down(ctx, n)
+		for _, m := range reverted {
+			fmt.Printf("reverted %d %s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := runner().Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", ""
+			if s.Applied {
+				state, appliedAt = "applied", s.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			if s.Dirty {
+				state = "dirty"
+			}
+			if s.Missing {
+				state += " (missing)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		return w.Flush()
+	case "create":
+		if len(args) < 2 {
+			return fmt.Errorf("create needs a NAME")
+		}
+		path, err := migrations.Create(migrationsDir, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created %s\n", path)
+		return nil
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("force needs a VERSION")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid VERSION %s", args[1])
+		}
+		if err := runner().Force(ctx, version); err != nil {
+			return err
+		}
+		fmt.Printf("forced version %d\n", version)
+		return nil
 	}
-	return nil
+	fmt.Fprint(os.Stderr, usage)
+	return fmt.Errorf("unknown command %s", args[0])
 }
 
-func main() {
-	create()
-	fmt.Println("Tables created.")
+func runner() *migrations.Runner {
+	return migrations.NewRunner(db.Get(), migrations.All())
 }
@@ -6,16 +6,34 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 
 	"github.com/Yu-Qi/GoAuth/api"
 	"github.com/Yu-Qi/GoAuth/api/middleware"
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/cache"
+	"github.com/Yu-Qi/GoAuth/pkg/config"
+	"github.com/Yu-Qi/GoAuth/pkg/db"
+	"github.com/Yu-Qi/GoAuth/pkg/geoip"
+	"github.com/Yu-Qi/GoAuth/pkg/repository"
+	"github.com/Yu-Qi/GoAuth/pkg/service/accounts"
 	"github.com/Yu-Qi/GoAuth/pkg/service/crypto"
 	"github.com/Yu-Qi/GoAuth/pkg/service/email"
+	"github.com/Yu-Qi/GoAuth/pkg/service/interactions"
+	"github.com/Yu-Qi/GoAuth/pkg/service/otp"
+	"github.com/Yu-Qi/GoAuth/pkg/service/outbox"
+	"github.com/Yu-Qi/GoAuth/pkg/service/password"
+	"github.com/Yu-Qi/GoAuth/pkg/service/recommend"
+	"github.com/Yu-Qi/GoAuth/pkg/service/risk"
+	"github.com/Yu-Qi/GoAuth/pkg/service/sms"
+	"github.com/Yu-Qi/GoAuth/pkg/smtpsink"
 )
 
 func main() {
@@ -28,6 +46,8 @@ func main() {
 
 	registerAccountAPI(r)
 	registerProductAPI(r)
+	registerAdminAPI(r)
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
 	startServer(r)
 }
@@ -36,17 +56,336 @@ func registerAccountAPI(r *gin.Engine) {
 	r.POST("/register", api.Register)
 	r.POST("/login", api.Login)
 	r.POST("/verify-email", api.VerifyEmail)
+	r.POST("/account/not-me", api.ReportUnrecognizedSession)
+	r.POST("/forgot-password", api.ForgotPassword)
+	r.POST("/reset-password", api.ResetPassword)
+	r.POST("/login/phone/code", api.SendLoginCode)
+	r.POST("/login/phone", api.LoginWithPhone)
+
+	account := r.Group("/account", middleware.AuthToken)
+	account.GET("/sessions", api.ListSessions)
+	account.DELETE("/sessions", api.RevokeOtherSessions)
+	account.DELETE("/sessions/:id", api.RevokeSession)
+	account.POST("/password", api.ChangePassword)
+	account.POST("/phone", api.StartPhoneVerification)
+	account.POST("/phone/verify", api.VerifyPhone)
 }
 
 func registerProductAPI(r *gin.Engine) {
 	product := r.Group("/products", middleware.AuthToken)
 	product.GET("/recommendation", api.GetRecommendations)
+	product.GET("/trending", api.GetTrending)
+	product.POST("/:id/events", api.RecordInteraction)
+	product.POST("/:id/purchase", api.PurchaseProduct)
+}
+
+func registerAdminAPI(r *gin.Engine) {
+	admin := r.Group("/admin", middleware.AuthToken, middleware.RequireAdmin)
+	admin.GET("/products", api.ListProducts)
+	admin.POST("/products", api.CreateProduct)
+	admin.GET("/products/:id", api.GetProduct)
+	admin.PATCH("/products/:id", api.UpdateProduct)
+	admin.POST("/products/:id/archive", api.ArchiveProduct)
+	admin.GET("/experiments/report", api.GetExperimentReport)
 }
 
 func initService() {
 	verificationCodeExpireSec := 600
 	crypto.InitService("your-strong-password", "your-salt-string", 4096, verificationCodeExpireSec)
-	email.InitService(email.NewPrintEmailService())
+	initRedis()
+	initLayeredCache()
+	repository.InitAccountRepository(db.NewAccountRepository())
+	repository.InitProductRepository(db.NewProductRepository())
+	repository.InitInteractionRepository(db.NewInteractionRepository())
+	initEmailService()
+	initOTPService()
+	initPasswordService()
+	initPasswordPolicy()
+	accounts.Init(accounts.InitParam{
+		EnumerationProtection: config.GetBool("ENUMERATION_PROTECTION"),
+		PasswordHistoryDepth:  config.GetInt("PASSWORD_HISTORY_DEPTH"),
+		PasswordHasher:        password.GetService(),
+	})
+	initLoginRiskService()
+	initInteractionService()
+	initRecommendService()
+}
+
+func initRedis() {
+	// REDIS_MODE picks a single node, sentinel failover or a cluster
+	cfg, err := cache.ConfigFromEnv()
+	if err != nil {
+		panic(err)
+	}
+	if err := cache.Init(context.Background(), cfg); err != nil {
+		panic(err)
+	}
+}
+
+func initLayeredCache() {
+	// hot keys are kept in memory, the other instances drop them through redis pub/sub when they are deleted
+	layered := cache.NewLayered(cache.Client, cache.LayeredParams{
+		Capacity: config.GetInt("CACHE_LOCAL_CAPACITY"),
+		TTL:      time.Duration(config.GetInt("CACHE_LOCAL_TTL_MS")) * time.Millisecond,
+	})
+	if err := layered.Start(context.Background()); err != nil {
+		panic(err)
+	}
+	cache.InitLayered(layered)
+}
+
+func initEmailService() {
+	// providers are tried in order, each behind a circuit breaker
+	providers := []email.Provider{}
+	for _, name := range strings.Split(config.GetString("EMAIL_PROVIDERS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			providers = append(providers, newEmailProvider(name))
+		}
+	}
+	if len(providers) == 0 {
+		providers = append(providers, email.NewPrintEmailService())
+	}
+	sender := email.NewFailoverEmailService(email.FailoverParams{
+		Providers:        providers,
+		FailureThreshold: config.GetInt("EMAIL_BREAKER_FAILURE_THRESHOLD"),
+		OpenTimeout:      time.Duration(config.GetInt("EMAIL_BREAKER_OPEN_MS")) * time.Millisecond,
+	})
+
+	params := email.RendererParams{
+		DefaultLocale: config.GetString("EMAIL_DEFAULT_LOCALE"),
+		Links: map[string]string{
+			domain.EmailLinkVerifyEmail:   config.GetString("EMAIL_VERIFY_URL"),
+			domain.EmailLinkResetPassword: config.GetString("EMAIL_RESET_PASSWORD_URL"),
+			domain.EmailLinkNotMe:         config.GetString("LOGIN_RISK_NOT_ME_URL"),
+		},
+	}
+	if dir := config.GetString("EMAIL_TEMPLATES_DIR"); dir != "" {
+		params.FS = os.DirFS(dir)
+	}
+	renderer, err := email.NewRenderer(params)
+	if err != nil {
+		panic(err)
+	}
+
+	// emails are queued in the outbox, and delivered by the dispatcher in the background
+	dispatcher := outbox.NewDispatcher(outbox.DispatcherParams{
+		SendEmailSvc: email.NewTemplateEmailService(renderer, sender),
+		BatchSize:    config.GetInt("EMAIL_OUTBOX_BATCH_SIZE"),
+		PollInterval: time.Duration(config.GetInt("EMAIL_OUTBOX_POLL_INTERVAL_MS")) * time.Millisecond,
+		Lease:        time.Duration(config.GetInt("EMAIL_OUTBOX_LEASE_MS")) * time.Millisecond,
+		MaxAttempts:  config.GetInt("EMAIL_OUTBOX_MAX_ATTEMPTS"),
+		Backoff: outbox.Backoff{
+			Base:   time.Duration(config.GetInt("EMAIL_OUTBOX_BACKOFF_BASE_MS")) * time.Millisecond,
+			Max:    time.Duration(config.GetInt("EMAIL_OUTBOX_BACKOFF_MAX_MS")) * time.Millisecond,
+			Jitter: 0.2,
+		},
+	})
+	dispatcher.Start()
+	outbox.InitDispatcher(dispatcher)
+	email.InitService(outbox.NewEmailService())
+}
+
+func newEmailProvider(name string) email.Provider {
+	// SMTP_FROM is the name used before EMAIL_FROM, it is still read so existing deployments keep working
+	from := config.GetString("EMAIL_FROM")
+	if from == "" {
+		from = config.GetString("SMTP_FROM")
+	}
+	httpConfig := func(prefix string) email.HTTPProviderConfig {
+		return email.HTTPProviderConfig{
+			BaseURL: config.GetString(prefix + "_BASE_URL"),
+			APIKey:  config.GetString(prefix + "_API_KEY"),
+			From:    from,
+			Client: &http.Client{
+				Timeout: time.Duration(config.GetInt("EMAIL_PROVIDER_TIMEOUT_MS")) * time.Millisecond,
+			},
+		}
+	}
+
+	switch name {
+	case "print":
+		return email.NewPrintEmailService()
+	case "smtp":
+		if addr := config.GetString("SMTP_SINK_ADDR"); addr != "" {
+			startSMTPSink(addr, config.GetString("SMTP_SINK_HTTP_ADDR"))
+		}
+		return email.NewSMTPEmailService(email.SMTPConfig{
+			Host:        config.GetString("SMTP_HOST"),
+			Port:        config.GetInt("SMTP_PORT"),
+			Username:    config.GetString("SMTP_USERNAME"),
+			Password:    config.GetString("SMTP_PASSWORD"),
+			Auth:        config.GetString("SMTP_AUTH"),
+			TLS:         config.GetString("SMTP_TLS"),
+			From:        from,
+			Timeout:     time.Duration(config.GetInt("SMTP_TIMEOUT_MS")) * time.Millisecond,
+			IdleTimeout: time.Duration(config.GetInt("SMTP_IDLE_TIMEOUT_MS")) * time.Millisecond,
+		})
+	case "sendgrid":
+		return email.NewSendGridEmailService(httpConfig("SENDGRID"))
+	case "postmark":
+		return email.NewPostmarkEmailService(httpConfig("POSTMARK"))
+	case "resend":
+		return email.NewResendEmailService(httpConfig("RESEND"))
+	}
+	panic(fmt.Sprintf("unknown email provider %s", name))
+}
+
+// startSMTPSink starts an in-process SMTP sink for development, the received emails are served over http on httpAddr
+func startSMTPSink(addr, httpAddr string) {
+	sink := smtpsink.New()
+	if _, err := sink.Start(addr); err != nil {
+		panic(err)
+	}
+	logrus.Infof("smtp sink listening on %s", addr)
+	if httpAddr == "" {
+		return
+	}
+	go func() {
+		if err := http.ListenAndServe(httpAddr, sink.Handler()); err != nil {
+			logrus.Errorf("smtp sink http: %s", err)
+		}
+	}()
+}
+
+func initOTPService() {
+	var smsSvc domain.SendSMSService
+	switch name := config.GetString("SMS_SENDER"); name {
+	case "", "print":
+		smsSvc = sms.NewPrintSMSService()
+	case "http":
+		smsSvc = sms.NewHTTPSMSService(sms.HTTPGatewayConfig{
+			URL:    config.GetString("SMS_GATEWAY_URL"),
+			APIKey: config.GetString("SMS_GATEWAY_API_KEY"),
+			From:   config.GetString("SMS_FROM"),
+			Client: &http.Client{
+				Timeout: time.Duration(config.GetInt("SMS_GATEWAY_TIMEOUT_MS")) * time.Millisecond,
+			},
+		})
+	default:
+		panic(fmt.Sprintf("unknown SMS_SENDER %s", name))
+	}
+	sms.InitService(smsSvc)
+
+	otp.InitService(otp.NewService(otp.Params{
+		Client:       cache.Client,
+		SMSSvc:       smsSvc,
+		CodeLength:   config.GetInt("OTP_CODE_LENGTH"),
+		TTL:          time.Duration(config.GetInt("OTP_TTL_SEC")) * time.Second,
+		MaxAttempts:  config.GetInt("OTP_MAX_ATTEMPTS"),
+		SendInterval: time.Duration(config.GetInt("OTP_SEND_INTERVAL_SEC")) * time.Second,
+		SendLimit:    config.GetInt("OTP_SEND_LIMIT"),
+		SendWindow:   time.Duration(config.GetInt("OTP_SEND_WINDOW_SEC")) * time.Second,
+	}))
+}
+
+func initPasswordService() {
+	var hasher *password.PolicyHasher
+	switch name := config.GetString("PASSWORD_HASHER"); name {
+	case "argon2id":
+		params := password.DefaultArgon2idParams
+		params.Memory = uint32(config.GetInt("ARGON2ID_MEMORY_KB"))
+		params.Iterations = uint32(config.GetInt("ARGON2ID_ITERATIONS"))
+		params.Parallelism = uint8(config.GetInt("ARGON2ID_PARALLELISM"))
+		hasher = password.NewPolicyHasher(password.NewArgon2idHasher(params))
+	case "bcrypt":
+		hasher = password.NewPolicyHasher(password.NewBcryptHasher(config.GetInt("BCRYPT_COST")))
+	default:
+		panic(fmt.Sprintf("unknown PASSWORD_HASHER %q", name))
+	}
+
+	// leave cpu for the other requests under a burst of login and register
+	concurrency := config.GetInt("PASSWORD_HASH_CONCURRENCY")
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	queueTimeout := time.Duration(config.GetInt("PASSWORD_HASH_QUEUE_TIMEOUT_MS")) * time.Millisecond
+	password.InitService(password.NewLimitedHasher(hasher, password.NewExecutor(concurrency, queueTimeout)))
+}
+
+func initPasswordPolicy() {
+	params := password.PolicyParams{
+		MinLength:      config.GetInt("PASSWORD_MIN_LENGTH"),
+		MaxLength:      config.GetInt("PASSWORD_MAX_LENGTH"),
+		RequireUpper:   config.GetBool("PASSWORD_REQUIRE_UPPER"),
+		RequireLower:   config.GetBool("PASSWORD_REQUIRE_LOWER"),
+		RequireDigit:   config.GetBool("PASSWORD_REQUIRE_DIGIT"),
+		RequireSpecial: config.GetBool("PASSWORD_REQUIRE_SPECIAL"),
+		MinEntropyBits: float64(config.GetInt("PASSWORD_MIN_ENTROPY_BITS")),
+	}
+	if path := config.GetString("PASSWORD_BREACHED_LIST_PATH"); path != "" {
+		breached, err := password.NewBreachedChecker(path)
+		if err != nil {
+			panic(err)
+		}
+		params.Breached = breached
+	}
+	password.InitPolicy(password.NewPolicy(params))
+}
+
+func initLoginRiskService() {
+	rules := []domain.LoginRiskRule{
+		&risk.NewDeviceRule{Score: 40},
+		&risk.NewIPRangeRule{Score: 30},
+	}
+	if path := config.GetString("GEOIP_DB_PATH"); path != "" {
+		locator, err := geoip.NewCSVLocatorFromFile(path)
+		if err != nil {
+			panic(err)
+		}
+		rules = append(rules, &risk.ImpossibleTravelRule{Score: 100, MaxSpeedKmh: 1000, Locator: locator})
+	}
+	risk.InitService(risk.NewLoginRiskService(&risk.LoginRiskParams{
+		Rules:           rules,
+		Threshold:       config.GetInt("LOGIN_RISK_THRESHOLD"),
+		VerificationSvc: crypto.GetService(),
+		SendEmailSvc:    email.GetService(),
+		AccountRepo:     repository.GetAccountRepository(),
+	}))
+}
+
+func initInteractionService() {
+	counter := interactions.NewTrendingCounter(cache.Client, time.Duration(config.GetInt("TRENDING_RETENTION_HOURS"))*time.Hour)
+	interactions.InitCounter(counter)
+	interactions.InitLimiter(interactions.NewLimiter(cache.Client,
+		config.GetInt("INTERACTION_RATE_LIMIT"),
+		time.Duration(config.GetInt("INTERACTION_RATE_WINDOW_MS"))*time.Millisecond,
+	))
+
+	// events are buffered, and written in batches by the flusher in the background
+	buffer := interactions.NewBuffer(interactions.BufferParams{
+		Repo:          repository.GetInteractionRepository(),
+		Counter:       counter,
+		Capacity:      config.GetInt("INTERACTION_BUFFER_CAPACITY"),
+		BatchSize:     config.GetInt("INTERACTION_BATCH_SIZE"),
+		FlushInterval: time.Duration(config.GetInt("INTERACTION_FLUSH_INTERVAL_MS")) * time.Millisecond,
+	})
+	buffer.Start()
+	interactions.InitBuffer(buffer)
+}
+
+func initRecommendService() {
+	strategies := recommend.NewStrategies(recommend.Params{
+		ProductRepo:     repository.GetProductRepository(),
+		InteractionRepo: repository.GetInteractionRepository(),
+	})
+	if err := recommend.Init(strategies, config.GetString("RECOMMENDER")); err != nil {
+		panic(err)
+	}
+
+	// the users are bucketed into the strategies of the experiment, unless a request names one
+	var experiment *recommend.Experiment
+	if name := config.GetString("RECOMMEND_EXPERIMENT"); name != "" {
+		variants, err := recommend.ParseVariants(config.GetString("RECOMMEND_EXPERIMENT_VARIANTS"))
+		if err != nil {
+			panic(err)
+		}
+		if experiment, err = recommend.NewExperiment(name, variants); err != nil {
+			panic(err)
+		}
+	}
+	if err := recommend.InitExperiment(experiment, recommend.NewExposureCounter(cache.Client)); err != nil {
+		panic(err)
+	}
 }
 
 func startServer(r *gin.Engine) {
@@ -74,4 +413,23 @@ func startServer(r *gin.Engine) {
 	if err := srv.Shutdown(ctx); err != nil {
 		logrus.Errorf("Server Shutdown: %v", err)
 	}
+	// the server is shut down, so no event is recorded while the buffer drains
+	if buffer := interactions.GetBuffer(); buffer != nil {
+		if err := buffer.Stop(ctx); err != nil {
+			logrus.Errorf("Interaction Buffer Stop: %v", err)
+		}
+	}
+	if dispatcher := outbox.GetDispatcher(); dispatcher != nil {
+		if err := dispatcher.Stop(ctx); err != nil {
+			logrus.Errorf("Outbox Dispatcher Stop: %v", err)
+		}
+	}
+	if layered := cache.GetLayered(); layered != nil {
+		if err := layered.Stop(); err != nil {
+			logrus.Errorf("Layered Cache Stop: %v", err)
+		}
+	}
+	if err := cache.Close(); err != nil {
+		logrus.Errorf("Redis Close: %v", err)
+	}
 }
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/db"
+	"github.com/Yu-Qi/GoAuth/pkg/db/model"
+	"github.com/Yu-Qi/GoAuth/pkg/service/recommend"
+)
+
+const usage = `usage: reco-eval [flags]
+
+replays the stored interactions before a split time, and reports the precision@k and recall@k of each
+recommendation strategy for the interactions after it. The products and the interactions are read from the
+database, or from JSON lines files of domain.Product and domain.Interaction if -products and -events are given.
+
+flags:
+`
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("reco-eval", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	k := flags.Int("k", 10, "number of products recommended to each user")
+	testRatio := flags.Float64("test-ratio", 0.2, "share of the latest events kept for testing, unless -split is given")
+	split := flags.String("split", "", "RFC3339 time, the events at or after it are kept for testing")
+	productsPath := flags.String("products", "", "JSON lines file of the products")
+	eventsPath := flags.String("events", "", "JSON lines file of the interactions")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if (*productsPath == "") != (*eventsPath == "") {
+		return fmt.Errorf("-products and -events must be given together")
+	}
+	ctx := context.Background()
+
+	var products []domain.Product
+	var events []domain.Interaction
+	var err error
+	if *productsPath != "" {
+		if err = readJSONLines(*productsPath, &products); err != nil {
+			return err
+		}
+		if err = readJSONLines(*eventsPath, &events); err != nil {
+			return err
+		}
+	} else if products, events, err = readDB(ctx); err != nil {
+		return err
+	}
+
+	params := recommend.EvalParams{
+		Products: products,
+		Events:   events,
+		Split:    recommend.SplitByRatio(events, *testRatio),
+		K:        *k,
+	}
+	if *split != "" {
+		if params.Split, err = time.Parse(time.RFC3339, *split); err != nil {
+			return fmt.Errorf("invalid -split %s", *split)
+		}
+	}
+	results, err := recommend.Evaluate(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d products, %d events, split at %s\n", len(products), len(events), params.Split.UTC().Format(time.RFC3339))
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "STRATEGY\tUSERS\tPRECISION@%d\tRECALL@%d\n", *k, *k)
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%.4f\t%.4f\n", r.Strategy, r.Users, r.PrecisionAtK, r.RecallAtK)
+	}
+	return w.Flush()
+}
+
+// readJSONLines appends a value to the slice pointed by v for each line of the file
+func readJSONLines[T any](path string, v *[]T) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var item T
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			return fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		*v = append(*v, item)
+	}
+	return scanner.Err()
+}
+
+// readDB reads every product and interaction stored
+func readDB(ctx context.Context) ([]domain.Product, []domain.Interaction, error) {
+	productRows := []model.Product{}
+	if err := db.GetWith(ctx).Find(&productRows).Error; err != nil {
+		return nil, nil, err
+	}
+	products := make([]domain.Product, 0, len(productRows))
+	for _, p := range productRows {
+		products = append(products, domain.Product{
+			ID:        p.ID,
+			Name:      p.Name,
+			Category:  p.Category,
+			Stock:     p.Stock,
+			Status:    p.Status,
+			CreatedAt: p.CreatedAt,
+			UpdatedAt: p.UpdatedAt,
+		})
+	}
+
+	interactionRows := []model.ProductInteraction{}
+	if err := db.GetWith(ctx).Order("created_at, id").Find(&interactionRows).Error; err != nil {
+		return nil, nil, err
+	}
+	events := make([]domain.Interaction, 0, len(interactionRows))
+	for _, i := range interactionRows {
+		events = append(events, domain.Interaction{
+			UID:       i.UID,
+			ProductID: i.ProductID,
+			Type:      i.Type,
+			CreatedAt: i.CreatedAt,
+		})
+	}
+	return products, events, nil
+}
@@ -4,21 +4,153 @@
 export ENV=local
 export APP_PORT=9030
 
-# database
+# database, DB_DRIVER is mysql, postgres or sqlite
+export DB_DRIVER=mysql
+export DB_SLOW_THRESHOLD=1000
 export MYSQL_HOST=127.0.0.1
 export MYSQL_PORT=3306
 export MYSQL_USERNAME=root
 export MYSQL_PASSWORD=changeit
 export MYSQL_OPTIONS=charset=utf8mb4\&parseTime=True\&loc=UTC
 export MYSQL_DATABASE=local
-export MYSQL_SLOW_THRESHOLD=1000
 export MYSQL_DSN="$MYSQL_USERNAME:$MYSQL_PASSWORD@tcp($MYSQL_HOST:$MYSQL_PORT)/$MYSQL_DATABASE?$MYSQL_OPTIONS"
+export POSTGRES_HOST=127.0.0.1
+export POSTGRES_PORT=5432
+export POSTGRES_USERNAME=postgres
+export POSTGRES_PASSWORD=changeit
+export POSTGRES_OPTIONS="sslmode=disable TimeZone=UTC"
+export POSTGRES_DATABASE=local
+# a file path, or :memory: for a database which lives as long as the process
+export SQLITE_PATH=local.db
 
 # redis
+# standalone, sentinel or cluster
+export REDIS_MODE=standalone
+# comma separated host:port of the node, the sentinels or the cluster seeds, REDIS_HOST:REDIS_PORT if empty
+export REDIS_ADDRS=
 export REDIS_HOST=127.0.0.1
 export REDIS_PORT=6379
+# the ACL user, empty for the default user
+export REDIS_USERNAME=
 export REDIS_AUTH=
+# the master monitored by the sentinels, and the credentials of the sentinels
+export REDIS_MASTER_NAME=
+export REDIS_SENTINEL_USERNAME=
+export REDIS_SENTINEL_AUTH=
+export REDIS_TLS=false
+# the CA of the server certificate, the system CAs if empty
+export REDIS_TLS_CA_FILE=
+export REDIS_TLS_SERVER_NAME=
+
+# accounts
+# hide whether an email is registered on login and register
+export ENUMERATION_PROTECTION=true
+# argon2id or bcrypt, hashes of the other algorithm are still verified and rehashed on login
+export PASSWORD_HASHER=argon2id
+export ARGON2ID_MEMORY_KB=65536
+export ARGON2ID_ITERATIONS=3
+export ARGON2ID_PARALLELISM=2
+export BCRYPT_COST=15
+# concurrent password hashing, 0 is the number of cpus
+export PASSWORD_HASH_CONCURRENCY=0
+# how long a hashing call waits for a worker before 503, 0 waits until the request is done
+export PASSWORD_HASH_QUEUE_TIMEOUT_MS=2000
+# password policy, lengths are in characters
+export PASSWORD_MIN_LENGTH=8
+export PASSWORD_MAX_LENGTH=128
+export PASSWORD_REQUIRE_UPPER=true
+export PASSWORD_REQUIRE_LOWER=true
+export PASSWORD_REQUIRE_DIGIT=false
+export PASSWORD_REQUIRE_SPECIAL=true
+export PASSWORD_MIN_ENTROPY_BITS=40
+# a file of SHA1[:COUNT] lines, or a directory of k-anonymity range files named by the 5-char SHA-1 prefix
+export PASSWORD_BREACHED_LIST_PATH=
+# number of previous passwords which can not be reused
+export PASSWORD_HISTORY_DEPTH=5
 
 # jwt
 export ACCESS_TOKEN_EXP_MINUTES=1440
-export JWT_TOKEN_SECRET=changeit
\ No newline at end of file
+export JWT_TOKEN_SECRET=changeit
+
+# email, EMAIL_PROVIDERS is a comma separated list of print, smtp, sendgrid, postmark and resend tried in order,
+# print is used when it is empty. SMTP_FROM is read when EMAIL_FROM is empty.
+export EMAIL_PROVIDERS=print
+export EMAIL_FROM="GoAuth <no-reply@goauth.local>"
+# a provider failing this many times in a row is skipped for EMAIL_BREAKER_OPEN_MS
+export EMAIL_BREAKER_FAILURE_THRESHOLD=5
+export EMAIL_BREAKER_OPEN_MS=30000
+export EMAIL_PROVIDER_TIMEOUT_MS=10000
+# email APIs, the base urls are optional
+export SENDGRID_API_KEY=
+export SENDGRID_BASE_URL=
+export POSTMARK_API_KEY=
+export POSTMARK_BASE_URL=
+export RESEND_API_KEY=
+export RESEND_BASE_URL=
+export SMTP_HOST=127.0.0.1
+export SMTP_PORT=2525
+export SMTP_USERNAME=
+export SMTP_PASSWORD=
+# none, plain or login
+export SMTP_AUTH=none
+# none, starttls or implicit
+export SMTP_TLS=none
+export SMTP_TIMEOUT_MS=10000
+export SMTP_IDLE_TIMEOUT_MS=30000
+# in-process smtp sink for development, not started when empty
+export SMTP_SINK_ADDR=127.0.0.1:2525
+export SMTP_SINK_HTTP_ADDR=127.0.0.1:8025
+# email templates, the embedded ones are used when EMAIL_TEMPLATES_DIR is empty
+export EMAIL_TEMPLATES_DIR=
+export EMAIL_DEFAULT_LOCALE=en
+# base urls of the links in emails, the code is appended as the query parameter code
+export EMAIL_VERIFY_URL=http://localhost:3000/verify-email
+export EMAIL_RESET_PASSWORD_URL=http://localhost:3000/reset-password
+# email outbox, a failed email is retried with exponential backoff until it is dead after the max attempts
+export EMAIL_OUTBOX_BATCH_SIZE=20
+export EMAIL_OUTBOX_POLL_INTERVAL_MS=1000
+export EMAIL_OUTBOX_LEASE_MS=60000
+export EMAIL_OUTBOX_MAX_ATTEMPTS=8
+export EMAIL_OUTBOX_BACKOFF_BASE_MS=1000
+export EMAIL_OUTBOX_BACKOFF_MAX_MS=3600000
+
+# sms, SMS_SENDER is print or http
+export SMS_SENDER=print
+export SMS_GATEWAY_URL=
+export SMS_GATEWAY_API_KEY=
+export SMS_FROM=GoAuth
+export SMS_GATEWAY_TIMEOUT_MS=10000
+# one-time passwords by sms, the send limits are per phone number
+export OTP_CODE_LENGTH=6
+export OTP_TTL_SEC=300
+export OTP_MAX_ATTEMPTS=5
+export OTP_SEND_INTERVAL_SEC=60
+export OTP_SEND_LIMIT=5
+export OTP_SEND_WINDOW_SEC=3600
+
+# login risk
+export LOGIN_RISK_THRESHOLD=50
+# a front-end page which asks the user to confirm, then posts the code to POST /account/not-me
+export LOGIN_RISK_NOT_ME_URL=http://localhost:3000/account/not-me
+# GeoLite2 City Blocks csv, impossible travel is not checked when empty
+export GEOIP_DB_PATH=
+
+# product interaction events, buffered in memory and written in batches
+export INTERACTION_BUFFER_CAPACITY=10000
+export INTERACTION_BATCH_SIZE=500
+export INTERACTION_FLUSH_INTERVAL_MS=1000
+# a user can record this many events in the window
+export INTERACTION_RATE_LIMIT=120
+export INTERACTION_RATE_WINDOW_MS=60000
+# trending counters are kept in redis per hour, for up to this many hours
+export TRENDING_RETENTION_HOURS=168
+
+# recommendation strategy when none is chosen by the request: popularity, recently_viewed, co_occurrence or category_affinity
+export RECOMMENDER=category_affinity
+# recommendation experiment, the users are bucketed into the strategies by the weights, none is running when the name is empty
+export RECOMMEND_EXPERIMENT=
+export RECOMMEND_EXPERIMENT_VARIANTS=category_affinity:50,co_occurrence:50
+
+# in-process cache in front of redis, a key is kept in memory for up to CACHE_LOCAL_TTL_MS
+export CACHE_LOCAL_CAPACITY=10000
+export CACHE_LOCAL_TTL_MS=30000
\ No newline at end of file
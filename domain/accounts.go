@@ -1,17 +1,83 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+)
 
 // Account is a struct that represents a user account
 type Account struct {
-	UID            string     `json:"uid"`
-	Email          string     `json:"email"`
-	HashedPassword string     `json:"-"`
-	IsActive       bool       `json:"-"`
-	SentAt         *time.Time `json:"-"`
+	UID                   string     `json:"uid"`
+	Email                 string     `json:"email"`
+	HashedPassword        string     `json:"-"`
+	IsActive              bool       `json:"-"`
+	SentAt                *time.Time `json:"-"`
+	PasswordResetRequired bool       `json:"-"`
+	Locale                string     `json:"locale"`
+	// Phone is the verified phone number in E.164, empty if none
+	Phone string `json:"phone,omitempty"`
+	// IsAdmin allows the admin APIs, such as managing the products
+	IsAdmin bool `json:"-"`
 }
 
 // UpdateAccountParams is the parameters for updating an account
 type UpdateAccountParams struct {
-	SentAt *time.Time
+	HashedPassword        *string
+	SentAt                *time.Time
+	PasswordResetRequired *bool
+}
+
+// CreateAccountParams is the parameters for creating an account
+type CreateAccountParams struct {
+	UID            string
+	Email          string
+	HashedPassword string
+	Locale         string
+	// VerificationEmail is queued in the outbox in the same transaction as the account, it is optional
+	VerificationEmail *EmailMessage
+}
+
+// ChangePasswordParams is the parameters for changing a password
+type ChangePasswordParams struct {
+	UID            string
+	HashedPassword string
+	// HistoryDepth is the number of previous hashes kept, 0 keeps none
+	HistoryDepth       int
+	ClearResetRequired bool
+}
+
+// AccountRepository stores accounts and their password history
+type AccountRepository interface {
+	CreateAccount(ctx context.Context, params *CreateAccountParams) *code.CustomError
+	// GetAccount, GetAccountByEmail and GetAccountByPhone return code.UserNotFound for an unknown account
+	GetAccount(ctx context.Context, uid string) (*Account, *code.CustomError)
+	GetAccountByEmail(ctx context.Context, email string) (*Account, *code.CustomError)
+	GetAccountByPhone(ctx context.Context, phone string) (*Account, *code.CustomError)
+	// ActivateAccount returns code.AccountAlreadyActive for an active account
+	ActivateAccount(ctx context.Context, uid string) *code.CustomError
+	// UserExists returns nil only for an active account
+	UserExists(ctx context.Context, uid string) *code.CustomError
+	UpdateAccount(ctx context.Context, uid string, params *UpdateAccountParams) *code.CustomError
+	// SetPhone returns code.PhoneAlreadyUsed when another account has the phone number
+	SetPhone(ctx context.Context, uid, phone string) *code.CustomError
+	// ListPasswordHistory lists the latest previous password hashes, the latest first
+	ListPasswordHistory(ctx context.Context, uid string, limit int) ([]string, *code.CustomError)
+	// ChangePassword replaces the password and keeps the previous hash in the history
+	ChangePassword(ctx context.Context, params *ChangePasswordParams) *code.CustomError
+	CreateSession(ctx context.Context, params *CreateSessionParams) *code.CustomError
+	// GetSession returns code.SessionNotFound for an unknown session
+	GetSession(ctx context.Context, jti string) (*Session, *code.CustomError)
+	// ListSessions lists the sessions of an account which are not revoked, the latest active one first
+	ListSessions(ctx context.Context, uid string) ([]Session, *code.CustomError)
+	// ListRecentSessions lists the latest sessions of an account including the revoked ones, the latest created first
+	ListRecentSessions(ctx context.Context, uid string, limit int) ([]Session, *code.CustomError)
+	// TouchSession sets the last seen time of a session unless it has been set since notAfter
+	TouchSession(ctx context.Context, jti string, seenAt, notAfter time.Time) *code.CustomError
+	// RevokeSession returns code.SessionNotFound for a session which is not an active one of the account
+	RevokeSession(ctx context.Context, uid, jti string) *code.CustomError
+	// RevokeOtherSessions revokes the sessions of an account except exceptJTI, empty for every session,
+	// and returns the number revoked
+	RevokeOtherSessions(ctx context.Context, uid, exceptJTI string) (int64, *code.CustomError)
 }
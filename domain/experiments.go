@@ -0,0 +1,29 @@
+package domain
+
+import "context"
+
+// ExperimentVariant is a variant of an experiment, the users are bucketed into the variants by the weights
+type ExperimentVariant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// VariantOutcome is the number of the interactions of a type by the users of a variant
+type VariantOutcome struct {
+	Variant string
+	Type    string
+	Events  int64
+	// Users is the number of the distinct users
+	Users int64
+}
+
+// ExposureCounter counts the users shown the recommendations of each variant of an experiment
+type ExposureCounter interface {
+	AddExposure(ctx context.Context, experiment, variant, uid string) error
+	// CountExposures returns the approximate number of the distinct users, and the number of the exposures
+	CountExposures(ctx context.Context, experiment, variant string) (users, impressions int64, err error)
+	// ExposedVariant returns the variant last shown to the user, it is empty if the user was not shown one
+	ExposedVariant(ctx context.Context, experiment, uid string) (string, error)
+	// RemoveExposure forgets the variant shown to the user, who is shown another strategy
+	RemoveExposure(ctx context.Context, experiment, uid string) error
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+)
+
+// interaction types of a user with a product
+const (
+	InteractionView      = "view"
+	InteractionClick     = "click"
+	InteractionAddToCart = "add_to_cart"
+	InteractionPurchase  = "purchase"
+)
+
+// InteractionWeights is how much each type of interaction tells about the interest of a user
+var InteractionWeights = map[string]int{
+	InteractionView:      1,
+	InteractionClick:     2,
+	InteractionAddToCart: 3,
+	InteractionPurchase:  5,
+}
+
+// Interaction is an interaction of a user with a product
+type Interaction struct {
+	UID       string    `json:"uid"`
+	ProductID int       `json:"product_id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	// Experiment and Variant are the recommendation experiment the user was in, empty if none
+	Experiment string `json:"experiment,omitempty"`
+	Variant    string `json:"variant,omitempty"`
+}
+
+// RelatedProductsParams is the parameters for listing the products related to other products
+type RelatedProductsParams struct {
+	ProductIDs []int
+	// ExcludeUID is the user whose interactions are not counted, usually the one recommended to
+	ExcludeUID string
+	// Since is the start of the interactions counted
+	Since time.Time
+	Limit int
+}
+
+// InteractionRepository stores the interactions of users with products
+type InteractionRepository interface {
+	CreateInteractions(ctx context.Context, interactions []Interaction) *code.CustomError
+	// ListInteractions lists the latest interactions of a user, the latest first
+	ListInteractions(ctx context.Context, uid string, limit int) ([]Interaction, *code.CustomError)
+	// ListRelatedProductIDs lists the other products which the users of params.ProductIDs interacted with,
+	// by the number of such users, the most first
+	ListRelatedProductIDs(ctx context.Context, params *RelatedProductsParams) ([]int, *code.CustomError)
+	// CountOutcomes counts the interactions recorded in the experiment by variant and type
+	CountOutcomes(ctx context.Context, experiment string) ([]VariantOutcome, *code.CustomError)
+}
+
+// InteractionRecorder records interactions in the background
+type InteractionRecorder interface {
+	Record(interaction Interaction) error
+}
+
+// InteractionLimiter limits how many interactions a user can record
+type InteractionLimiter interface {
+	// Allow counts an interaction of the user and returns false if the user recorded too many
+	Allow(ctx context.Context, uid string) (bool, error)
+}
+
+// InteractionCounter keeps the weighted interactions of the products for the trending queries
+type InteractionCounter interface {
+	Add(ctx context.Context, interactions []Interaction) error
+	// Trending returns the ids of the products with the most weighted interactions in the last window, the most first
+	Trending(ctx context.Context, window time.Duration, limit int) ([]int, error)
+}
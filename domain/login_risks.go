@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// LoginAttempt is a successful login to be assessed for risk
+type LoginAttempt struct {
+	UID       string
+	Email     string
+	JTI       string
+	UserAgent string
+	IP        string
+	At        time.Time
+}
+
+// LoginRiskRule scores a login attempt against the previous sessions of the same account.
+// A rule returns 0 when it finds nothing suspicious, otherwise a positive score and a human readable reason.
+type LoginRiskRule interface {
+	Name() string
+	Evaluate(ctx context.Context, attempt *LoginAttempt, history []Session) (score int, reason string)
+}
+
+// GeoLocation is the location of an ip
+type GeoLocation struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// GeoIPLocator looks up the location of an ip
+type GeoIPLocator interface {
+	Locate(ip net.IP) (*GeoLocation, bool)
+}
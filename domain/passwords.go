@@ -0,0 +1,13 @@
+package domain
+
+import "context"
+
+// PasswordHasher hashes passwords into self-describing hash strings and verifies them
+type PasswordHasher interface {
+	// Hash hashes a password with the current policy
+	Hash(ctx context.Context, password string) (string, error)
+	// Compare returns nil if the password matches the hash
+	Compare(ctx context.Context, hash string, password string) error
+	// NeedsRehash reports whether the hash was made with an algorithm or parameters older than the current policy
+	NeedsRehash(hash string) bool
+}
@@ -1,7 +1,84 @@
 package domain
 
+import (
+	"context"
+	"time"
+
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+)
+
+// product statuses, only active products are recommended
+const (
+	ProductStatusActive   = "active"
+	ProductStatusArchived = "archived"
+)
+
 // Product represents a product
 type Product struct {
-	ID   int    `json:"product_id"`
-	Name string `json:"name"`
+	ID          int    `json:"product_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Price is in the minor unit of the currency, such as cents
+	Price     int64     `json:"price"`
+	Category  string    `json:"category"`
+	Stock     int       `json:"stock"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateProductParams is the parameters for creating a product
+type CreateProductParams struct {
+	Name        string
+	Description string
+	Price       int64
+	Category    string
+	Stock       int
+	// Status is ProductStatusActive if empty
+	Status string
+}
+
+// UpdateProductParams is the parameters for updating a product, nil fields are not changed
+type UpdateProductParams struct {
+	Name        *string
+	Description *string
+	Price       *int64
+	Category    *string
+	Stock       *int
+	Status      *string
+}
+
+// ListProductsParams is the parameters for listing products, empty filters match every product
+type ListProductsParams struct {
+	Status   string
+	Category string
+	Offset   int
+	Limit    int
+}
+
+// PopularProductsParams is the parameters for listing the popular products
+type PopularProductsParams struct {
+	// Categories limits the products to the categories, empty for every category
+	Categories []string
+	ExcludeIDs []int
+	// Since is the start of the interactions counted for the popularity
+	Since time.Time
+	Limit int
+}
+
+// ProductRepository stores products
+type ProductRepository interface {
+	CreateProduct(ctx context.Context, params *CreateProductParams) (*Product, *code.CustomError)
+	// GetProduct and UpdateProduct return code.ProductNotFound for an unknown product
+	GetProduct(ctx context.Context, id int) (*Product, *code.CustomError)
+	UpdateProduct(ctx context.Context, id int, params *UpdateProductParams) (*Product, *code.CustomError)
+	// PurchaseProduct takes the quantity from the stock of an active product and returns the updated product,
+	// code.ProductNotFound for an unknown or inactive product and code.OutOfStock if the stock is short
+	PurchaseProduct(ctx context.Context, id int, quantity int) (*Product, *code.CustomError)
+	// ListProducts lists a page of the products, the latest first, and the number of products matching the filters
+	ListProducts(ctx context.Context, params *ListProductsParams) ([]Product, int64, *code.CustomError)
+	// ListProductsByIDs lists the products of the ids, the unknown ids are skipped
+	ListProductsByIDs(ctx context.Context, ids []int) ([]Product, *code.CustomError)
+	// ListPopularProducts lists the active products in stock, by the weighted interactions since params.Since and then the latest first
+	ListPopularProducts(ctx context.Context, params *PopularProductsParams) ([]Product, *code.CustomError)
 }
@@ -0,0 +1,15 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+)
+
+// Recommender is a strategy which recommends products to users
+type Recommender interface {
+	// Name identifies the strategy, such as in the requests and the cache keys
+	Name() string
+	// Recommend recommends up to limit active products in stock to a user, the best first
+	Recommend(ctx context.Context, uid string, limit int) ([]Product, *code.CustomError)
+}
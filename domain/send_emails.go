@@ -1,6 +1,47 @@
 package domain
 
+import "context"
+
+// EmailMessage is an email to send.
+// When Template is set, Subject, Text and HTML are rendered from the template of Template and Locale with Data.
+type EmailMessage struct {
+	// ID is the idempotency key of the email, an email is queued once per ID and retries keep the same Message-ID
+	ID       string
+	To       string
+	Template string
+	Locale   string
+	Data     map[string]interface{}
+	Subject  string
+	Text     string
+	// HTML is optional, the email is sent as multipart/alternative with Text when it is set
+	HTML string
+}
+
 // SendEmailService is an interface for sending emails
 type SendEmailService interface {
-	SendEmail(email string, subject string, body string) error
+	SendEmail(ctx context.Context, msg *EmailMessage) error
 }
+
+// OutboxEmail is an email in the outbox claimed for delivery
+type OutboxEmail struct {
+	ID         int64
+	Message    *EmailMessage
+	AccountUID string
+	// Attempts counts the delivery attempts including the current one
+	Attempts int
+}
+
+// templates of EmailMessage
+const (
+	EmailTemplateVerifyEmail       = "verify_email"
+	EmailTemplateAlreadyRegistered = "already_registered"
+	EmailTemplateResetPassword     = "reset_password"
+	EmailTemplateNewSignIn         = "new_sign_in"
+)
+
+// links in emails, the base url of each is configured and the code is appended as the query parameter code
+const (
+	EmailLinkVerifyEmail   = "verify_email"
+	EmailLinkResetPassword = "reset_password"
+	EmailLinkNotMe         = "not_me"
+)
@@ -0,0 +1,27 @@
+package domain
+
+import "context"
+
+// SMSMessage is a text message to a phone number in E.164
+type SMSMessage struct {
+	To   string
+	Text string
+}
+
+// SendSMSService is an interface for sending text messages
+type SendSMSService interface {
+	SendSMS(msg *SMSMessage) error
+}
+
+// OTPService sends one-time passwords to phone numbers and verifies them
+type OTPService interface {
+	// Send sends a new code for the purpose to phone, the subject is what the code proves, such as a uid
+	Send(ctx context.Context, purpose, phone, subject string) error
+	// AllowSend counts a code against the send limits of phone without sending it, so that a number
+	// which gets no code is limited the same as one which does
+	AllowSend(ctx context.Context, phone string) error
+	// Deliver sends a new code like Send, without the send limits which the caller has applied with AllowSend
+	Deliver(ctx context.Context, purpose, phone, subject string) error
+	// Verify consumes the code and returns its subject
+	Verify(ctx context.Context, purpose, phone, code string) (string, error)
+}
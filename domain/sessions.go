@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// Session is a struct that represents a login session, keyed by the jti of the access token
+type Session struct {
+	JTI        string     `json:"id"`
+	UID        string     `json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"-"`
+	Current    bool       `json:"current"`
+}
+
+// CreateSessionParams is the parameters for recording a login session
+type CreateSessionParams struct {
+	JTI       string
+	UID       string
+	UserAgent string
+	IP        string
+}
@@ -0,0 +1,131 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned by Allow when the breaker is open
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is the state of a Breaker
+type State int
+
+// states of a Breaker
+const (
+	// Closed lets every call through
+	Closed State = iota
+	// Open rejects every call until the open timeout passes
+	Open
+	// HalfOpen lets one trial call through, which closes or opens the breaker again
+	HalfOpen
+)
+
+// String .
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Params is the parameters for creating a Breaker
+type Params struct {
+	// FailureThreshold is the number of consecutive failures which opens the breaker
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before a trial call is let through
+	OpenTimeout time.Duration
+}
+
+// Breaker is a circuit breaker, which stops calling a dependency that keeps failing and tries it again later
+type Breaker struct {
+	params Params
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	// trial is true while the trial call of the half-open state is running
+	trial bool
+}
+
+// New creates a Breaker
+func New(params Params) *Breaker {
+	return &Breaker{
+		params: params,
+		now:    time.Now,
+	}
+}
+
+// Allow returns ErrOpen if the call should not be made, otherwise the result of the call must be
+// reported with Success or Failure
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.params.OpenTimeout {
+			return ErrOpen
+		}
+		b.state = HalfOpen
+		b.trial = true
+		return nil
+	case HalfOpen:
+		if b.trial {
+			return ErrOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// Success reports a successful call, which closes the breaker
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = Closed
+	b.failures = 0
+	b.trial = false
+}
+
+// Failure reports a failed call, which opens the breaker after the threshold or a failed trial
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.state == HalfOpen || b.failures >= b.params.FailureThreshold {
+		b.state = Open
+		b.openedAt = b.now()
+	}
+}
+
+// State returns the current state
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.params.OpenTimeout {
+		return HalfOpen
+	}
+	return b.state
+}
+
+// Do calls fn if allowed and reports its result
+func (b *Breaker) Do(fn func() error) error {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		b.Failure()
+		return err
+	}
+	b.Success()
+	return nil
+}
@@ -0,0 +1,43 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := New(Params{FailureThreshold: 3, OpenTimeout: time.Minute})
+	b.now = func() time.Time { return now }
+	failing := func() error { return errors.New("failed") }
+	succeeding := func() error { return nil }
+
+	// a success resets the consecutive failures
+	assert.NotNil(t, b.Do(failing))
+	assert.NotNil(t, b.Do(failing))
+	assert.Nil(t, b.Do(succeeding))
+	assert.NotNil(t, b.Do(failing))
+	assert.NotNil(t, b.Do(failing))
+	assert.Equal(t, Closed, b.State())
+
+	assert.NotNil(t, b.Do(failing))
+	assert.Equal(t, Open, b.State())
+	assert.ErrorIs(t, b.Do(succeeding), ErrOpen)
+
+	// a failed trial opens it again
+	now = now.Add(time.Minute)
+	assert.Equal(t, HalfOpen, b.State())
+	assert.Nil(t, b.Allow())
+	// only one trial at a time
+	assert.ErrorIs(t, b.Allow(), ErrOpen)
+	b.Failure()
+	assert.Equal(t, Open, b.State())
+
+	// a successful trial closes it
+	now = now.Add(time.Minute)
+	assert.Nil(t, b.Do(succeeding))
+	assert.Equal(t, Closed, b.State())
+}
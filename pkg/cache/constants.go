@@ -1,6 +1,11 @@
 package cache
 
 const (
-	// CacheKeyProductRecommendation is the cache key for product recommendation
+	// CacheKeyProductRecommendation is the prefix of the cache keys for the product recommendations of the users
 	CacheKeyProductRecommendation = "product_recommendation"
 )
+
+// ProductRecommendationKey is the cache key for the product recommendations of a user by a strategy
+func ProductRecommendationKey(uid, strategy string) string {
+	return CacheKeyProductRecommendation + ":" + uid + ":" + strategy
+}
@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Yu-Qi/GoAuth/pkg/cache/lru"
+)
+
+// tiers of the layered cache
+const (
+	TierLocal = "local"
+	TierRedis = "redis"
+)
+
+// InvalidationChannel is the redis pub/sub channel of the keys deleted, every instance drops them from its local tier
+const InvalidationChannel = "cache_invalidation"
+
+var (
+	tierHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_hits_total",
+		Help: "Number of the reads of the layered cache found by tier, which is local or redis.",
+	}, []string{"tier"})
+	tierMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_misses_total",
+		Help: "Number of the reads of the layered cache not found by tier, which is local or redis.",
+	}, []string{"tier"})
+)
+
+// LayeredParams is the parameters of a Layered
+type LayeredParams struct {
+	// Capacity is the number of the keys kept in the local tier
+	Capacity int
+	// TTL is the longest a key is kept in the local tier, it bounds how stale a key is if an invalidation is lost
+	TTL time.Duration
+	// Channel is InvalidationChannel if empty
+	Channel string
+}
+
+var _ FencedStore = (*Layered)(nil)
+
+// Layered is a cache of a bounded in-process LRU in front of redis. A key deleted by any instance is dropped
+// from the local tier of every instance through redis pub/sub.
+type Layered struct {
+	client  redis.UniversalClient
+	local   *lru.Cache[string]
+	channel string
+
+	mu     sync.Mutex
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+// NewLayered creates a Layered, Start it to receive the invalidations of the other instances
+func NewLayered(client redis.UniversalClient, params LayeredParams) *Layered {
+	channel := params.Channel
+	if channel == "" {
+		channel = InvalidationChannel
+	}
+	return &Layered{
+		client:  client,
+		local:   lru.New[string](params.Capacity, params.TTL),
+		channel: channel,
+	}
+}
+
+// Get gets the value of the key from the local tier, or else from redis in one round trip
+func (l *Layered) Get(ctx context.Context, key string) (value string, found bool, err error) {
+	if value, ok := l.local.Get(key); ok {
+		tierHits.WithLabelValues(TierLocal).Inc()
+		return value, true, nil
+	}
+	tierMisses.WithLabelValues(TierLocal).Inc()
+
+	// the ttl is read in the same round trip, so that the local copy expires no later than the one in redis
+	pipe := l.client.Pipeline()
+	getCmd := pipe.Get(ctx, key)
+	ttlCmd := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return "", false, err
+	}
+	value, err = getCmd.Result()
+	if err == redis.Nil {
+		tierMisses.WithLabelValues(TierRedis).Inc()
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	tierHits.WithLabelValues(TierRedis).Inc()
+	// -1 is a key without an expiry
+	if ttl := ttlCmd.Val(); ttl > 0 {
+		l.local.SetWithTTL(key, value, ttl)
+	} else if ttl == -1 {
+		l.local.Set(key, value)
+	}
+	return value, true, nil
+}
+
+// Set sets the value of the key in both tiers
+func (l *Layered) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := l.client.Set(ctx, key, value, ttl).Err(); err != nil {
+		return err
+	}
+	l.local.SetWithTTL(key, value, ttl)
+	return nil
+}
+
+// setFencedScript sets the value and the latest fencing token of the key, unless a greater token has been seen.
+// The token is kept apart from the key, so that it outlives an invalidation of the key, and the hash tag of the key
+// keeps it in the slot of the key in a cluster.
+var setFencedScript = redis.NewScript(`
+local fence = tonumber(redis.call("GET", KEYS[2]) or "0")
+if tonumber(ARGV[3]) < fence then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+redis.call("SET", KEYS[2], ARGV[3], "PX", ARGV[2])
+return 1`)
+
+// SetFenced sets the value of the key in both tiers, unless it has been set with a greater fencing token
+func (l *Layered) SetFenced(ctx context.Context, key, value string, ttl time.Duration, token int64) (bool, error) {
+	keys := []string{key, "{" + key + "}:fence"}
+	applied, err := setFencedScript.Run(ctx, l.client, keys, value, ttl.Milliseconds(), token).Int()
+	if err != nil {
+		return false, err
+	}
+	if applied == 0 {
+		return false, nil
+	}
+	l.local.SetWithTTL(key, value, ttl)
+	return true, nil
+}
+
+// Del deletes the keys from both tiers, and from the local tier of the other instances
+func (l *Layered) Del(ctx context.Context, keys ...string) error {
+	l.local.Delete(keys...)
+	if err := del(ctx, l.client, keys); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := l.client.Publish(ctx, l.channel, key).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DelByPattern deletes the keys matching the redis glob pattern from both tiers, and from the local tier of the
+// other instances
+func (l *Layered) DelByPattern(ctx context.Context, pattern string) error {
+	l.invalidate(pattern)
+	if err := DelByPattern(ctx, pattern); err != nil {
+		return err
+	}
+	return l.client.Publish(ctx, l.channel, pattern).Err()
+}
+
+// invalidate drops the keys matching the pattern from the local tier, an exact key is a pattern matching itself
+func (l *Layered) invalidate(pattern string) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		l.local.Purge()
+		return
+	}
+	l.local.DeleteFunc(func(key string) bool {
+		matched, _ := path.Match(pattern, key)
+		return matched
+	})
+}
+
+// Start subscribes to the invalidations of the other instances
+func (l *Layered) Start(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.pubsub != nil {
+		return nil
+	}
+	pubsub := l.client.Subscribe(ctx, l.channel)
+	// wait for the subscription, so that no invalidation is missed after Start returns
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+	l.pubsub = pubsub
+	l.done = make(chan struct{})
+	go l.listen(pubsub.ChannelWithSubscriptions(ctx, 100))
+	return nil
+}
+
+func (l *Layered) listen(ch <-chan interface{}) {
+	defer close(l.done)
+	for msg := range ch {
+		switch msg := msg.(type) {
+		case *redis.Message:
+			l.invalidate(msg.Payload)
+		case *redis.Subscription:
+			// subscribed again after the connection was lost, the invalidations in between are unknown
+			logrus.WithFields(logrus.Fields{
+				"channel": msg.Channel,
+			}).Warn("Layered.listen, resubscribed")
+			l.local.Purge()
+		}
+	}
+}
+
+// Stop unsubscribes from the invalidations
+func (l *Layered) Stop() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.pubsub == nil {
+		return nil
+	}
+	err := l.pubsub.Close()
+	<-l.done
+	l.pubsub = nil
+	return err
+}
+
+var layered *Layered
+
+// InitLayered initializes the layered cache
+func InitLayered(l *Layered) {
+	layered = l
+}
+
+// GetLayered returns the layered cache
+func GetLayered() *Layered {
+	return layered
+}
@@ -0,0 +1,279 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrNotFound is returned by a load for a missing value, a Loader caches it for NegativeTTL
+var ErrNotFound = errors.New("cache: not found")
+
+// Store keeps the serialized values of a Loader, such as the Layered cache
+type Store interface {
+	// Get returns found false for a missing key
+	Get(ctx context.Context, key string) (value string, found bool, err error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+}
+
+// FencedStore is a Store which takes the fencing token of the lock held while loading a value, and rejects the
+// value of an owner older than the latest one it has seen
+type FencedStore interface {
+	Store
+	// SetFenced returns false without setting the value if a greater token has been seen for the key
+	SetFenced(ctx context.Context, key, value string, ttl time.Duration, token int64) (bool, error)
+}
+
+// LoaderParams is the parameters of a Loader
+type LoaderParams struct {
+	Store Store
+	// TTL is how long a loaded value is fresh
+	TTL time.Duration
+	// Jitter changes the TTL of each value by up to this fraction, so that the values loaded together do not expire together
+	Jitter float64
+	// StaleTTL is how long a value is still served after the TTL while it is loaded again in the background, 0 for none
+	StaleTTL time.Duration
+	// NegativeTTL is how long ErrNotFound of a load is cached, 0 for not caching it
+	NegativeTTL time.Duration
+	// LoadTimeout bounds a load, 0 for no bound. A load is detached from the context of the caller, which can
+	// leave without failing the other callers waiting for the same key.
+	LoadTimeout time.Duration
+	// Locker makes a key loaded by one instance at a time, nil for loading in each instance. While an instance
+	// loads a key, the others serve the stale value, or wait up to LockWait for the value and then load it too.
+	// LockWait is defaultLockWait if 0.
+	Locker   *Locker
+	LockWait time.Duration
+	// LockPoll is how often a waiting instance looks for the value, 50ms if 0
+	LockPoll time.Duration
+}
+
+// Loader is a typed cache aside. A missing key is loaded once at a time however many callers want it, and the
+// value is kept in the store as JSON.
+type Loader[T any] struct {
+	params LoaderParams
+	group  singleflight.Group
+	now    func() time.Time
+}
+
+// defaultLockWait is the LockWait of a Loader without one, a Loader waiting for nothing would never take the lock
+const defaultLockWait = time.Second
+
+// NewLoader creates a Loader
+func NewLoader[T any](params LoaderParams) *Loader[T] {
+	if params.LockWait <= 0 {
+		params.LockWait = defaultLockWait
+	}
+	return &Loader[T]{params: params, now: time.Now}
+}
+
+// refreshKey is the flight key of a refresh, apart from the load of a missing key, so that a caller waiting
+// for a value never gets the result of a refresh which was skipped
+func refreshKey(key string) string {
+	return "refresh/" + key
+}
+
+// envelope is what a Loader keeps in the store
+type envelope[T any] struct {
+	Value T `json:"value"`
+	// NotFound is a cached ErrNotFound
+	NotFound bool `json:"not_found,omitempty"`
+	// FreshUntil is in unix milliseconds, the value is stale after it
+	FreshUntil int64 `json:"fresh_until"`
+}
+
+// Get returns the value of the key from the store, or loads it. A stale value is returned at once, and loaded again
+// in the background. The store is only a cache, so a failure of it is logged and the value is loaded instead.
+func (l *Loader[T]) Get(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	raw, found, err := l.params.Store.Get(ctx, key)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"key":   key,
+			"error": err.Error(),
+		}).Warn("Loader.Get, Store.Get")
+	}
+	if found {
+		e := envelope[T]{}
+		if err := json.Unmarshal([]byte(raw), &e); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"key":   key,
+				"error": err.Error(),
+			}).Warn("Loader.Get, Unmarshal")
+		} else {
+			if l.now().UnixMilli() >= e.FreshUntil {
+				// nobody waits for the result, so a failure is logged here, and the stale value is served until
+				// a later refresh succeeds
+				l.group.DoChan(refreshKey(key), func() (any, error) {
+					value, err := l.refresh(key, load)
+					if err != nil && !errors.Is(err, ErrNotFound) {
+						logrus.WithFields(logrus.Fields{
+							"key":   key,
+							"error": err.Error(),
+						}).Warn("Loader.Get, refresh")
+					}
+					return value, err
+				})
+			}
+			if e.NotFound {
+				return zero, ErrNotFound
+			}
+			return e.Value, nil
+		}
+	}
+
+	// the load goes on for the other callers if this one leaves
+	select {
+	case result := <-l.group.DoChan(key, func() (any, error) {
+		return l.loadOnce(key, load)
+	}):
+		if result.Err != nil {
+			return zero, result.Err
+		}
+		return result.Val.(T), nil
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// refresh loads a stale value again in the background, unless another instance is loading it. Nobody waits for
+// its result, a skipped refresh returns the zero value.
+func (l *Loader[T]) refresh(key string, load func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	if l.params.Locker == nil {
+		return l.load(key, load, nil)
+	}
+	lock, err := l.params.Locker.TryAcquire(context.Background(), key)
+	if errors.Is(err, ErrLockHeld) {
+		return zero, nil
+	} else if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"key":   key,
+			"error": err.Error(),
+		}).Warn("Loader.refresh, TryAcquire")
+		return l.load(key, load, nil)
+	}
+	defer l.release(key, lock)
+	return l.load(key, load, lock)
+}
+
+// loadOnce loads a missing value. If another instance is loading it, it waits for the value up to LockWait, and
+// then loads the value too.
+func (l *Loader[T]) loadOnce(key string, load func(ctx context.Context) (T, error)) (T, error) {
+	if l.params.Locker == nil {
+		return l.load(key, load, nil)
+	}
+	poll := l.params.LockPoll
+	if poll <= 0 {
+		poll = 50 * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), l.params.LockWait)
+	defer cancel()
+	for {
+		lock, err := l.params.Locker.TryAcquire(ctx, key)
+		if err == nil {
+			defer l.release(key, lock)
+			// the other instance may have just loaded the value
+			if value, found, err := l.cached(ctx, key); found {
+				return value, err
+			}
+			return l.load(key, load, lock)
+		} else if !errors.Is(err, ErrLockHeld) {
+			if ctx.Err() == nil {
+				logrus.WithFields(logrus.Fields{
+					"key":   key,
+					"error": err.Error(),
+				}).Warn("Loader.loadOnce, TryAcquire")
+			}
+			return l.load(key, load, nil)
+		}
+
+		select {
+		case <-time.After(poll):
+		case <-ctx.Done():
+			return l.load(key, load, nil)
+		}
+		if value, found, err := l.cached(ctx, key); found {
+			return value, err
+		}
+	}
+}
+
+// cached returns the value of the key in the store, found is false if there is none or it cannot be read
+func (l *Loader[T]) cached(ctx context.Context, key string) (value T, found bool, err error) {
+	raw, found, err := l.params.Store.Get(ctx, key)
+	if err != nil || !found {
+		return value, false, nil
+	}
+	e := envelope[T]{}
+	if err := json.Unmarshal([]byte(raw), &e); err != nil {
+		return value, false, nil
+	}
+	if e.NotFound {
+		return value, true, ErrNotFound
+	}
+	return e.Value, true, nil
+}
+
+func (l *Loader[T]) release(key string, lock *Lock) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := lock.Release(ctx); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"key":   key,
+			"error": err.Error(),
+		}).Warn("Loader.release, Release")
+	}
+}
+
+// load loads the value under a context detached from the callers, and keeps it in the store. The value is written
+// with the fencing token of the lock if the store is a FencedStore, so that the value of an owner which has lost
+// the lock does not overwrite the value of the next owner.
+func (l *Loader[T]) load(key string, load func(ctx context.Context) (T, error), lock *Lock) (T, error) {
+	ctx := context.Background()
+	if l.params.LoadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.params.LoadTimeout)
+		defer cancel()
+	}
+
+	value, err := load(ctx)
+	e := envelope[T]{Value: value}
+	ttl := l.jittered(l.params.TTL)
+	if errors.Is(err, ErrNotFound) && l.params.NegativeTTL > 0 {
+		e = envelope[T]{NotFound: true}
+		ttl = l.jittered(l.params.NegativeTTL)
+	} else if err != nil {
+		return value, err
+	}
+	e.FreshUntil = l.now().Add(ttl).UnixMilli()
+
+	raw, setErr := json.Marshal(e)
+	if setErr == nil {
+		fenced, ok := l.params.Store.(FencedStore)
+		if lock != nil && ok {
+			_, setErr = fenced.SetFenced(ctx, key, string(raw), ttl+l.params.StaleTTL, lock.Token())
+		} else {
+			setErr = l.params.Store.Set(ctx, key, string(raw), ttl+l.params.StaleTTL)
+		}
+	}
+	if setErr != nil {
+		logrus.WithFields(logrus.Fields{
+			"key":   key,
+			"error": setErr.Error(),
+		}).Warn("Loader.load, Store.Set")
+	}
+	return value, err
+}
+
+// jittered returns ttl changed by up to Jitter of it at random
+func (l *Loader[T]) jittered(ttl time.Duration) time.Duration {
+	if l.params.Jitter <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration((rand.Float64()*2-1)*l.params.Jitter*float64(ttl))
+}
@@ -0,0 +1,291 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+// memStore is a Store in memory, its values do not expire
+type memStore struct {
+	mu     sync.Mutex
+	values map[string]string
+	// err fails every call if it is not nil
+	err error
+}
+
+func newMemStore() *memStore {
+	return &memStore{values: map[string]string{}}
+}
+
+func (s *memStore) Get(ctx context.Context, key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return "", false, s.err
+	}
+	value, ok := s.values[key]
+	return value, ok, nil
+}
+
+func (s *memStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.values[key] = value
+	return nil
+}
+
+func TestLoaderCachesValue(t *testing.T) {
+	loader := NewLoader[string](LoaderParams{Store: newMemStore(), TTL: time.Minute})
+	loads := int32(0)
+	load := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&loads, 1)
+		return "v", nil
+	}
+
+	for i := 0; i < 3; i++ {
+		value, err := loader.Get(context.Background(), "k", load)
+		assert.NoError(t, err)
+		assert.Equal(t, "v", value)
+	}
+	assert.Equal(t, int32(1), loads)
+}
+
+func TestLoaderSharesLoad(t *testing.T) {
+	loader := NewLoader[string](LoaderParams{Store: newMemStore(), TTL: time.Minute})
+	loads := int32(0)
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "v", nil
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := loader.Get(context.Background(), "k", load)
+			assert.NoError(t, err)
+			assert.Equal(t, "v", value)
+		}()
+	}
+	// the callers are waiting on the first load
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), loads)
+}
+
+func TestLoaderNegativeCaching(t *testing.T) {
+	loader := NewLoader[string](LoaderParams{Store: newMemStore(), TTL: time.Minute, NegativeTTL: time.Minute})
+	loads := 0
+	load := func(ctx context.Context) (string, error) {
+		loads++
+		return "", ErrNotFound
+	}
+
+	_, err := loader.Get(context.Background(), "k", load)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = loader.Get(context.Background(), "k", load)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 1, loads)
+
+	// an error is not cached
+	failed := errors.New("failed")
+	_, err = loader.Get(context.Background(), "other", func(ctx context.Context) (string, error) {
+		loads++
+		return "", failed
+	})
+	assert.ErrorIs(t, err, failed)
+	_, err = loader.Get(context.Background(), "other", func(ctx context.Context) (string, error) {
+		loads++
+		return "v", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, loads)
+}
+
+func TestLoaderServesStale(t *testing.T) {
+	now := time.Now()
+	loader := NewLoader[string](LoaderParams{Store: newMemStore(), TTL: time.Minute, StaleTTL: time.Minute})
+	loader.now = func() time.Time { return now }
+
+	value, err := loader.Get(context.Background(), "k", func(ctx context.Context) (string, error) {
+		return "old", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "old", value)
+
+	// the stale value is returned at once, and the new one is loaded in the background
+	now = now.Add(2 * time.Minute)
+	reloaded := make(chan struct{})
+	value, err = loader.Get(context.Background(), "k", func(ctx context.Context) (string, error) {
+		defer close(reloaded)
+		return "new", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "old", value)
+
+	<-reloaded
+	assert.Eventually(t, func() bool {
+		value, err := loader.Get(context.Background(), "k", func(ctx context.Context) (string, error) {
+			return "unexpected", nil
+		})
+		return err == nil && value == "new"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestLoaderRefreshFailure(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+	now := time.Now()
+	loader := NewLoader[string](LoaderParams{Store: newMemStore(), TTL: time.Minute, StaleTTL: time.Minute})
+	loader.now = func() time.Time { return now }
+	_, err := loader.Get(context.Background(), "k", func(ctx context.Context) (string, error) {
+		return "old", nil
+	})
+	assert.NoError(t, err)
+
+	// the failure of a refresh in the background is logged, and the stale value is still served
+	now = now.Add(2 * time.Minute)
+	failed := errors.New("failed")
+	value, err := loader.Get(context.Background(), "k", func(ctx context.Context) (string, error) {
+		return "", failed
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "old", value)
+	assert.Eventually(t, func() bool {
+		entry := hook.LastEntry()
+		return entry != nil && entry.Level == logrus.WarnLevel && entry.Message == "Loader.Get, refresh" && entry.Data["error"] == failed.Error()
+	}, time.Second, 10*time.Millisecond)
+
+	value, err = loader.Get(context.Background(), "k", func(ctx context.Context) (string, error) {
+		return "new", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "old", value)
+}
+
+func TestLoaderStoreFailure(t *testing.T) {
+	store := newMemStore()
+	store.err = errors.New("redis is down")
+	loader := NewLoader[string](LoaderParams{Store: store, TTL: time.Minute})
+
+	value, err := loader.Get(context.Background(), "k", func(ctx context.Context) (string, error) {
+		return "v", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "v", value)
+}
+
+func TestLoaderCallerLeaves(t *testing.T) {
+	loader := NewLoader[string](LoaderParams{Store: newMemStore(), TTL: time.Minute})
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		<-release
+		return "v", nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := loader.Get(ctx, "k", load)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// the load goes on, and the next caller gets its value
+	close(release)
+	value, err := loader.Get(context.Background(), "k", load)
+	assert.NoError(t, err)
+	assert.Equal(t, "v", value)
+}
+
+func TestLoaderLockWaitsForValue(t *testing.T) {
+	_, client := newTestRedis(t)
+	store := NewLayered(client, LayeredParams{Capacity: 10, TTL: time.Minute})
+	locker := NewLocker(client, time.Minute)
+	loader := NewLoader[string](LoaderParams{Store: store, TTL: time.Minute, Locker: locker, LockWait: time.Second, LockPoll: 10 * time.Millisecond})
+	ctx := context.Background()
+
+	// another instance is loading the key
+	lock, err := locker.TryAcquire(ctx, "k")
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		other := NewLoader[string](LoaderParams{Store: store, TTL: time.Minute})
+		_, err := other.Get(ctx, "k", func(ctx context.Context) (string, error) {
+			return "other", nil
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, lock.Release(ctx))
+	}()
+
+	value, err := loader.Get(ctx, "k", func(ctx context.Context) (string, error) {
+		return "unexpected", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "other", value)
+}
+
+func TestLoaderLockWaitTimeout(t *testing.T) {
+	_, client := newTestRedis(t)
+	store := NewLayered(client, LayeredParams{Capacity: 10, TTL: time.Minute})
+	locker := NewLocker(client, time.Minute)
+	loader := NewLoader[string](LoaderParams{Store: store, TTL: time.Minute, Locker: locker, LockWait: 50 * time.Millisecond, LockPoll: 10 * time.Millisecond})
+	ctx := context.Background()
+
+	// the other instance never sets the value, so the key is loaded after the wait
+	lock, err := locker.TryAcquire(ctx, "k")
+	assert.NoError(t, err)
+	defer lock.Release(ctx)
+	value, err := loader.Get(ctx, "k", func(ctx context.Context) (string, error) {
+		return "v", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "v", value)
+}
+
+func TestLoaderRefreshSkipped(t *testing.T) {
+	_, client := newTestRedis(t)
+	store := NewLayered(client, LayeredParams{Capacity: 10, TTL: time.Minute})
+	locker := NewLocker(client, time.Minute)
+	now := time.Now()
+	// no LockWait is the default, not a wait for nothing
+	loader := NewLoader[string](LoaderParams{Store: store, TTL: time.Minute, StaleTTL: time.Minute, Locker: locker})
+	assert.Equal(t, defaultLockWait, loader.params.LockWait)
+	loader.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	value, err := loader.Get(ctx, "k", func(ctx context.Context) (string, error) {
+		return "old", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "old", value)
+
+	// another instance is refreshing the key, so the stale value is served without loading it
+	lock, err := locker.TryAcquire(ctx, "k")
+	assert.NoError(t, err)
+	defer lock.Release(ctx)
+	now = now.Add(2 * time.Minute)
+	loads := int32(0)
+	for i := 0; i < 3; i++ {
+		value, err := loader.Get(ctx, "k", func(ctx context.Context) (string, error) {
+			atomic.AddInt32(&loads, 1)
+			return "new", nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "old", value)
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&loads))
+}
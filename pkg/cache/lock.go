@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrLockHeld is returned when another owner holds the lock
+var ErrLockHeld = errors.New("cache: lock is held")
+
+// fenceTTL is how long the token counter of a lock is kept after the last owner acquired it. The counter starts
+// over once it expires, so it must outlive the values written with its tokens, which are kept for minutes.
+const fenceTTL = 24 * time.Hour
+
+// acquireScript sets the owner if the lock is free, and returns the next fencing token of the lock, or 0 if it is held.
+// The token counter outlives the lock by fenceTTL, so that a later owner always gets a greater token.
+var acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	local token = redis.call("INCR", KEYS[2])
+	redis.call("PEXPIRE", KEYS[2], ARGV[3])
+	return token
+end
+return 0`)
+
+// renewScript extends the lock if it is still of the owner
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// releaseScript deletes the lock if it is still of the owner
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// Locker acquires distributed locks in redis. A lock expires after its TTL unless it is renewed, which its owner
+// does in the background, so that a crashed owner does not hold it forever.
+type Locker struct {
+	client redis.UniversalClient
+	ttl    time.Duration
+}
+
+// NewLocker creates a Locker of which the locks expire after ttl without being renewed
+func NewLocker(client redis.UniversalClient, ttl time.Duration) *Locker {
+	return &Locker{client: client, ttl: ttl}
+}
+
+// lockKeys returns the keys of the lock and its token counter, the hash tag keeps them in one slot of a cluster
+func lockKeys(name string) []string {
+	return []string{"lock:{" + name + "}", "lock:{" + name + "}:fence"}
+}
+
+// TryAcquire acquires the lock of the name, or returns ErrLockHeld at once if another owner holds it
+func (l *Locker) TryAcquire(ctx context.Context, name string) (*Lock, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	owner := hex.EncodeToString(b)
+	token, err := acquireScript.Run(ctx, l.client, lockKeys(name), owner, l.ttl.Milliseconds(), fenceTTL.Milliseconds()).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if token == 0 {
+		return nil, ErrLockHeld
+	}
+
+	lock := &Lock{
+		locker: l,
+		name:   name,
+		owner:  owner,
+		token:  token,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		lost:   make(chan struct{}),
+	}
+	go lock.renew()
+	return lock, nil
+}
+
+// Acquire waits for the lock of the name, trying every interval until ctx is done
+func (l *Locker) Acquire(ctx context.Context, name string, interval time.Duration) (*Lock, error) {
+	for {
+		lock, err := l.TryAcquire(ctx, name)
+		if !errors.Is(err, ErrLockHeld) {
+			return lock, err
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Lock is a distributed lock held by this process
+type Lock struct {
+	locker *Locker
+	name   string
+	owner  string
+	token  int64
+
+	stop     chan struct{}
+	done     chan struct{}
+	lost     chan struct{}
+	stopOnce sync.Once
+}
+
+// Token returns the fencing token of the lock, it is greater than the token of every earlier owner. A resource
+// protected by the lock rejects the writes with a token smaller than one it has seen, so that an owner which has
+// lost the lock without knowing it cannot overwrite the writes of the next owner.
+func (lock *Lock) Token() int64 {
+	return lock.token
+}
+
+// Lost is closed when the lock could not be renewed, the owner should stop the work it protects
+func (lock *Lock) Lost() <-chan struct{} {
+	return lock.lost
+}
+
+// renew extends the lock every third of its TTL until it is released or lost
+func (lock *Lock) renew() {
+	defer close(lock.done)
+	interval := lock.locker.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lock.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			renewed, err := renewScript.Run(ctx, lock.locker.client, lockKeys(lock.name)[:1], lock.owner, lock.locker.ttl.Milliseconds()).Int64()
+			cancel()
+			// a failed round trip is retried, the lock is lost only when it is of another owner or has expired
+			if err == nil && renewed == 0 {
+				close(lock.lost)
+				return
+			}
+		}
+	}
+}
+
+// Release stops renewing the lock and releases it, a lock already lost is not released from its new owner
+func (lock *Lock) Release(ctx context.Context) error {
+	lock.stopOnce.Do(func() { close(lock.stop) })
+	<-lock.done
+	return releaseScript.Run(ctx, lock.locker.client, lockKeys(lock.name)[:1], lock.owner).Err()
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRedis starts a redis in memory and returns a client of it
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return server, client
+}
+
+func TestLockerTryAcquire(t *testing.T) {
+	server, client := newTestRedis(t)
+	locker := NewLocker(client, time.Minute)
+	ctx := context.Background()
+
+	lock, err := locker.TryAcquire(ctx, "k")
+	assert.NoError(t, err)
+	_, err = locker.TryAcquire(ctx, "k")
+	assert.ErrorIs(t, err, ErrLockHeld)
+	// the locks of the names are apart
+	other, err := locker.TryAcquire(ctx, "other")
+	assert.NoError(t, err)
+	assert.NoError(t, other.Release(ctx))
+
+	// a later owner gets a greater token, and the counter expires
+	assert.NoError(t, lock.Release(ctx))
+	next, err := locker.TryAcquire(ctx, "k")
+	assert.NoError(t, err)
+	assert.Greater(t, next.Token(), lock.Token())
+	assert.Equal(t, fenceTTL, server.TTL(lockKeys("k")[1]))
+	assert.NoError(t, next.Release(ctx))
+}
+
+func TestLockerAcquire(t *testing.T) {
+	_, client := newTestRedis(t)
+	locker := NewLocker(client, time.Minute)
+	ctx := context.Background()
+
+	lock, err := locker.TryAcquire(ctx, "k")
+	assert.NoError(t, err)
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = locker.Acquire(timeout, "k", 10*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the waiter gets the lock once it is released
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		assert.NoError(t, lock.Release(ctx))
+	}()
+	next, err := locker.Acquire(ctx, "k", 10*time.Millisecond)
+	assert.NoError(t, err)
+	assert.NoError(t, next.Release(ctx))
+}
+
+func TestLockRenew(t *testing.T) {
+	server, client := newTestRedis(t)
+	locker := NewLocker(client, 300*time.Millisecond)
+	ctx := context.Background()
+	key := lockKeys("k")[0]
+
+	lock, err := locker.TryAcquire(ctx, "k")
+	assert.NoError(t, err)
+	server.FastForward(200 * time.Millisecond)
+	// the owner extends the lock before it expires
+	assert.Eventually(t, func() bool {
+		return server.TTL(key) == 300*time.Millisecond
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, lock.Release(ctx))
+	assert.False(t, server.Exists(key))
+}
+
+func TestLockLost(t *testing.T) {
+	server, client := newTestRedis(t)
+	locker := NewLocker(client, 30*time.Millisecond)
+	ctx := context.Background()
+	key := lockKeys("k")[0]
+
+	lock, err := locker.TryAcquire(ctx, "k")
+	assert.NoError(t, err)
+	// the lock expired, and another owner took it
+	assert.NoError(t, server.Set(key, "other"))
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("the lock is not lost")
+	}
+
+	// the lock of the new owner is not released
+	assert.NoError(t, lock.Release(ctx))
+	value, err := server.Get(key)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", value)
+}
+
+func TestLayeredSetFenced(t *testing.T) {
+	_, client := newTestRedis(t)
+	layered := NewLayered(client, LayeredParams{Capacity: 10, TTL: time.Minute})
+	ctx := context.Background()
+
+	applied, err := layered.SetFenced(ctx, "k", "new", time.Minute, 2)
+	assert.NoError(t, err)
+	assert.True(t, applied)
+	// an older owner does not overwrite the value of a newer one, even after the value is deleted
+	applied, err = layered.SetFenced(ctx, "k", "old", time.Minute, 1)
+	assert.NoError(t, err)
+	assert.False(t, applied)
+	value, _, err := layered.Get(ctx, "k")
+	assert.NoError(t, err)
+	assert.Equal(t, "new", value)
+
+	assert.NoError(t, layered.Del(ctx, "k"))
+	applied, err = layered.SetFenced(ctx, "k", "old", time.Minute, 1)
+	assert.NoError(t, err)
+	assert.False(t, applied)
+	applied, err = layered.SetFenced(ctx, "k", "newer", time.Minute, 3)
+	assert.NoError(t, err)
+	assert.True(t, applied)
+}
@@ -0,0 +1,77 @@
+package lru
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	c := New[int](2, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	// a is used, so b is the least recently used
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	c.Set("c", 3)
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	v, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, v)
+	assert.Equal(t, 2, c.Len())
+
+	// setting an existing key does not evict
+	c.Set("a", 10)
+	v, ok = c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 10, v)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestExpire(t *testing.T) {
+	now := time.Now()
+	c := New[string](10, time.Minute)
+	c.now = func() time.Time { return now }
+	c.Set("a", "1")
+	c.SetWithTTL("b", "2", 10*time.Second)
+	// the TTL of the cache bounds the given one
+	c.SetWithTTL("c", "3", time.Hour)
+
+	now = now.Add(10 * time.Second)
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(50 * time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	_, ok = c.Get("c")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestDelete(t *testing.T) {
+	c := New[int](10, time.Minute)
+	c.Set("user:1", 1)
+	c.Set("user:2", 2)
+	c.Set("product:1", 3)
+
+	c.Delete("user:1", "unknown")
+	_, ok := c.Get("user:1")
+	assert.False(t, ok)
+
+	c.DeleteFunc(func(key string) bool { return strings.HasPrefix(key, "user:") })
+	_, ok = c.Get("user:2")
+	assert.False(t, ok)
+	_, ok = c.Get("product:1")
+	assert.True(t, ok)
+
+	c.Purge()
+	assert.Equal(t, 0, c.Len())
+}
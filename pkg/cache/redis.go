@@ -2,24 +2,28 @@ package cache
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
-	r "github.com/zeromicro/go-zero/core/stores/redis"
 
 	"github.com/Yu-Qi/GoAuth/pkg/config"
 )
 
-// Client .
-var Client *redis.Client
-
-// LockClient .
-var LockClient *r.Redis
+// modes of REDIS_MODE
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+)
 
-// RedisOptions connection options used by redis
-var RedisOptions *redis.Options
+// Client is the redis client of every mode, it is set by Init
+var Client redis.UniversalClient
 
 // ErrorRedisNil .
 var ErrorRedisNil = "redis: nil"
@@ -27,23 +31,127 @@ var ErrorRedisNil = "redis: nil"
 // ErrorRedisZsetEmpty .
 var ErrorRedisZsetEmpty = "zset is empty"
 
-func init() {
-	redisHost := config.GetString("REDIS_HOST")
-	redisPort := config.GetString("REDIS_PORT")
+// Config is the connection config of redis
+type Config struct {
+	// Mode is ModeStandalone if empty
+	Mode string
+	// Addrs are the address of the node, the addresses of the sentinels, or the seed nodes of the cluster
+	Addrs []string
+	// MasterName is the name of the master monitored by the sentinels
+	MasterName string
+	// Username is the ACL user, empty for the default user
+	Username string
+	Password string
+	// SentinelUsername and SentinelPassword authenticate to the sentinels, which may differ from the master
+	SentinelUsername string
+	SentinelPassword string
+	// TLS connects with TLS if it is not nil
+	TLS *tls.Config
+}
+
+// ConfigFromEnv reads the Config of the REDIS_* env vars. REDIS_ADDRS is a comma separated list of host:port,
+// REDIS_HOST and REDIS_PORT are the address if it is empty.
+func ConfigFromEnv() (*Config, error) {
+	cfg := &Config{
+		Mode:             config.GetString("REDIS_MODE"),
+		MasterName:       config.GetString("REDIS_MASTER_NAME"),
+		Username:         config.GetString("REDIS_USERNAME"),
+		Password:         config.GetString("REDIS_AUTH"),
+		SentinelUsername: config.GetString("REDIS_SENTINEL_USERNAME"),
+		SentinelPassword: config.GetString("REDIS_SENTINEL_AUTH"),
+	}
+	for _, addr := range strings.Split(config.GetString("REDIS_ADDRS"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			cfg.Addrs = append(cfg.Addrs, addr)
+		}
+	}
+	if len(cfg.Addrs) == 0 {
+		host, port := config.GetString("REDIS_HOST"), config.GetString("REDIS_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("missing redis config, set REDIS_ADDRS or REDIS_HOST and REDIS_PORT")
+		}
+		cfg.Addrs = []string{host + ":" + port}
+	}
 
-	if redisHost != "" && redisPort != "" {
-		password := config.GetString("REDIS_AUTH")
+	if config.GetBool("REDIS_TLS") {
+		cfg.TLS = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: config.GetString("REDIS_TLS_SERVER_NAME"),
+		}
+		if caFile := config.GetString("REDIS_TLS_CA_FILE"); caFile != "" {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("read REDIS_TLS_CA_FILE: %w", err)
+			}
+			cfg.TLS.RootCAs = x509.NewCertPool()
+			if !cfg.TLS.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate in REDIS_TLS_CA_FILE %s", caFile)
+			}
+		}
+	}
+	return cfg, nil
+}
 
-		RedisOptions = &redis.Options{
-			Addr:     redisHost + ":" + redisPort,
-			Password: password,
+// NewClient creates the client of the mode of the config
+func NewClient(cfg *Config) (redis.UniversalClient, error) {
+	if len(cfg.Addrs) == 0 {
+		return nil, fmt.Errorf("missing redis addrs")
+	}
+	switch cfg.Mode {
+	case "", ModeStandalone:
+		if len(cfg.Addrs) > 1 {
+			return nil, fmt.Errorf("redis mode %s takes one addr, got %d", ModeStandalone, len(cfg.Addrs))
+		}
+		return redis.NewClient(&redis.Options{
+			Addr:      cfg.Addrs[0],
+			Username:  cfg.Username,
+			Password:  cfg.Password,
+			TLSConfig: cfg.TLS,
+		}), nil
+	case ModeSentinel:
+		if cfg.MasterName == "" {
+			return nil, fmt.Errorf("redis mode %s needs a master name", ModeSentinel)
 		}
-		Client = redis.NewClient(RedisOptions)
-		LockClient = r.New(redisHost+":"+redisPort, r.WithPass(password))
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.Addrs,
+			SentinelUsername: cfg.SentinelUsername,
+			SentinelPassword: cfg.SentinelPassword,
+			Username:         cfg.Username,
+			Password:         cfg.Password,
+			TLSConfig:        cfg.TLS,
+		}), nil
+	case ModeCluster:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     cfg.Addrs,
+			Username:  cfg.Username,
+			Password:  cfg.Password,
+			TLSConfig: cfg.TLS,
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown redis mode %s", cfg.Mode)
+}
 
-		return
+// Init creates the Client of the config and checks that redis is reachable
+func Init(ctx context.Context, cfg *Config) error {
+	client, err := NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return fmt.Errorf("ping redis: %w", err)
 	}
-	panic("missing redis config")
+	Client = client
+	return nil
+}
+
+// Close closes the Client
+func Close() error {
+	if Client == nil {
+		return nil
+	}
+	return Client.Close()
 }
 
 // Exists check if key exists
@@ -112,3 +220,57 @@ func SetWithObject(ctx context.Context, key string, value interface{}, duration
 
 	return (*cmd).Err()
 }
+
+// Del deletes keys from redis, the keys may be in different slots of a cluster
+func Del(ctx context.Context, keys ...string) error {
+	if Client == nil {
+		panic("redis client is nil")
+	}
+	return del(ctx, Client, keys)
+}
+
+// del deletes the keys one by one in a pipeline, a DEL of many keys fails in a cluster unless they are in one slot
+func del(ctx context.Context, client redis.UniversalClient, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	pipe := client.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DelByPattern deletes the keys matching pattern, it scans the keys of every master so it is for rare operations
+func DelByPattern(ctx context.Context, pattern string) error {
+	if Client == nil {
+		panic("redis client is nil")
+	}
+	if cluster, ok := Client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return delByPattern(ctx, node, pattern)
+		})
+	}
+	return delByPattern(ctx, Client, pattern)
+}
+
+// delByPattern deletes the keys of the node matching pattern, the keys are deleted through Client which routes
+// them to their slots
+func delByPattern(ctx context.Context, node redis.UniversalClient, pattern string) error {
+	iter := node.Scan(ctx, 0, pattern, 100).Iterator()
+	keys := []string{}
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 100 {
+			if err := del(ctx, Client, keys); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	return del(ctx, Client, keys)
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("REDIS_HOST", "127.0.0.1")
+	t.Setenv("REDIS_PORT", "6379")
+	t.Setenv("REDIS_ADDRS", "")
+	t.Setenv("REDIS_USERNAME", "app")
+	t.Setenv("REDIS_TLS", "")
+	cfg, err := ConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:6379"}, cfg.Addrs)
+	assert.Equal(t, "app", cfg.Username)
+	assert.Nil(t, cfg.TLS)
+
+	// REDIS_ADDRS is preferred to REDIS_HOST and REDIS_PORT
+	t.Setenv("REDIS_MODE", ModeSentinel)
+	t.Setenv("REDIS_ADDRS", "s1:26379, s2:26379,")
+	t.Setenv("REDIS_TLS", "true")
+	t.Setenv("REDIS_TLS_SERVER_NAME", "redis.internal")
+	cfg, err = ConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, ModeSentinel, cfg.Mode)
+	assert.Equal(t, []string{"s1:26379", "s2:26379"}, cfg.Addrs)
+	assert.Equal(t, "redis.internal", cfg.TLS.ServerName)
+
+	t.Setenv("REDIS_TLS_CA_FILE", "/nonexistent/ca.pem")
+	_, err = ConfigFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("REDIS_ADDRS", "")
+	t.Setenv("REDIS_HOST", "")
+	_, err = ConfigFromEnv()
+	assert.Error(t, err)
+}
+
+func TestNewClient(t *testing.T) {
+	client, err := NewClient(&Config{Addrs: []string{"127.0.0.1:6379"}})
+	assert.NoError(t, err)
+	assert.IsType(t, &redis.Client{}, client)
+	_ = client.Close()
+
+	client, err = NewClient(&Config{Mode: ModeCluster, Addrs: []string{"n1:6379", "n2:6379"}})
+	assert.NoError(t, err)
+	assert.IsType(t, &redis.ClusterClient{}, client)
+	_ = client.Close()
+
+	client, err = NewClient(&Config{Mode: ModeSentinel, Addrs: []string{"s1:26379"}, MasterName: "mymaster"})
+	assert.NoError(t, err)
+	_ = client.Close()
+
+	for _, cfg := range []*Config{
+		{},
+		{Mode: ModeStandalone, Addrs: []string{"n1:6379", "n2:6379"}},
+		{Mode: ModeSentinel, Addrs: []string{"s1:26379"}},
+		{Mode: "unknown", Addrs: []string{"n1:6379"}},
+	} {
+		_, err := NewClient(cfg)
+		assert.Error(t, err, cfg)
+	}
+}
@@ -18,12 +18,25 @@ const (
 	JsonMarshalError = 1007
 	JsonUnmarshalErr = 1008
 	TokenExpired     = 1009
+	SessionRevoked   = 1010
+	ServiceBusy      = 1011
+	TooManyRequests  = 1012
+	PermissionDenied = 1013
 	// business errors
 	AccountAlreadyExists       = 2000
 	AccountOrPasswordIncorrect = 2001
 	AccountNotActive           = 2002
 	AccountAlreadyActive       = 2003
 	SendEmailError             = 2004
+	SessionNotFound            = 2005
+	PasswordResetRequired      = 2006
+	PasswordReused             = 2007
+	OTPIncorrect               = 2008
+	PhoneAlreadyUsed           = 2009
+	SendSMSError               = 2010
+	ProductNotFound            = 2011
+	ExperimentNotFound         = 2012
+	OutOfStock                 = 2013
 	// internal errors
 	CryptoError          = 3000
 	InternalUnknownError = 3999
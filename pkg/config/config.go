@@ -24,3 +24,16 @@ func GetInt(key string) int {
 	}
 	return val
 }
+
+// GetBool . An unset key is false
+func GetBool(key string) bool {
+	val := os.Getenv(key)
+	if val == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
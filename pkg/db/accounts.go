@@ -4,28 +4,54 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/Yu-Qi/GoAuth/domain"
 	"github.com/Yu-Qi/GoAuth/pkg/code"
 	"github.com/Yu-Qi/GoAuth/pkg/db/model"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
-// CreateAccountParams is the parameters for creating an account
-type CreateAccountParams struct {
-	UID            string
-	Email          string
-	HashedPassword string
+// AccountRepository is the domain.AccountRepository of the database
+type AccountRepository struct{}
+
+var _ domain.AccountRepository = (*AccountRepository)(nil)
+
+// NewAccountRepository creates an AccountRepository
+func NewAccountRepository() *AccountRepository {
+	return &AccountRepository{}
+}
+
+func toDomainAccount(account *model.Account) *domain.Account {
+	return &domain.Account{
+		UID:                   account.UID,
+		Email:                 account.Email,
+		HashedPassword:        account.HashedPassword,
+		IsActive:              account.IsActive,
+		SentAt:                account.SentAt,
+		PasswordResetRequired: account.PasswordResetRequired,
+		Locale:                account.Locale,
+		Phone:                 util.Deref(account.Phone),
+		IsAdmin:               account.IsAdmin,
+	}
 }
 
 // CreateAccount creates a new account
-func CreateAccount(ctx context.Context, params *CreateAccountParams) *code.CustomError {
-	err := GetWith(ctx).Create(&model.Account{
-		UID:            params.UID,
-		Email:          params.Email,
-		HashedPassword: params.HashedPassword,
-	}).Error
+func (r *AccountRepository) CreateAccount(ctx context.Context, params *domain.CreateAccountParams) *code.CustomError {
+	err := GetWith(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&model.Account{
+			UID:            params.UID,
+			Email:          params.Email,
+			HashedPassword: params.HashedPassword,
+			Locale:         params.Locale,
+		}).Error
+		if err != nil || params.VerificationEmail == nil {
+			return err
+		}
+		return enqueueEmail(tx, params.VerificationEmail, params.UID)
+	})
 	if IsDuplicateEntryError(err) {
 		return code.NewCustomError(code.AccountAlreadyExists, http.StatusBadRequest, err)
 	} else if err != nil {
@@ -35,30 +61,70 @@ func CreateAccount(ctx context.Context, params *CreateAccountParams) *code.Custo
 	return nil
 }
 
-// Login login an active account
-func Login(ctx context.Context, params *domain.Account) (*domain.Account, *code.CustomError) {
-	// check if account already exists
+// GetAccountByEmail gets an account by email
+func (r *AccountRepository) GetAccountByEmail(ctx context.Context, email string) (*domain.Account, *code.CustomError) {
 	account := &model.Account{}
 	err := GetWith(ctx).
-		Where("email = ?", params.Email).
+		Where("email = ?", email).
 		First(account).Error
+	if IsRecordNotFoundError(err) {
+		return nil, code.NewCustomError(code.UserNotFound, http.StatusBadRequest, err)
+	} else if err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return toDomainAccount(account), nil
+}
 
+// GetAccount gets an account by uid
+func (r *AccountRepository) GetAccount(ctx context.Context, uid string) (*domain.Account, *code.CustomError) {
+	account := &model.Account{}
+	err := GetWith(ctx).
+		Where("uid = ?", uid).
+		First(account).Error
 	if IsRecordNotFoundError(err) {
-		return nil, code.NewCustomError(code.AccountOrPasswordIncorrect, http.StatusBadRequest, fmt.Errorf("account or password incorrect"))
+		return nil, code.NewCustomError(code.UserNotFound, http.StatusBadRequest, err)
 	} else if err != nil {
 		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
 	}
+	return toDomainAccount(account), nil
+}
 
-	return &domain.Account{
-		UID:            account.UID,
-		Email:          account.Email,
-		HashedPassword: account.HashedPassword,
-		IsActive:       account.IsActive,
-	}, nil
+// GetAccountByPhone gets the account of a verified phone number
+func (r *AccountRepository) GetAccountByPhone(ctx context.Context, phone string) (*domain.Account, *code.CustomError) {
+	account := &model.Account{}
+	err := GetWith(ctx).
+		Where("phone = ?", phone).
+		First(account).Error
+	if IsRecordNotFoundError(err) {
+		return nil, code.NewCustomError(code.UserNotFound, http.StatusBadRequest, err)
+	} else if err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return toDomainAccount(account), nil
+}
+
+// SetPhone sets the verified phone number of an account
+func (r *AccountRepository) SetPhone(ctx context.Context, uid, phone string) *code.CustomError {
+	query := GetWith(ctx).
+		Model(&model.Account{}).
+		Where("uid = ?", uid).
+		Update("phone", phone)
+	if IsDuplicateEntryError(query.Error) {
+		return code.NewCustomError(code.PhoneAlreadyUsed, http.StatusBadRequest, fmt.Errorf("phone is used by another account"))
+	} else if query.Error != nil {
+		return code.NewCustomError(code.DBError, http.StatusInternalServerError, query.Error)
+	}
+	// mysql counts changed rows instead of matched rows, so setting the same phone again affects none
+	if query.RowsAffected == 0 {
+		if _, customErr := r.GetAccount(ctx, uid); customErr != nil {
+			return customErr
+		}
+	}
+	return nil
 }
 
-// ActiveAccount activates an account
-func ActiveAccount(ctx context.Context, uid string) *code.CustomError {
+// ActivateAccount activates an account
+func (r *AccountRepository) ActivateAccount(ctx context.Context, uid string) *code.CustomError {
 	httpStatus := http.StatusInternalServerError
 	errCode := code.DBError
 	err := GetWith(ctx).Transaction(func(tx *gorm.DB) error {
@@ -72,12 +138,13 @@ func ActiveAccount(ctx context.Context, uid string) *code.CustomError {
 			return err
 		}
 
-		query := GetWith(ctx).
+		// mysql counts changed rows and the other drivers count matched rows, the condition on is_active makes them agree
+		query := tx.
 			Model(&model.Account{}).
-			Where("uid = ?", uid).
+			Where("uid = ? AND is_active = ?", uid, false).
 			Update("is_active", true)
 		if query.Error != nil {
-			return err
+			return query.Error
 		}
 		if query.RowsAffected == 0 {
 			httpStatus = http.StatusBadRequest
@@ -95,7 +162,7 @@ func ActiveAccount(ctx context.Context, uid string) *code.CustomError {
 }
 
 // UserExists checks if a user exists and is active
-func UserExists(ctx context.Context, uid string) *code.CustomError {
+func (r *AccountRepository) UserExists(ctx context.Context, uid string) *code.CustomError {
 	account := &model.Account{}
 	err := GetWith(ctx).
 		Where("uid = ?", uid).
@@ -113,7 +180,7 @@ func UserExists(ctx context.Context, uid string) *code.CustomError {
 }
 
 // UpdateAccount updates an account
-func UpdateAccount(ctx context.Context, uid string, params *domain.UpdateAccountParams) *code.CustomError {
+func (r *AccountRepository) UpdateAccount(ctx context.Context, uid string, params *domain.UpdateAccountParams) *code.CustomError {
 	httpStatus := http.StatusInternalServerError
 	errCode := code.DBError
 	err := GetWith(ctx).Transaction(func(tx *gorm.DB) error {
@@ -129,11 +196,21 @@ func UpdateAccount(ctx context.Context, uid string, params *domain.UpdateAccount
 			return err
 		}
 		// check params
+		updates := map[string]interface{}{}
+		if params.HashedPassword != nil {
+			updates["hashed_password"] = *params.HashedPassword
+		}
 		if params.SentAt != nil {
-			account.SentAt = params.SentAt
+			updates["sent_at"] = params.SentAt
 		}
-		// update account
-		err = tx.Updates(&account).Error
+		if params.PasswordResetRequired != nil {
+			updates["password_reset_required"] = *params.PasswordResetRequired
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		// update account, a map is used so that false can be written
+		err = tx.Model(&account).Updates(updates).Error
 		if err != nil {
 			return err
 		}
@@ -144,3 +221,38 @@ func UpdateAccount(ctx context.Context, uid string, params *domain.UpdateAccount
 	}
 	return nil
 }
+
+// CreateSession records a login session of an account
+func (r *AccountRepository) CreateSession(ctx context.Context, params *domain.CreateSessionParams) *code.CustomError {
+	return CreateSession(ctx, params)
+}
+
+// GetSession gets a session by jti
+func (r *AccountRepository) GetSession(ctx context.Context, jti string) (*domain.Session, *code.CustomError) {
+	return GetSession(ctx, jti)
+}
+
+// ListSessions lists the sessions of an account which are not revoked, the latest active one first
+func (r *AccountRepository) ListSessions(ctx context.Context, uid string) ([]domain.Session, *code.CustomError) {
+	return ListSessions(ctx, uid)
+}
+
+// ListRecentSessions lists the latest sessions of an account including the revoked ones, the latest created first
+func (r *AccountRepository) ListRecentSessions(ctx context.Context, uid string, limit int) ([]domain.Session, *code.CustomError) {
+	return ListRecentSessions(ctx, uid, limit)
+}
+
+// TouchSession sets the last seen time of a session unless it has been set since notAfter
+func (r *AccountRepository) TouchSession(ctx context.Context, jti string, seenAt, notAfter time.Time) *code.CustomError {
+	return TouchSession(ctx, jti, seenAt, notAfter)
+}
+
+// RevokeSession revokes a session of an account
+func (r *AccountRepository) RevokeSession(ctx context.Context, uid, jti string) *code.CustomError {
+	return RevokeSession(ctx, uid, jti)
+}
+
+// RevokeOtherSessions revokes the sessions of an account except exceptJTI, empty for every session
+func (r *AccountRepository) RevokeOtherSessions(ctx context.Context, uid, exceptJTI string) (int64, *code.CustomError) {
+	return RevokeOtherSessions(ctx, uid, exceptJTI)
+}
@@ -8,14 +8,23 @@ import (
 	"sync"
 	"time"
 
+	"github.com/glebarez/sqlite"
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
 	"github.com/Yu-Qi/GoAuth/pkg/config"
 )
 
+// drivers of DB_DRIVER
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 var database *gorm.DB
 var initdatabaseOnce sync.Once
 
@@ -36,19 +45,53 @@ func Init() {
 	initdatabaseOnce.Do(initialize)
 }
 
+// Driver returns the driver of DB_DRIVER, mysql when it is not set
+func Driver() string {
+	if driver := config.GetString("DB_DRIVER"); driver != "" {
+		return driver
+	}
+	return DriverMySQL
+}
+
+// dialector returns the dialector of the driver, configured by the env vars of the driver
+func dialector(driver string) (gorm.Dialector, error) {
+	switch driver {
+	case DriverMySQL:
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?%s",
+			config.GetString("MYSQL_USERNAME"),
+			config.GetString("MYSQL_PASSWORD"),
+			config.GetString("MYSQL_HOST"),
+			config.GetString("MYSQL_PORT"),
+			config.GetString("MYSQL_DATABASE"),
+			config.GetString("MYSQL_OPTIONS"))
+		return mysql.Open(dsn), nil
+	case DriverPostgres:
+		dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s %s",
+			config.GetString("POSTGRES_HOST"),
+			config.GetString("POSTGRES_PORT"),
+			config.GetString("POSTGRES_USERNAME"),
+			config.GetString("POSTGRES_PASSWORD"),
+			config.GetString("POSTGRES_DATABASE"),
+			config.GetString("POSTGRES_OPTIONS"))
+		return postgres.Open(dsn), nil
+	case DriverSQLite:
+		return sqlite.Open(config.GetString("SQLITE_PATH")), nil
+	}
+	return nil, fmt.Errorf("unknown DB_DRIVER %s", driver)
+}
+
 // initialize will create a new database sesssion. If we are in an CI environment, a random table name will be used.
 func initialize() {
-	var err error
-	username := config.GetString("MYSQL_USERNAME")
-	password := config.GetString("MYSQL_PASSWORD")
-	host := config.GetString("MYSQL_HOST")
-	port := config.GetString("MYSQL_PORT")
-	options := config.GetString("MYSQL_OPTIONS")
-	databaseName := config.GetString("MYSQL_DATABASE")
-	slowThreshold := time.Duration(config.GetInt("MYSQL_SLOW_THRESHOLD")) * time.Millisecond
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?%s", username, password, host, port, databaseName, options)
+	driver := Driver()
+	dialector, err := dialector(driver)
+	if err != nil {
+		logrus.Fatalf("failed to connect database: %v", err)
+	}
+	slowThreshold := time.Duration(config.GetInt("DB_SLOW_THRESHOLD")) * time.Millisecond
 
-	database, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
+	database, err = gorm.Open(dialector, &gorm.Config{
+		// driver errors such as duplicate keys are translated to the errors of gorm, see IsDuplicateEntryError
+		TranslateError: true,
 		Logger: logger.New(
 			log.New(os.Stdout, "\r\n", log.LstdFlags),
 			logger.Config{
@@ -63,4 +106,12 @@ func initialize() {
 		logrus.Fatalf("failed to connect database: %v", err)
 	}
 
+	if driver == DriverSQLite {
+		// sqlite allows one writer at a time, and every connection to :memory: is a new database
+		sqlDB, err := database.DB()
+		if err != nil {
+			logrus.Fatalf("failed to connect database: %v", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
 }
@@ -0,0 +1,267 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/db/migrations"
+	"github.com/Yu-Qi/GoAuth/pkg/db/model"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
+)
+
+// TestMain runs the tests on an in-memory sqlite database, so that no database server is needed
+func TestMain(m *testing.M) {
+	os.Setenv("DB_DRIVER", DriverSQLite)
+	os.Setenv("SQLITE_PATH", ":memory:")
+	os.Setenv("DB_SLOW_THRESHOLD", "1000")
+	if _, err := migrations.NewRunner(Get(), migrations.All()).Up(context.Background()); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestAccountRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewAccountRepository()
+	uid := util.UUID()
+	address := util.RandEmail()
+
+	assert.Nil(t, repo.CreateAccount(ctx, &domain.CreateAccountParams{UID: uid, Email: address, HashedPassword: "hash", Locale: "en"}))
+	// the duplicate key error of the driver is classified without knowing the driver
+	customErr := repo.CreateAccount(ctx, &domain.CreateAccountParams{UID: util.UUID(), Email: address, HashedPassword: "hash"})
+	assert.Equal(t, code.AccountAlreadyExists, customErr.Code)
+
+	account, customErr := repo.GetAccountByEmail(ctx, address)
+	assert.Nil(t, customErr)
+	assert.Equal(t, uid, account.UID)
+	assert.False(t, account.IsActive)
+	_, customErr = repo.GetAccountByEmail(ctx, util.RandEmail())
+	assert.Equal(t, code.UserNotFound, customErr.Code)
+
+	assert.Nil(t, repo.ActivateAccount(ctx, uid))
+	assert.Equal(t, code.AccountAlreadyActive, repo.ActivateAccount(ctx, uid).Code)
+	assert.Nil(t, repo.UserExists(ctx, uid))
+
+	assert.Nil(t, repo.SetPhone(ctx, uid, "+886912345678"))
+	// setting the same phone again changes no row
+	assert.Nil(t, repo.SetPhone(ctx, uid, "+886912345678"))
+	assert.Equal(t, code.UserNotFound, repo.SetPhone(ctx, util.UUID(), "+886900000000").Code)
+
+	other := util.UUID()
+	assert.Nil(t, repo.CreateAccount(ctx, &domain.CreateAccountParams{UID: other, Email: util.RandEmail(), HashedPassword: "hash"}))
+	assert.Equal(t, code.PhoneAlreadyUsed, repo.SetPhone(ctx, other, "+886912345678").Code)
+
+	account, customErr = repo.GetAccountByPhone(ctx, "+886912345678")
+	assert.Nil(t, customErr)
+	assert.Equal(t, uid, account.UID)
+}
+
+func TestPasswordHistory(t *testing.T) {
+	ctx := context.Background()
+	repo := NewAccountRepository()
+	uid := util.UUID()
+	assert.Nil(t, repo.CreateAccount(ctx, &domain.CreateAccountParams{UID: uid, Email: util.RandEmail(), HashedPassword: "hash-1"}))
+
+	for _, hash := range []string{"hash-2", "hash-3", "hash-4"} {
+		assert.Nil(t, repo.ChangePassword(ctx, &domain.ChangePasswordParams{UID: uid, HashedPassword: hash, HistoryDepth: 2}))
+	}
+	history, customErr := repo.ListPasswordHistory(ctx, uid, 10)
+	assert.Nil(t, customErr)
+	assert.Equal(t, []string{"hash-3", "hash-2"}, history)
+}
+
+func TestClaimEmails(t *testing.T) {
+	ctx := context.Background()
+	msg := &domain.EmailMessage{ID: "test/" + util.UUID(), To: util.RandEmail(), Subject: "subject", Text: "code 123456"}
+	assert.Nil(t, EnqueueEmail(ctx, msg, ""))
+	// an email with the same ID is ignored
+	assert.Nil(t, EnqueueEmail(ctx, msg, ""))
+
+	emails, customErr := ClaimEmails(ctx, 10, time.Minute)
+	assert.Nil(t, customErr)
+	assert.Len(t, emails, 1)
+	assert.Equal(t, msg.To, emails[0].Message.To)
+	assert.Equal(t, 1, emails[0].Attempts)
+
+	// the claimed email is leased
+	leased, customErr := ClaimEmails(ctx, 10, time.Minute)
+	assert.Nil(t, customErr)
+	assert.Len(t, leased, 0)
+
+	assert.Nil(t, MarkEmailSent(ctx, emails[0]))
+	row := &model.EmailOutbox{}
+	assert.Nil(t, Get().First(row, emails[0].ID).Error)
+	assert.Equal(t, model.EmailOutboxSent, row.Status)
+	// the content is dropped once sent
+	assert.NotContains(t, row.Message, "123456")
+	assert.Contains(t, row.Message, msg.To)
+}
+
+func TestProductRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewProductRepository()
+	category := "test-" + util.RandString(8)
+
+	created := []*domain.Product{}
+	for i, stock := range []int{3, 0, 5} {
+		product, customErr := repo.CreateProduct(ctx, &domain.CreateProductParams{
+			Name:     fmt.Sprintf("product %d", i),
+			Price:    int64(100 * (i + 1)),
+			Category: category,
+			Stock:    stock,
+		})
+		assert.Nil(t, customErr)
+		assert.Equal(t, domain.ProductStatusActive, product.Status)
+		assert.False(t, product.CreatedAt.IsZero())
+		created = append(created, product)
+	}
+
+	// zero values can be written
+	price := int64(0)
+	archived := domain.ProductStatusArchived
+	product, customErr := repo.UpdateProduct(ctx, created[2].ID, &domain.UpdateProductParams{Price: &price, Status: &archived})
+	assert.Nil(t, customErr)
+	assert.Equal(t, int64(0), product.Price)
+	assert.Equal(t, archived, product.Status)
+	assert.Equal(t, created[2].Name, product.Name)
+	_, customErr = repo.UpdateProduct(ctx, created[2].ID+1000, &domain.UpdateProductParams{Price: &price})
+	assert.Equal(t, code.ProductNotFound, customErr.Code)
+	_, customErr = repo.GetProduct(ctx, created[2].ID+1000)
+	assert.Equal(t, code.ProductNotFound, customErr.Code)
+
+	page, total, customErr := repo.ListProducts(ctx, &domain.ListProductsParams{Category: category, Offset: 1, Limit: 1})
+	assert.Nil(t, customErr)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, []int{created[1].ID}, productIDs(page))
+	page, total, customErr = repo.ListProducts(ctx, &domain.ListProductsParams{Category: category, Status: domain.ProductStatusActive, Limit: 10})
+	assert.Nil(t, customErr)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []int{created[1].ID, created[0].ID}, productIDs(page))
+
+	// the archived and the out of stock products are not popular
+	popular, customErr := repo.ListPopularProducts(ctx, &domain.PopularProductsParams{Categories: []string{category}, Limit: 10})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{created[0].ID}, productIDs(popular))
+}
+
+func TestPopularProducts(t *testing.T) {
+	ctx := context.Background()
+	repo := NewProductRepository()
+	interactionRepo := NewInteractionRepository()
+	category := "test-" + util.RandString(8)
+
+	ids := []int{}
+	for i := 0; i < 3; i++ {
+		product, customErr := repo.CreateProduct(ctx, &domain.CreateProductParams{Name: fmt.Sprintf("product %d", i), Category: category, Stock: 1})
+		assert.Nil(t, customErr)
+		ids = append(ids, product.ID)
+	}
+	now := time.Now()
+	uid := util.UUID()
+	assert.Nil(t, interactionRepo.CreateInteractions(ctx, []domain.Interaction{
+		{UID: uid, ProductID: ids[0], Type: domain.InteractionPurchase, CreatedAt: now.Add(-time.Hour)},
+		{UID: uid, ProductID: ids[1], Type: domain.InteractionView, CreatedAt: now.Add(-time.Minute)},
+		{UID: util.UUID(), ProductID: ids[1], Type: domain.InteractionView, CreatedAt: now.Add(-time.Minute)},
+		// too old to count
+		{UID: util.UUID(), ProductID: ids[1], Type: domain.InteractionPurchase, CreatedAt: now.Add(-48 * time.Hour)},
+	}))
+
+	interactions, customErr := interactionRepo.ListInteractions(ctx, uid, 10)
+	assert.Nil(t, customErr)
+	assert.Len(t, interactions, 2)
+	assert.Equal(t, ids[1], interactions[0].ProductID)
+	assert.Equal(t, domain.InteractionView, interactions[0].Type)
+
+	// a purchase weighs more than two views, the product without interactions comes last
+	popular, customErr := repo.ListPopularProducts(ctx, &domain.PopularProductsParams{Categories: []string{category}, Since: now.Add(-24 * time.Hour), Limit: 10})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{ids[0], ids[1], ids[2]}, productIDs(popular))
+
+	popular, customErr = repo.ListPopularProducts(ctx, &domain.PopularProductsParams{Categories: []string{category}, ExcludeIDs: []int{ids[0]}, Since: now.Add(-24 * time.Hour), Limit: 1})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{ids[1]}, productIDs(popular))
+
+	products, customErr := repo.ListProductsByIDs(ctx, []int{ids[2], ids[2] + 1000})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{ids[2]}, productIDs(products))
+}
+
+func TestRelatedProducts(t *testing.T) {
+	ctx := context.Background()
+	repo := NewProductRepository()
+	interactionRepo := NewInteractionRepository()
+
+	ids := []int{}
+	for i := 0; i < 4; i++ {
+		product, customErr := repo.CreateProduct(ctx, &domain.CreateProductParams{Name: fmt.Sprintf("product %d", i), Stock: 1})
+		assert.Nil(t, customErr)
+		ids = append(ids, product.ID)
+	}
+	now := time.Now()
+	uid, a, b := util.UUID(), util.UUID(), util.UUID()
+	assert.Nil(t, interactionRepo.CreateInteractions(ctx, []domain.Interaction{
+		{UID: uid, ProductID: ids[0], Type: domain.InteractionView, CreatedAt: now},
+		{UID: uid, ProductID: ids[3], Type: domain.InteractionView, CreatedAt: now},
+		// two users relate product 1 and one user relates product 2 to product 0
+		{UID: a, ProductID: ids[0], Type: domain.InteractionView, CreatedAt: now},
+		{UID: a, ProductID: ids[1], Type: domain.InteractionView, CreatedAt: now},
+		{UID: a, ProductID: ids[1], Type: domain.InteractionPurchase, CreatedAt: now},
+		{UID: a, ProductID: ids[2], Type: domain.InteractionView, CreatedAt: now},
+		{UID: b, ProductID: ids[0], Type: domain.InteractionView, CreatedAt: now},
+		{UID: b, ProductID: ids[1], Type: domain.InteractionView, CreatedAt: now},
+		// too old to relate
+		{UID: b, ProductID: ids[2], Type: domain.InteractionView, CreatedAt: now.Add(-48 * time.Hour)},
+	}))
+
+	// the interactions of the user are not counted, so product 3 is not related
+	related, customErr := interactionRepo.ListRelatedProductIDs(ctx, &domain.RelatedProductsParams{ProductIDs: []int{ids[0]}, ExcludeUID: uid, Since: now.Add(-24 * time.Hour), Limit: 10})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{ids[1], ids[2]}, related)
+
+	related, customErr = interactionRepo.ListRelatedProductIDs(ctx, &domain.RelatedProductsParams{ProductIDs: []int{ids[0], ids[1]}, ExcludeUID: uid, Since: now.Add(-24 * time.Hour), Limit: 1})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{ids[2]}, related)
+}
+
+func TestCountOutcomes(t *testing.T) {
+	ctx := context.Background()
+	interactionRepo := NewInteractionRepository()
+	experiment := "test-" + util.RandString(8)
+	now := time.Now()
+	a, b := util.UUID(), util.UUID()
+	assert.Nil(t, interactionRepo.CreateInteractions(ctx, []domain.Interaction{
+		{UID: a, ProductID: 1, Type: domain.InteractionView, CreatedAt: now, Experiment: experiment, Variant: "control"},
+		{UID: a, ProductID: 2, Type: domain.InteractionView, CreatedAt: now, Experiment: experiment, Variant: "control"},
+		{UID: b, ProductID: 1, Type: domain.InteractionView, CreatedAt: now, Experiment: experiment, Variant: "control"},
+		{UID: b, ProductID: 1, Type: domain.InteractionPurchase, CreatedAt: now, Experiment: experiment, Variant: "treatment"},
+		// not in the experiment
+		{UID: b, ProductID: 1, Type: domain.InteractionPurchase, CreatedAt: now},
+	}))
+
+	outcomes, customErr := interactionRepo.CountOutcomes(ctx, experiment)
+	assert.Nil(t, customErr)
+	assert.Equal(t, []domain.VariantOutcome{
+		{Variant: "control", Type: domain.InteractionView, Events: 3, Users: 2},
+		{Variant: "treatment", Type: domain.InteractionPurchase, Events: 1, Users: 1},
+	}, outcomes)
+
+	interactions, customErr := interactionRepo.ListInteractions(ctx, a, 1)
+	assert.Nil(t, customErr)
+	assert.Equal(t, experiment, interactions[0].Experiment)
+	assert.Equal(t, "control", interactions[0].Variant)
+}
+
+func productIDs(products []domain.Product) []int {
+	ids := []int{}
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+	return ids
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/db/model"
+)
+
+// maxLastErrorLength is the length of model.EmailOutbox.LastError
+const maxLastErrorLength = 1024
+
+// EnqueueEmail adds an email to the outbox, an email whose ID is already in the outbox is ignored.
+// The sent_at of accountUID is updated when the email is delivered, it can be empty.
+func EnqueueEmail(ctx context.Context, msg *domain.EmailMessage, accountUID string) *code.CustomError {
+	if err := enqueueEmail(GetWith(ctx), msg, accountUID); err != nil {
+		return code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+func enqueueEmail(tx *gorm.DB, msg *domain.EmailMessage, accountUID string) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.EmailOutbox{
+			IdempotencyKey: msg.ID,
+			AccountUID:     accountUID,
+			Message:        string(payload),
+			Status:         model.EmailOutboxPending,
+			NextAttemptAt:  time.Now(),
+		}).Error
+}
+
+// ClaimEmails claims up to limit due emails for delivery and counts an attempt for each.
+// A claimed email is due again after lease, in case the dispatcher which claimed it dies while sending.
+// Rows locked by another dispatcher are skipped.
+func ClaimEmails(ctx context.Context, limit int, lease time.Duration) ([]*domain.OutboxEmail, *code.CustomError) {
+	emails := []*domain.OutboxEmail{}
+	err := GetWith(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		rows := []model.EmailOutbox{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_attempt_at <= ?", []string{model.EmailOutboxPending, model.EmailOutboxSending}, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+
+		claimed := []int64{}
+		for _, row := range rows {
+			msg := &domain.EmailMessage{}
+			if err := json.Unmarshal([]byte(row.Message), msg); err != nil {
+				// it will never be deliverable
+				logrus.WithFields(logrus.Fields{
+					"id":    row.ID,
+					"error": err.Error(),
+				}).Error("ClaimEmails, Unmarshal")
+				err = tx.Model(&row).Updates(map[string]interface{}{
+					"status":     model.EmailOutboxDead,
+					"last_error": truncate(err.Error(), maxLastErrorLength),
+				}).Error
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			claimed = append(claimed, row.ID)
+			emails = append(emails, &domain.OutboxEmail{
+				ID:         row.ID,
+				Message:    msg,
+				AccountUID: row.AccountUID,
+				Attempts:   row.Attempts + 1,
+			})
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+		return tx.Model(&model.EmailOutbox{}).
+			Where("id IN ?", claimed).
+			Updates(map[string]interface{}{
+				"status":          model.EmailOutboxSending,
+				"attempts":        gorm.Expr("attempts + 1"),
+				"next_attempt_at": now.Add(lease),
+			}).Error
+	})
+	if err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return emails, nil
+}
+
+// MarkEmailSent marks a claimed email as delivered, and updates the sent_at of its account.
+// The content of the email, which can hold codes, is dropped from the outbox.
+func MarkEmailSent(ctx context.Context, email *domain.OutboxEmail) *code.CustomError {
+	err := GetWith(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		message, err := json.Marshal(&domain.EmailMessage{
+			ID:       email.Message.ID,
+			To:       email.Message.To,
+			Template: email.Message.Template,
+			Locale:   email.Message.Locale,
+		})
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&model.EmailOutbox{}).
+			Where("id = ?", email.ID).
+			Updates(map[string]interface{}{
+				"status":     model.EmailOutboxSent,
+				"sent_at":    now,
+				"last_error": "",
+				"message":    string(message),
+			}).Error
+		if err != nil || email.AccountUID == "" {
+			return err
+		}
+		return tx.Model(&model.Account{}).
+			Where("uid = ?", email.AccountUID).
+			Update("sent_at", now).Error
+	})
+	if err != nil {
+		return code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// MarkEmailFailed records a failed attempt of a claimed email. The email is retried at nextAttemptAt,
+// or never again if dead. An email claimed again by another dispatcher since is left alone.
+func MarkEmailFailed(ctx context.Context, email *domain.OutboxEmail, lastError string, nextAttemptAt time.Time, dead bool) *code.CustomError {
+	status := model.EmailOutboxPending
+	if dead {
+		status = model.EmailOutboxDead
+	}
+	err := GetWith(ctx).
+		Model(&model.EmailOutbox{}).
+		Where("id = ? AND status = ? AND attempts = ?", email.ID, model.EmailOutboxSending, email.Attempts).
+		Updates(map[string]interface{}{
+			"status":          status,
+			"next_attempt_at": nextAttemptAt,
+			"last_error":      truncate(lastError, maxLastErrorLength),
+		}).Error
+	if err != nil {
+		return code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// truncate cuts s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
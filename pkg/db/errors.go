@@ -0,0 +1,18 @@
+package db
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// IsDuplicateEntryError checks if the error is a unique constraint violation. The drivers translate
+// their own errors (mysql 1062, postgres 23505, sqlite constraint unique) to gorm.ErrDuplicatedKey.
+func IsDuplicateEntryError(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+// IsRecordNotFoundError checks if the error is a record not found error
+func IsRecordNotFoundError(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,128 @@
+package migrations
+
+import (
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// the tables as of this migration, later changes of pkg/db/model must not change what it creates
+
+type account0001 struct {
+	UID                   string     `gorm:"column:uid;size:36;not null;primaryKey"`
+	Email                 string     `gorm:"column:email;size:256;not null;uniqueIndex:idx_accounts"`
+	HashedPassword        string     `gorm:"column:hashed_password;size:255;not null"`
+	IsActive              bool       `gorm:"column:is_active;not null;default:false"`
+	SentAt                *time.Time `gorm:"column:sent_at;type:timestamp;"`
+	PasswordResetRequired bool       `gorm:"column:password_reset_required;not null;default:false"`
+	Locale                string     `gorm:"column:locale;size:35;not null;default:''"`
+	Phone                 *string    `gorm:"column:phone;size:16;uniqueIndex:idx_accounts_phone"`
+	CreatedAt             time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt             time.Time  `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	DeleteAt              *time.Time `gorm:"column:delete_at;type:timestamp"`
+}
+
+func (*account0001) TableName() string { return "accounts" }
+
+type session0001 struct {
+	JTI        string     `gorm:"column:jti;size:36;not null;primaryKey"`
+	UID        string     `gorm:"column:uid;size:36;not null;index:idx_sessions_uid"`
+	UserAgent  string     `gorm:"column:user_agent;size:512;not null;default:''"`
+	IP         string     `gorm:"column:ip;size:45;not null;default:''"`
+	CreatedAt  time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	LastSeenAt time.Time  `gorm:"column:last_seen_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at;type:timestamp"`
+}
+
+func (*session0001) TableName() string { return "sessions" }
+
+type passwordHistory0001 struct {
+	ID             int64     `gorm:"column:id;not null;primaryKey;autoIncrement"`
+	UID            string    `gorm:"column:uid;size:36;not null;index:idx_password_history_uid"`
+	HashedPassword string    `gorm:"column:hashed_password;size:255;not null"`
+	CreatedAt      time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (*passwordHistory0001) TableName() string { return "password_history" }
+
+type emailOutbox0001 struct {
+	ID             int64      `gorm:"column:id;not null;primaryKey;autoIncrement"`
+	IdempotencyKey string     `gorm:"column:idempotency_key;size:191;not null;uniqueIndex:idx_email_outbox_idempotency_key"`
+	AccountUID     string     `gorm:"column:account_uid;size:36;not null;default:''"`
+	Message        string     `gorm:"column:message;not null"`
+	Status         string     `gorm:"column:status;size:16;not null;index:idx_email_outbox_due,priority:1"`
+	Attempts       int        `gorm:"column:attempts;type:int;not null;default:0"`
+	NextAttemptAt  time.Time  `gorm:"column:next_attempt_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;index:idx_email_outbox_due,priority:2"`
+	LastError      string     `gorm:"column:last_error;size:1024;not null;default:''"`
+	SentAt         *time.Time `gorm:"column:sent_at;type:timestamp"`
+	CreatedAt      time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt      time.Time  `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (*emailOutbox0001) TableName() string { return "email_outbox" }
+
+func init() {
+	tables := []interface{}{&account0001{}, &session0001{}, &passwordHistory0001{}, &emailOutbox0001{}}
+	register(Migration{
+		Version: 1,
+		Name:    "create_account_tables",
+		Up: func(tx *gorm.DB) error {
+			// a database created by AutoMigrate before the migrations already has the tables, which are upgraded instead
+			for _, table := range tables {
+				if tx.Migrator().HasTable(table) {
+					if err := upgradeTable0001(tx, table); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := tx.Migrator().CreateTable(table); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(tables...)
+		},
+	})
+}
+
+// upgradeTable0001 brings a table created by AutoMigrate up to the table of this migration
+func upgradeTable0001(tx *gorm.DB, table interface{}) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(table); err != nil {
+		return err
+	}
+	migrator := tx.Migrator()
+	// the column was varchar(72) for bcrypt, which is too short for the argon2id hashes
+	if _, ok := table.(*account0001); ok {
+		if err := migrator.AlterColumn(table, "HashedPassword"); err != nil {
+			return err
+		}
+	}
+	for _, column := range stmt.Schema.DBNames {
+		if migrator.HasColumn(table, column) {
+			continue
+		}
+		if err := migrator.AddColumn(table, column); err != nil {
+			return err
+		}
+	}
+
+	indexes := stmt.Schema.ParseIndexes()
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if migrator.HasIndex(table, name) {
+			continue
+		}
+		if err := migrator.CreateIndex(table, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import "gorm.io/gorm"
+
+type productRecommendation0002 struct {
+	ID   int    `gorm:"column:id;not null;primaryKey;autoIncrement"`
+	Name string `gorm:"column:name;size:256;not null"`
+}
+
+func (*productRecommendation0002) TableName() string { return "product_recommendations" }
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "create_product_recommendations",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&productRecommendation0002{}); err != nil {
+				return err
+			}
+			// the recommendation which was hard coded before
+			return tx.Create(&productRecommendation0002{Name: "product1"}).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&productRecommendation0002{})
+		},
+	})
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type product0003 struct {
+	ID          int       `gorm:"column:id;not null;primaryKey;autoIncrement"`
+	Name        string    `gorm:"column:name;size:256;not null"`
+	Description string    `gorm:"column:description;size:4096;not null;default:''"`
+	Price       int64     `gorm:"column:price;not null;default:0"`
+	Category    string    `gorm:"column:category;size:64;not null;default:'';index:idx_products_category"`
+	Stock       int       `gorm:"column:stock;type:int;not null;default:0"`
+	Status      string    `gorm:"column:status;size:16;not null;index:idx_products_status,priority:1"`
+	CreatedAt   time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;index:idx_products_status,priority:2"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (*product0003) TableName() string { return "products" }
+
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "create_products",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&product0003{}); err != nil {
+				return err
+			}
+			// product_recommendations only held the stub product, the products are managed by the admin APIs
+			return tx.Migrator().DropTable(&productRecommendation0002{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&product0003{}); err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateTable(&productRecommendation0002{}); err != nil {
+				return err
+			}
+			return tx.Create(&productRecommendation0002{Name: "product1"}).Error
+		},
+	})
+}
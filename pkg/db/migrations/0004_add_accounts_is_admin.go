@@ -0,0 +1,22 @@
+package migrations
+
+import "gorm.io/gorm"
+
+type account0004 struct {
+	IsAdmin bool `gorm:"column:is_admin;not null;default:false"`
+}
+
+func (*account0004) TableName() string { return "accounts" }
+
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "add_accounts_is_admin",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&account0004{}, "IsAdmin")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&account0004{}, "IsAdmin")
+		},
+	})
+}
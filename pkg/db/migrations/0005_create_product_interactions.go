@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type productInteraction0005 struct {
+	ID        int64     `gorm:"column:id;not null;primaryKey;autoIncrement"`
+	UID       string    `gorm:"column:uid;size:36;not null;index:idx_product_interactions_uid,priority:1"`
+	ProductID int       `gorm:"column:product_id;not null"`
+	Type      string    `gorm:"column:type;size:16;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;index:idx_product_interactions_uid,priority:2;index:idx_product_interactions_created_at"`
+}
+
+func (*productInteraction0005) TableName() string { return "product_interactions" }
+
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "create_product_interactions",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&productInteraction0005{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&productInteraction0005{})
+		},
+	})
+}
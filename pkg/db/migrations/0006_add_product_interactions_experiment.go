@@ -0,0 +1,37 @@
+package migrations
+
+import "gorm.io/gorm"
+
+type productInteraction0006 struct {
+	Experiment string `gorm:"column:experiment;size:64;not null;default:'';index:idx_product_interactions_experiment,priority:1"`
+	Variant    string `gorm:"column:variant;size:64;not null;default:'';index:idx_product_interactions_experiment,priority:2"`
+}
+
+func (*productInteraction0006) TableName() string { return "product_interactions" }
+
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "add_product_interactions_experiment",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.AddColumn(&productInteraction0006{}, "Experiment"); err != nil {
+				return err
+			}
+			if err := m.AddColumn(&productInteraction0006{}, "Variant"); err != nil {
+				return err
+			}
+			return m.CreateIndex(&productInteraction0006{}, "idx_product_interactions_experiment")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&productInteraction0006{}, "idx_product_interactions_experiment"); err != nil {
+				return err
+			}
+			if err := m.DropColumn(&productInteraction0006{}, "Variant"); err != nil {
+				return err
+			}
+			return m.DropColumn(&productInteraction0006{}, "Experiment")
+		},
+	})
+}
@@ -0,0 +1,77 @@
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var (
+	fileNamePattern = regexp.MustCompile(`^(\d+)_[a-z0-9_]+\.go$`)
+	namePattern     = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+var migrationTemplate = template.Must(template.New("migration").Parse(`package migrations
+
+import "gorm.io/gorm"
+
+func init() {
+	register(Migration{
+		Version: {{.Version}},
+		Name:    "{{.Name}}",
+		Up: func(tx *gorm.DB) error {
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+}
+`))
+
+// Create writes an empty migration file to dir, numbered after the latest file in dir, and returns its path
+func Create(dir, name string) (string, error) {
+	name = strings.NewReplacer("-", "_", " ", "_").Replace(strings.ToLower(strings.TrimSpace(name)))
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid migration name %q, use letters, digits and underscores", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var latest int64
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return "", err
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+
+	version := latest + 1
+	path := filepath.Join(dir, fmt.Sprintf("%04d_%s.go", version, name))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	err = migrationTemplate.Execute(file, map[string]interface{}{
+		"Version": version,
+		"Name":    name,
+	})
+	return path, err
+}
@@ -0,0 +1,328 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// TableNameSchemaMigrations is the table of the applied migrations
+	TableNameSchemaMigrations = "schema_migrations"
+	// lockName is the name of the mysql lock, lockKey is the key of the postgres advisory lock
+	lockName = "goauth_schema_migrations"
+	lockKey  = 7305626389175318563
+)
+
+var (
+	// ErrLocked is returned when another process holds the migration lock for too long
+	ErrLocked = errors.New("migrations are locked by another process")
+)
+
+// Migration is a numbered schema or data change, Up and Down run in a transaction where the database supports it
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+var registered []Migration
+
+// register adds a migration, it is called by the init of each migration file
+func register(m Migration) {
+	registered = append(registered, m)
+}
+
+// All returns the registered migrations in version order
+func All() []Migration {
+	all := append([]Migration{}, registered...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
+
+// schemaMigration is a row of schema_migrations. A dirty row is a migration which failed halfway,
+// mysql can not roll back DDL, so it must be fixed by hand and then forced.
+type schemaMigration struct {
+	Version   int64     `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;size:255;not null;default:''"`
+	Dirty     bool      `gorm:"column:dirty;not null;default:false"`
+	AppliedAt time.Time `gorm:"column:applied_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName schemaMigration's table name
+func (*schemaMigration) TableName() string {
+	return TableNameSchemaMigrations
+}
+
+// DirtyError is returned when a migration failed halfway, see Runner.Force
+type DirtyError struct {
+	Version int64
+}
+
+// Error .
+func (e *DirtyError) Error() string {
+	return fmt.Sprintf("migration %d is dirty, fix the schema by hand and force a version", e.Version)
+}
+
+// Status is the state of a migration
+type Status struct {
+	Version int64
+	Name    string
+	Applied bool
+	Dirty   bool
+	// AppliedAt is nil for a pending migration
+	AppliedAt *time.Time
+	// Missing is an applied migration which is not registered, such as one applied by a newer build
+	Missing bool
+}
+
+// Runner applies migrations and records them in schema_migrations.
+// Runners of different processes are serialized by a lock of the database.
+type Runner struct {
+	db          *gorm.DB
+	migrations  []Migration
+	lockTimeout time.Duration
+}
+
+// NewRunner creates a Runner of migrations, which are sorted by version
+func NewRunner(db *gorm.DB, migrations []Migration) *Runner {
+	sorted := append([]Migration{}, migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Runner{
+		db:          db,
+		migrations:  sorted,
+		lockTimeout: time.Minute,
+	}
+}
+
+// withLock runs fn on one connection which holds the migration lock
+func (r *Runner) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return r.db.WithContext(ctx).Connection(func(pinned *gorm.DB) error {
+		// a new session, otherwise the implicit transaction of a write puts the pool back in place of the connection
+		conn := pinned.Session(&gorm.Session{})
+		switch conn.Dialector.Name() {
+		case "mysql":
+			var locked sql.NullInt64
+			if err := conn.Raw("SELECT GET_LOCK(?, ?)", lockName, int(r.lockTimeout.Seconds())).Scan(&locked).Error; err != nil {
+				return err
+			}
+			if locked.Int64 != 1 {
+				return ErrLocked
+			}
+			defer conn.Exec("SELECT RELEASE_LOCK(?)", lockName)
+		case "postgres":
+			var locked bool
+			deadline := time.Now().Add(r.lockTimeout)
+			for {
+				if err := conn.Raw("SELECT pg_try_advisory_lock(?)", lockKey).Scan(&locked).Error; err != nil {
+					return err
+				}
+				if locked {
+					break
+				}
+				if time.Now().After(deadline) {
+					return ErrLocked
+				}
+				time.Sleep(time.Second)
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", lockKey)
+		}
+		// sqlite locks the database file for each write transaction, and the pool has one connection
+
+		if err := conn.AutoMigrate(&schemaMigration{}); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+// applied returns the rows of schema_migrations by version
+func applied(conn *gorm.DB) (map[int64]schemaMigration, error) {
+	rows := []schemaMigration{}
+	if err := conn.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]schemaMigration{}
+	for _, row := range rows {
+		byVersion[row.Version] = row
+	}
+	return byVersion, nil
+}
+
+func checkDirty(rows map[int64]schemaMigration) error {
+	for _, row := range rows {
+		if row.Dirty {
+			return &DirtyError{Version: row.Version}
+		}
+	}
+	return nil
+}
+
+// run applies or reverts one migration. The row is marked dirty first, so that a failure which
+// leaves the schema halfway is not mistaken for a clean state.
+func run(conn *gorm.DB, m Migration, up bool) error {
+	step, action := m.Up, "up"
+	if !up {
+		step, action = m.Down, "down"
+	}
+	if step == nil {
+		return fmt.Errorf("migration %d %s has no %s step", m.Version, m.Name, action)
+	}
+
+	var err error
+	if up {
+		err = conn.Create(&schemaMigration{Version: m.Version, Name: m.Name, Dirty: true, AppliedAt: time.Now()}).Error
+	} else {
+		err = conn.Model(&schemaMigration{}).Where("version = ?", m.Version).Update("dirty", true).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := conn.Transaction(step); err != nil {
+		return fmt.Errorf("migration %d %s %s: %w", m.Version, m.Name, action, err)
+	}
+
+	if up {
+		return conn.Model(&schemaMigration{}).Where("version = ?", m.Version).
+			Updates(map[string]interface{}{"dirty": false, "applied_at": time.Now()}).Error
+	}
+	return conn.Where("version = ?", m.Version).Delete(&schemaMigration{}).Error
+}
+
+// Up applies the pending migrations in version order and returns the applied ones
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	done := []Migration{}
+	err := r.withLock(ctx, func(conn *gorm.DB) error {
+		rows, err := applied(conn)
+		if err != nil {
+			return err
+		}
+		if err := checkDirty(rows); err != nil {
+			return err
+		}
+		for _, m := range r.migrations {
+			if _, ok := rows[m.Version]; ok {
+				continue
+			}
+			if err := run(conn, m, true); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the latest n applied migrations and returns the reverted ones
+func (r *Runner) Down(ctx context.Context, n int) ([]Migration, error) {
+	done := []Migration{}
+	err := r.withLock(ctx, func(conn *gorm.DB) error {
+		rows, err := applied(conn)
+		if err != nil {
+			return err
+		}
+		if err := checkDirty(rows); err != nil {
+			return err
+		}
+		versions := []int64{}
+		for version := range rows {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for i := 0; i < n && i < len(versions); i++ {
+			m, ok := r.find(versions[i])
+			if !ok {
+				return fmt.Errorf("migration %d %s is applied but not registered", versions[i], rows[versions[i]].Name)
+			}
+			if err := run(conn, m, false); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status returns the state of the registered and the applied migrations in version order
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	statuses := []Status{}
+	err := r.withLock(ctx, func(conn *gorm.DB) error {
+		rows, err := applied(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range r.migrations {
+			status := Status{Version: m.Version, Name: m.Name}
+			if row, ok := rows[m.Version]; ok {
+				appliedAt := row.AppliedAt
+				status.Applied, status.Dirty, status.AppliedAt = true, row.Dirty, &appliedAt
+				delete(rows, m.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		for _, row := range rows {
+			appliedAt := row.AppliedAt
+			statuses = append(statuses, Status{
+				Version:   row.Version,
+				Name:      row.Name,
+				Applied:   true,
+				Dirty:     row.Dirty,
+				AppliedAt: &appliedAt,
+				Missing:   true,
+			})
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
+
+// Force records the registered migrations up to version as applied and the later ones as not applied,
+// and clears the dirty state, without running any migration. Version 0 records none as applied.
+func (r *Runner) Force(ctx context.Context, version int64) error {
+	if _, ok := r.find(version); !ok && version != 0 {
+		return fmt.Errorf("migration %d is not registered", version)
+	}
+	return r.withLock(ctx, func(conn *gorm.DB) error {
+		return conn.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("version > ?", version).Delete(&schemaMigration{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&schemaMigration{}).Where("dirty = ?", true).Update("dirty", false).Error; err != nil {
+				return err
+			}
+			rows, err := applied(tx)
+			if err != nil {
+				return err
+			}
+			for _, m := range r.migrations {
+				if _, ok := rows[m.Version]; ok || m.Version > version {
+					continue
+				}
+				if err := tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+func (r *Runner) find(version int64) (Migration, bool) {
+	for _, m := range r.migrations {
+		if m.Version == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
@@ -0,0 +1,184 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	assert.Nil(t, err)
+	sqlDB, err := db.DB()
+	assert.Nil(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+type widget struct {
+	ID   int
+	Name string
+}
+
+func TestRunner(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	fail := true
+	migrations := []Migration{
+		{
+			Version: 2,
+			Name:    "seed_widgets",
+			Up: func(tx *gorm.DB) error {
+				return tx.Create(&widget{Name: "first"}).Error
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Where("name = ?", "first").Delete(&widget{}).Error
+			},
+		},
+		{
+			Version: 1,
+			Name:    "create_widgets",
+			Up: func(tx *gorm.DB) error {
+				return tx.Migrator().CreateTable(&widget{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&widget{})
+			},
+		},
+	}
+	runner := NewRunner(db, migrations)
+
+	applied, err := runner.Up(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2}, versionsOf(applied))
+	var count int64
+	assert.Nil(t, db.Model(&widget{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	// nothing is pending
+	applied, err = runner.Up(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, applied, 0)
+
+	reverted, err := runner.Down(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{2}, versionsOf(reverted))
+	statuses, err := runner.Status(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+
+	// a failed migration leaves a dirty state which blocks the next runs
+	runner = NewRunner(db, append(migrations, Migration{
+		Version: 3,
+		Name:    "broken",
+		Up: func(tx *gorm.DB) error {
+			if fail {
+				return errors.New("broken")
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error { return nil },
+	}))
+	_, err = runner.Up(ctx)
+	assert.ErrorContains(t, err, "broken")
+	_, err = runner.Up(ctx)
+	dirty := &DirtyError{}
+	assert.True(t, errors.As(err, &dirty))
+	assert.Equal(t, int64(3), dirty.Version)
+
+	// force records 2 as the latest, and 3 runs again once fixed
+	assert.Nil(t, runner.Force(ctx, 2))
+	fail = false
+	applied, err = runner.Up(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{3}, versionsOf(applied))
+	// 2 was applied again before 3 failed
+	assert.Nil(t, db.Model(&widget{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	// forcing 1 records 2 and 3 as not applied without running their down steps
+	assert.Nil(t, runner.Force(ctx, 1))
+	statuses, err = runner.Status(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, false, false}, []bool{statuses[0].Applied, statuses[1].Applied, statuses[2].Applied})
+	assert.Nil(t, db.Model(&widget{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	assert.NotNil(t, runner.Force(ctx, 4))
+}
+
+func TestRegisteredMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	runner := NewRunner(db, All())
+
+	applied, err := runner.Up(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, applied, len(All()))
+	assert.True(t, db.Migrator().HasTable("accounts"))
+
+	// every migration can be reverted
+	reverted, err := runner.Down(ctx, len(All()))
+	assert.Nil(t, err)
+	assert.Len(t, reverted, len(All()))
+	assert.False(t, db.Migrator().HasTable("accounts"))
+}
+
+func TestUpgradeAutoMigrated(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	// the accounts table which AutoMigrate created before the migrations
+	assert.Nil(t, db.Exec("CREATE TABLE `accounts` (`uid` varchar(36) NOT NULL, `email` varchar(256) NOT NULL, "+
+		"`hashed_password` varchar(72) NOT NULL, `is_active` tinyint(1) NOT NULL DEFAULT 0, `sent_at` timestamp, "+
+		"`created_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP, `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP, "+
+		"`delete_at` timestamp, PRIMARY KEY (`uid`))").Error)
+	assert.Nil(t, db.Exec("CREATE UNIQUE INDEX `idx_accounts` ON `accounts`(`email`)").Error)
+	assert.Nil(t, db.Exec("INSERT INTO `accounts` (`uid`, `email`, `hashed_password`) VALUES ('uid', 'a@b.c', 'hashed')").Error)
+
+	_, err := NewRunner(db, All()).Up(ctx)
+	assert.Nil(t, err)
+	for _, column := range []string{"password_reset_required", "locale", "phone", "is_admin"} {
+		assert.True(t, db.Migrator().HasColumn("accounts", column), column)
+	}
+	assert.True(t, db.Migrator().HasIndex("accounts", "idx_accounts_phone"))
+	var ddl string
+	assert.Nil(t, db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'accounts'").Scan(&ddl).Error)
+	assert.NotContains(t, ddl, "varchar(72)")
+	var count int64
+	assert.Nil(t, db.Table("accounts").Where("uid = ? AND locale = ?", "uid", "").Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "0007_existing.go"), []byte("package migrations\n"), 0o644))
+
+	path, err := Create(dir, "Add Widgets")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "0008_add_widgets.go"), path)
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "Version: 8,")
+	assert.Contains(t, string(content), `Name:    "add_widgets",`)
+
+	_, err = Create(dir, "drop;table")
+	assert.NotNil(t, err)
+}
+
+func versionsOf(migrations []Migration) []int64 {
+	versions := []int64{}
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	return versions
+}
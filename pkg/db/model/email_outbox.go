@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// TableNameEmailOutbox is the table name of <email_outbox>
+const TableNameEmailOutbox = "email_outbox"
+
+// status of EmailOutbox
+const (
+	EmailOutboxPending = "pending"
+	EmailOutboxSending = "sending"
+	EmailOutboxSent    = "sent"
+	EmailOutboxDead    = "dead"
+)
+
+// EmailOutbox mapped from table <email_outbox>
+type EmailOutbox struct {
+	ID             int64      `gorm:"column:id;not null;primaryKey;autoIncrement"`
+	IdempotencyKey string     `gorm:"column:idempotency_key;size:191;not null;uniqueIndex:idx_email_outbox_idempotency_key"`
+	AccountUID     string     `gorm:"column:account_uid;size:36;not null;default:''"`
+	Message        string     `gorm:"column:message;not null"`
+	Status         string     `gorm:"column:status;size:16;not null;index:idx_email_outbox_due,priority:1"`
+	Attempts       int        `gorm:"column:attempts;type:int;not null;default:0"`
+	NextAttemptAt  time.Time  `gorm:"column:next_attempt_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;index:idx_email_outbox_due,priority:2"`
+	LastError      string     `gorm:"column:last_error;size:1024;not null;default:''"`
+	SentAt         *time.Time `gorm:"column:sent_at;type:timestamp"`
+	CreatedAt      time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt      time.Time  `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName EmailOutbox's table name
+func (*EmailOutbox) TableName() string {
+	return TableNameEmailOutbox
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// TableNamePasswordHistory is the table name of <password_history>
+const TableNamePasswordHistory = "password_history"
+
+// PasswordHistory mapped from table <password_history>
+type PasswordHistory struct {
+	ID             int64     `gorm:"column:id;not null;primaryKey;autoIncrement"`
+	UID            string    `gorm:"column:uid;size:36;not null;index:idx_password_history_uid"`
+	HashedPassword string    `gorm:"column:hashed_password;size:255;not null"`
+	CreatedAt      time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName PasswordHistory's table name
+func (*PasswordHistory) TableName() string {
+	return TableNamePasswordHistory
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// TableNameProductInteraction is the table name of <product_interactions>
+const TableNameProductInteraction = "product_interactions"
+
+// ProductInteraction mapped from table <product_interactions>
+type ProductInteraction struct {
+	ID         int64     `gorm:"column:id;not null;primaryKey;autoIncrement"`
+	UID        string    `gorm:"column:uid;size:36;not null;index:idx_product_interactions_uid,priority:1"`
+	ProductID  int       `gorm:"column:product_id;not null"`
+	Type       string    `gorm:"column:type;size:16;not null"`
+	CreatedAt  time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;index:idx_product_interactions_uid,priority:2;index:idx_product_interactions_created_at"`
+	Experiment string    `gorm:"column:experiment;size:64;not null;default:'';index:idx_product_interactions_experiment,priority:1"`
+	Variant    string    `gorm:"column:variant;size:64;not null;default:'';index:idx_product_interactions_experiment,priority:2"`
+}
+
+// TableName ProductInteraction's table name
+func (*ProductInteraction) TableName() string {
+	return TableNameProductInteraction
+}
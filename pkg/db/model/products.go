@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// TableNameProduct is the table name of <products>
+const TableNameProduct = "products"
+
+// Product mapped from table <products>
+type Product struct {
+	ID          int       `gorm:"column:id;not null;primaryKey;autoIncrement"`
+	Name        string    `gorm:"column:name;size:256;not null"`
+	Description string    `gorm:"column:description;size:4096;not null;default:''"`
+	Price       int64     `gorm:"column:price;not null;default:0"`
+	Category    string    `gorm:"column:category;size:64;not null;default:'';index:idx_products_category"`
+	Stock       int       `gorm:"column:stock;type:int;not null;default:0"`
+	Status      string    `gorm:"column:status;size:16;not null;index:idx_products_status,priority:1"`
+	CreatedAt   time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;index:idx_products_status,priority:2"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName Product's table name
+func (*Product) TableName() string {
+	return TableNameProduct
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// TableNameSession is the table name of <sessions>
+const TableNameSession = "sessions"
+
+// Session mapped from table <sessions>
+type Session struct {
+	JTI        string     `gorm:"column:jti;size:36;not null;primaryKey"`
+	UID        string     `gorm:"column:uid;size:36;not null;index:idx_sessions_uid"`
+	UserAgent  string     `gorm:"column:user_agent;size:512;not null;default:''"`
+	IP         string     `gorm:"column:ip;size:45;not null;default:''"`
+	CreatedAt  time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	LastSeenAt time.Time  `gorm:"column:last_seen_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at;type:timestamp"`
+}
+
+// TableName Session's table name
+func (*Session) TableName() string {
+	return TableNameSession
+}
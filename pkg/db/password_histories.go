@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"net/http"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/db/model"
+)
+
+// ListPasswordHistory lists the latest previous password hashes of a user, the latest first
+func (r *AccountRepository) ListPasswordHistory(ctx context.Context, uid string, limit int) ([]string, *code.CustomError) {
+	hashes := []string{}
+	err := GetWith(ctx).
+		Model(&model.PasswordHistory{}).
+		Where("uid = ?", uid).
+		Order("id DESC").
+		Limit(limit).
+		Pluck("hashed_password", &hashes).Error
+	if err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return hashes, nil
+}
+
+// ChangePassword replaces the password of an account, keeps the previous hash in the history,
+// and prunes the history to the given depth
+func (r *AccountRepository) ChangePassword(ctx context.Context, params *domain.ChangePasswordParams) *code.CustomError {
+	httpStatus := http.StatusInternalServerError
+	errCode := code.DBError
+	err := GetWith(ctx).Transaction(func(tx *gorm.DB) error {
+		account := model.Account{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uid = ?", params.UID).
+			First(&account).Error
+		if err != nil {
+			if IsRecordNotFoundError(err) {
+				httpStatus = http.StatusBadRequest
+				errCode = code.UserNotFound
+			}
+			return err
+		}
+
+		if params.HistoryDepth > 0 {
+			err = tx.Create(&model.PasswordHistory{
+				UID:            params.UID,
+				HashedPassword: account.HashedPassword,
+			}).Error
+			if err != nil {
+				return err
+			}
+			if err := prunePasswordHistory(tx, params.UID, params.HistoryDepth); err != nil {
+				return err
+			}
+		}
+
+		updates := map[string]interface{}{
+			"hashed_password": params.HashedPassword,
+		}
+		if params.ClearResetRequired {
+			updates["password_reset_required"] = false
+		}
+		return tx.Model(&account).Updates(updates).Error
+	})
+	if err != nil {
+		return code.NewCustomError(errCode, httpStatus, err)
+	}
+	return nil
+}
+
+// prunePasswordHistory deletes the history of a user older than the latest depth rows
+func prunePasswordHistory(tx *gorm.DB, uid string, depth int) error {
+	ids := []int64{}
+	err := tx.Model(&model.PasswordHistory{}).
+		Where("uid = ?", uid).
+		Order("id DESC").
+		Offset(depth).
+		Limit(1000).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return tx.Where("id IN ?", ids).Delete(&model.PasswordHistory{}).Error
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/db/model"
+)
+
+// InteractionRepository is the domain.InteractionRepository of the database
+type InteractionRepository struct{}
+
+var _ domain.InteractionRepository = (*InteractionRepository)(nil)
+
+// NewInteractionRepository creates an InteractionRepository
+func NewInteractionRepository() *InteractionRepository {
+	return &InteractionRepository{}
+}
+
+// CreateInteractions records interactions in one statement
+func (r *InteractionRepository) CreateInteractions(ctx context.Context, interactions []domain.Interaction) *code.CustomError {
+	if len(interactions) == 0 {
+		return nil
+	}
+	rows := make([]model.ProductInteraction, 0, len(interactions))
+	for _, interaction := range interactions {
+		rows = append(rows, model.ProductInteraction{
+			UID:        interaction.UID,
+			ProductID:  interaction.ProductID,
+			Type:       interaction.Type,
+			CreatedAt:  interaction.CreatedAt,
+			Experiment: interaction.Experiment,
+			Variant:    interaction.Variant,
+		})
+	}
+	if err := GetWith(ctx).Create(&rows).Error; err != nil {
+		return code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// ListInteractions lists the latest interactions of a user, the latest first
+func (r *InteractionRepository) ListInteractions(ctx context.Context, uid string, limit int) ([]domain.Interaction, *code.CustomError) {
+	rows := []model.ProductInteraction{}
+	err := GetWith(ctx).
+		Where("uid = ?", uid).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&rows).Error
+	if err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	interactions := make([]domain.Interaction, 0, len(rows))
+	for _, row := range rows {
+		interactions = append(interactions, domain.Interaction{
+			UID:        row.UID,
+			ProductID:  row.ProductID,
+			Type:       row.Type,
+			CreatedAt:  row.CreatedAt,
+			Experiment: row.Experiment,
+			Variant:    row.Variant,
+		})
+	}
+	return interactions, nil
+}
+
+// ListRelatedProductIDs lists the other products which the users of params.ProductIDs interacted with,
+// by the number of such users, the most first
+func (r *InteractionRepository) ListRelatedProductIDs(ctx context.Context, params *domain.RelatedProductsParams) ([]int, *code.CustomError) {
+	if len(params.ProductIDs) == 0 {
+		return []int{}, nil
+	}
+	ids := []int{}
+	err := GetWith(ctx).
+		Table(model.TableNameProductInteraction+" AS seed").
+		Select("related.product_id").
+		Joins("JOIN product_interactions AS related ON related.uid = seed.uid AND related.product_id NOT IN ? AND related.created_at >= ?", params.ProductIDs, params.Since).
+		Where("seed.product_id IN ? AND seed.uid <> ? AND seed.created_at >= ?", params.ProductIDs, params.ExcludeUID, params.Since).
+		Group("related.product_id").
+		Order("COUNT(DISTINCT related.uid) DESC, related.product_id DESC").
+		Limit(params.Limit).
+		Scan(&ids).Error
+	if err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return ids, nil
+}
+
+// CountOutcomes counts the interactions recorded in the experiment by variant and type
+func (r *InteractionRepository) CountOutcomes(ctx context.Context, experiment string) ([]domain.VariantOutcome, *code.CustomError) {
+	outcomes := []domain.VariantOutcome{}
+	err := GetWith(ctx).
+		Model(&model.ProductInteraction{}).
+		Select("variant, type, COUNT(*) AS events, COUNT(DISTINCT uid) AS users").
+		Where("experiment = ?", experiment).
+		Group("variant, type").
+		Order("variant, type").
+		Scan(&outcomes).Error
+	if err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return outcomes, nil
+}
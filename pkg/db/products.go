@@ -2,19 +2,250 @@ package db
 
 import (
 	"context"
-	"time"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/Yu-Qi/GoAuth/domain"
 	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/db/model"
 )
 
-// GetProductRecommendations gets product recommendations
-func GetProductRecommendations(ctx context.Context) ([]domain.Product, *code.CustomError) {
-	time.Sleep(3 * time.Second) // simulate db slow query
-	return []domain.Product{
-		{
-			ID:   1,
-			Name: "product1",
-		},
-	}, nil
+// ProductRepository is the domain.ProductRepository of the database
+type ProductRepository struct{}
+
+var _ domain.ProductRepository = (*ProductRepository)(nil)
+
+// NewProductRepository creates a ProductRepository
+func NewProductRepository() *ProductRepository {
+	return &ProductRepository{}
+}
+
+func toDomainProduct(product *model.Product) *domain.Product {
+	return &domain.Product{
+		ID:          product.ID,
+		Name:        product.Name,
+		Description: product.Description,
+		Price:       product.Price,
+		Category:    product.Category,
+		Stock:       product.Stock,
+		Status:      product.Status,
+		CreatedAt:   product.CreatedAt,
+		UpdatedAt:   product.UpdatedAt,
+	}
+}
+
+func toDomainProducts(products []model.Product) []domain.Product {
+	result := make([]domain.Product, 0, len(products))
+	for i := range products {
+		result = append(result, *toDomainProduct(&products[i]))
+	}
+	return result
+}
+
+// CreateProduct creates a new product
+func (r *ProductRepository) CreateProduct(ctx context.Context, params *domain.CreateProductParams) (*domain.Product, *code.CustomError) {
+	product := &model.Product{
+		Name:        params.Name,
+		Description: params.Description,
+		Price:       params.Price,
+		Category:    params.Category,
+		Stock:       params.Stock,
+		Status:      params.Status,
+	}
+	if product.Status == "" {
+		product.Status = domain.ProductStatusActive
+	}
+	if err := GetWith(ctx).Create(product).Error; err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return toDomainProduct(product), nil
+}
+
+// GetProduct gets a product by id
+func (r *ProductRepository) GetProduct(ctx context.Context, id int) (*domain.Product, *code.CustomError) {
+	product := &model.Product{}
+	err := GetWith(ctx).
+		Where("id = ?", id).
+		First(product).Error
+	if IsRecordNotFoundError(err) {
+		return nil, code.NewCustomError(code.ProductNotFound, http.StatusNotFound, err)
+	} else if err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return toDomainProduct(product), nil
+}
+
+// UpdateProduct updates a product and returns the updated product
+func (r *ProductRepository) UpdateProduct(ctx context.Context, id int, params *domain.UpdateProductParams) (*domain.Product, *code.CustomError) {
+	httpStatus := http.StatusInternalServerError
+	errCode := code.DBError
+	product := &model.Product{}
+	err := GetWith(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", id).
+			First(product).Error
+		if err != nil {
+			if IsRecordNotFoundError(err) {
+				httpStatus = http.StatusNotFound
+				errCode = code.ProductNotFound
+			}
+			return err
+		}
+		updates := map[string]interface{}{}
+		if params.Name != nil {
+			updates["name"] = *params.Name
+		}
+		if params.Description != nil {
+			updates["description"] = *params.Description
+		}
+		if params.Price != nil {
+			updates["price"] = *params.Price
+		}
+		if params.Category != nil {
+			updates["category"] = *params.Category
+		}
+		if params.Stock != nil {
+			updates["stock"] = *params.Stock
+		}
+		if params.Status != nil {
+			updates["status"] = *params.Status
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		// a map is used so that zero values can be written, updated_at is set by gorm
+		if err := tx.Model(product).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).First(product).Error
+	})
+	if err != nil {
+		return nil, code.NewCustomError(errCode, httpStatus, err)
+	}
+	return toDomainProduct(product), nil
+}
+
+// PurchaseProduct takes the quantity from the stock of an active product and returns the updated product
+func (r *ProductRepository) PurchaseProduct(ctx context.Context, id int, quantity int) (*domain.Product, *code.CustomError) {
+	httpStatus := http.StatusInternalServerError
+	errCode := code.DBError
+	product := &model.Product{}
+	err := GetWith(ctx).Transaction(func(tx *gorm.DB) error {
+		// the stock is checked in the update, so that concurrent purchases never take more than there is
+		result := tx.Model(&model.Product{}).
+			Where("id = ? AND status = ? AND stock >= ?", id, domain.ProductStatusActive, quantity).
+			Update("stock", gorm.Expr("stock - ?", quantity))
+		if result.Error != nil {
+			return result.Error
+		}
+		err := tx.Where("id = ?", id).First(product).Error
+		if IsRecordNotFoundError(err) {
+			httpStatus = http.StatusNotFound
+			errCode = code.ProductNotFound
+			return err
+		} else if err != nil {
+			return err
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+		if product.Status != domain.ProductStatusActive {
+			httpStatus = http.StatusNotFound
+			errCode = code.ProductNotFound
+			return fmt.Errorf("product is not active")
+		}
+		httpStatus = http.StatusConflict
+		errCode = code.OutOfStock
+		return fmt.Errorf("product is out of stock")
+	})
+	if err != nil {
+		return nil, code.NewCustomError(errCode, httpStatus, err)
+	}
+	return toDomainProduct(product), nil
+}
+
+// ListProducts lists a page of the products, the latest first, and the number of products matching the filters
+func (r *ProductRepository) ListProducts(ctx context.Context, params *domain.ListProductsParams) ([]domain.Product, int64, *code.CustomError) {
+	query := GetWith(ctx).Model(&model.Product{})
+	if params.Status != "" {
+		query = query.Where("status = ?", params.Status)
+	}
+	if params.Category != "" {
+		query = query.Where("category = ?", params.Category)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+
+	products := []model.Product{}
+	err := query.
+		Order("id DESC").
+		Offset(params.Offset).
+		Limit(params.Limit).
+		Find(&products).Error
+	if err != nil {
+		return nil, 0, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return toDomainProducts(products), total, nil
 }
+
+// ListProductsByIDs lists the products of the ids, the unknown ids are skipped
+func (r *ProductRepository) ListProductsByIDs(ctx context.Context, ids []int) ([]domain.Product, *code.CustomError) {
+	if len(ids) == 0 {
+		return []domain.Product{}, nil
+	}
+	products := []model.Product{}
+	err := GetWith(ctx).
+		Where("id IN ?", ids).
+		Find(&products).Error
+	if err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return toDomainProducts(products), nil
+}
+
+// ListPopularProducts lists the active products in stock, by the weighted interactions since params.Since and then the latest first
+func (r *ProductRepository) ListPopularProducts(ctx context.Context, params *domain.PopularProductsParams) ([]domain.Product, *code.CustomError) {
+	query := GetWith(ctx).
+		Table(model.TableNameProduct).
+		Select("products.*, "+interactionScore+" AS score").
+		Joins("LEFT JOIN product_interactions ON product_interactions.product_id = products.id AND product_interactions.created_at >= ?", params.Since).
+		Where("products.status = ? AND products.stock > 0", domain.ProductStatusActive)
+	if len(params.Categories) > 0 {
+		query = query.Where("products.category IN ?", params.Categories)
+	}
+	if len(params.ExcludeIDs) > 0 {
+		query = query.Where("products.id NOT IN ?", params.ExcludeIDs)
+	}
+
+	products := []model.Product{}
+	// the other columns depend on the primary key, so grouping by it is allowed by mysql and postgres
+	err := query.
+		Group("products.id").
+		Order("score DESC, products.created_at DESC, products.id DESC").
+		Limit(params.Limit).
+		Find(&products).Error
+	if err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return toDomainProducts(products), nil
+}
+
+// interactionScore sums domain.InteractionWeights of the joined product_interactions
+var interactionScore = func() string {
+	types := make([]string, 0, len(domain.InteractionWeights))
+	for t := range domain.InteractionWeights {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	cases := ""
+	for _, t := range types {
+		cases += fmt.Sprintf(" WHEN '%s' THEN %d", t, domain.InteractionWeights[t])
+	}
+	return "COALESCE(SUM(CASE product_interactions.type" + cases + " ELSE 0 END), 0)"
+}()
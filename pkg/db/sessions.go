@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/db/model"
+)
+
+// CreateSession records a new login session
+func CreateSession(ctx context.Context, params *domain.CreateSessionParams) *code.CustomError {
+	now := time.Now()
+	err := GetWith(ctx).Create(&model.Session{
+		JTI:        params.JTI,
+		UID:        params.UID,
+		UserAgent:  params.UserAgent,
+		IP:         params.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}).Error
+	if err != nil {
+		return code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// GetSession gets a session by jti
+func GetSession(ctx context.Context, jti string) (*domain.Session, *code.CustomError) {
+	session := &model.Session{}
+	err := GetWith(ctx).
+		Where("jti = ?", jti).
+		First(session).Error
+	if IsRecordNotFoundError(err) {
+		return nil, code.NewCustomError(code.SessionNotFound, http.StatusNotFound, err)
+	} else if err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return toDomainSession(session), nil
+}
+
+// ListSessions lists the sessions of a user which are not revoked, the latest active one first
+func ListSessions(ctx context.Context, uid string) ([]domain.Session, *code.CustomError) {
+	sessions := []model.Session{}
+	err := GetWith(ctx).
+		Where("uid = ? AND revoked_at IS NULL", uid).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+
+	result := make([]domain.Session, 0, len(sessions))
+	for i := range sessions {
+		result = append(result, *toDomainSession(&sessions[i]))
+	}
+	return result, nil
+}
+
+// RevokeSession revokes a session of a user
+func RevokeSession(ctx context.Context, uid, jti string) *code.CustomError {
+	query := GetWith(ctx).
+		Model(&model.Session{}).
+		Where("uid = ? AND jti = ? AND revoked_at IS NULL", uid, jti).
+		Update("revoked_at", time.Now())
+	if query.Error != nil {
+		return code.NewCustomError(code.DBError, http.StatusInternalServerError, query.Error)
+	}
+	if query.RowsAffected == 0 {
+		return code.NewCustomError(code.SessionNotFound, http.StatusNotFound, fmt.Errorf("session not found"))
+	}
+	return nil
+}
+
+// RevokeOtherSessions revokes all sessions of a user except the given one
+func RevokeOtherSessions(ctx context.Context, uid, exceptJTI string) (int64, *code.CustomError) {
+	query := GetWith(ctx).
+		Model(&model.Session{}).
+		Where("uid = ? AND jti <> ? AND revoked_at IS NULL", uid, exceptJTI).
+		Update("revoked_at", time.Now())
+	if query.Error != nil {
+		return 0, code.NewCustomError(code.DBError, http.StatusInternalServerError, query.Error)
+	}
+	return query.RowsAffected, nil
+}
+
+// TouchSession updates last_seen_at of a session if it has not been updated since the given time
+func TouchSession(ctx context.Context, jti string, seenAt, notAfter time.Time) *code.CustomError {
+	err := GetWith(ctx).
+		Model(&model.Session{}).
+		Where("jti = ? AND last_seen_at < ?", jti, notAfter).
+		Update("last_seen_at", seenAt).Error
+	if err != nil {
+		return code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// ListRecentSessions lists the latest sessions of a user including revoked ones, the latest created first
+func ListRecentSessions(ctx context.Context, uid string, limit int) ([]domain.Session, *code.CustomError) {
+	sessions := []model.Session{}
+	err := GetWith(ctx).
+		Where("uid = ?", uid).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&sessions).Error
+	if err != nil {
+		return nil, code.NewCustomError(code.DBError, http.StatusInternalServerError, err)
+	}
+
+	result := make([]domain.Session, 0, len(sessions))
+	for i := range sessions {
+		result = append(result, *toDomainSession(&sessions[i]))
+	}
+	return result, nil
+}
+
+func toDomainSession(session *model.Session) *domain.Session {
+	return &domain.Session{
+		JTI:        session.JTI,
+		UID:        session.UID,
+		UserAgent:  session.UserAgent,
+		IP:         session.IP,
+		CreatedAt:  session.CreatedAt,
+		LastSeenAt: session.LastSeenAt,
+		RevokedAt:  session.RevokedAt,
+	}
+}
@@ -0,0 +1,125 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+type ipRange struct {
+	start    net.IP
+	end      net.IP
+	location domain.GeoLocation
+}
+
+// CSVLocator is a GeoIPLocator backed by a GeoLite2 City Blocks style csv file,
+// which has at least the columns network, latitude and longitude
+type CSVLocator struct {
+	ranges []ipRange
+}
+
+// NewCSVLocatorFromFile loads a CSVLocator from a local csv file
+func NewCSVLocatorFromFile(path string) (*CSVLocator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewCSVLocator(f)
+}
+
+// NewCSVLocator loads a CSVLocator from csv content
+func NewCSVLocator(r io.Reader) (*CSVLocator, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"network", "latitude", "longitude"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("geoip csv missing column %s", name)
+		}
+	}
+
+	locator := &CSVLocator{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) != len(header) {
+			continue
+		}
+		_, network, err := net.ParseCIDR(record[columns["network"]])
+		if err != nil {
+			return nil, err
+		}
+		// networks without coordinates are unusable for distance
+		lat, err := strconv.ParseFloat(record[columns["latitude"]], 64)
+		if err != nil {
+			continue
+		}
+		lon, err := strconv.ParseFloat(record[columns["longitude"]], 64)
+		if err != nil {
+			continue
+		}
+		start, end := networkBounds(network)
+		locator.ranges = append(locator.ranges, ipRange{
+			start:    start,
+			end:      end,
+			location: domain.GeoLocation{Latitude: lat, Longitude: lon},
+		})
+	}
+	sort.Slice(locator.ranges, func(i, j int) bool {
+		return bytes.Compare(locator.ranges[i].start, locator.ranges[j].start) < 0
+	})
+	return locator, nil
+}
+
+// Locate looks up the location of an ip
+func (l *CSVLocator) Locate(ip net.IP) (*domain.GeoLocation, bool) {
+	ip = ip.To16()
+	if ip == nil {
+		return nil, false
+	}
+	// find the last range which starts before or at ip
+	i := sort.Search(len(l.ranges), func(i int) bool {
+		return bytes.Compare(l.ranges[i].start, ip) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(ip, l.ranges[i].end) > 0 {
+		return nil, false
+	}
+	location := l.ranges[i].location
+	return &location, true
+}
+
+// networkBounds returns the first and last ip of a network in 16-byte form
+func networkBounds(network *net.IPNet) (net.IP, net.IP) {
+	ip := network.IP.To16()
+	mask := network.Mask
+	if len(mask) == net.IPv4len {
+		mask = append(net.CIDRMask(96, 128)[:12:12], mask...)
+	}
+	start := make(net.IP, net.IPv6len)
+	end := make(net.IP, net.IPv6len)
+	for i := range ip {
+		start[i] = ip[i] & mask[i]
+		end[i] = ip[i] | ^mask[i]
+	}
+	return start, end
+}
@@ -11,6 +11,12 @@ import (
 	"github.com/google/uuid"
 )
 
+// TokenData is the data to create a token bound to a known jti
+type TokenData struct {
+	UID string
+	JTI string
+}
+
 // TokenJwtStrategy is the JWT strategy for custom token
 type TokenJwtStrategy struct {
 	secretKey string
@@ -40,11 +46,16 @@ func (s *TokenJwtStrategy) Parse(tokenString string) (any, *code.CustomError) {
 	return claimsI, nil
 }
 
-// CreateToken creates the JWT token
+// CreateToken creates the JWT token. data is either the uid, or a TokenData when the jti is decided by the caller
 func (s *TokenJwtStrategy) CreateToken(data any) (string, error) {
 	now := time.Now()
-	uid, ok := data.(string)
-	if !ok {
+	var uid, jti string
+	switch v := data.(type) {
+	case string:
+		uid, jti = v, uuid.New().String()
+	case TokenData:
+		uid, jti = v.UID, v.JTI
+	default:
 		return "", fmt.Errorf("invalid data type")
 	}
 	expiresAt := now.Add(time.Duration(config.GetInt("ACCESS_TOKEN_EXP_MINUTES")) * time.Minute)
@@ -55,7 +66,7 @@ func (s *TokenJwtStrategy) CreateToken(data any) (string, error) {
 		ExpiresAt: expiresAt.Unix(),
 		NotBefore: now.Unix(),
 		IssuedAt:  now.Unix(),
-		Id:        uuid.New().String(),
+		Id:        jti,
 	}
 	jwtClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims)
 	token, err := jwtClaims.SignedString([]byte(s.secretKey))
@@ -0,0 +1,40 @@
+package repository
+
+import "github.com/Yu-Qi/GoAuth/domain"
+
+var (
+	accountRepo domain.AccountRepository
+	productRepo domain.ProductRepository
+
+	interactionRepo domain.InteractionRepository
+)
+
+// GetAccountRepository returns the account repository
+func GetAccountRepository() domain.AccountRepository {
+	return accountRepo
+}
+
+// InitAccountRepository initializes the account repository
+func InitAccountRepository(r domain.AccountRepository) {
+	accountRepo = r
+}
+
+// GetProductRepository returns the product repository
+func GetProductRepository() domain.ProductRepository {
+	return productRepo
+}
+
+// InitProductRepository initializes the product repository
+func InitProductRepository(r domain.ProductRepository) {
+	productRepo = r
+}
+
+// GetInteractionRepository returns the interaction repository
+func GetInteractionRepository() domain.InteractionRepository {
+	return interactionRepo
+}
+
+// InitInteractionRepository initializes the interaction repository
+func InitInteractionRepository(r domain.InteractionRepository) {
+	interactionRepo = r
+}
@@ -0,0 +1,335 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+)
+
+// AccountRepository keeps accounts in memory, it is meant for tests
+type AccountRepository struct {
+	mu       sync.Mutex
+	accounts map[string]*domain.Account
+	// history is the previous password hashes by uid, the oldest first
+	history map[string][]string
+	emails  []*domain.EmailMessage
+	// sessions is the sessions by jti
+	sessions map[string]*domain.Session
+}
+
+var _ domain.AccountRepository = (*AccountRepository)(nil)
+
+// NewAccountRepository creates an empty AccountRepository
+func NewAccountRepository() *AccountRepository {
+	return &AccountRepository{
+		accounts: map[string]*domain.Account{},
+		history:  map[string][]string{},
+		sessions: map[string]*domain.Session{},
+	}
+}
+
+// AddSession adds an active session of an account
+func (r *AccountRepository) AddSession(uid, jti string) {
+	_ = r.CreateSession(context.Background(), &domain.CreateSessionParams{UID: uid, JTI: jti})
+}
+
+// Sessions returns the jtis of the active sessions of an account
+func (r *AccountRepository) Sessions(uid string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	jtis := []string{}
+	for jti, session := range r.sessions {
+		if session.UID == uid && session.RevokedAt == nil {
+			jtis = append(jtis, jti)
+		}
+	}
+	sort.Strings(jtis)
+	return jtis
+}
+
+// Emails returns the verification emails queued with the created accounts
+func (r *AccountRepository) Emails() []*domain.EmailMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*domain.EmailMessage{}, r.emails...)
+}
+
+// find returns the stored account for which match is true, the caller holds the lock
+func (r *AccountRepository) find(match func(*domain.Account) bool) *domain.Account {
+	for _, account := range r.accounts {
+		if match(account) {
+			return account
+		}
+	}
+	return nil
+}
+
+func notFound() *code.CustomError {
+	return code.NewCustomError(code.UserNotFound, http.StatusBadRequest, fmt.Errorf("account not found"))
+}
+
+// copyOf keeps callers from changing a stored account
+func copyOf(account *domain.Account) *domain.Account {
+	c := *account
+	return &c
+}
+
+// CreateAccount creates a new account
+func (r *AccountRepository) CreateAccount(ctx context.Context, params *domain.CreateAccountParams) *code.CustomError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.accounts[params.UID]; ok || r.find(func(a *domain.Account) bool { return a.Email == params.Email }) != nil {
+		return code.NewCustomError(code.AccountAlreadyExists, http.StatusBadRequest, fmt.Errorf("account already exists"))
+	}
+	r.accounts[params.UID] = &domain.Account{
+		UID:            params.UID,
+		Email:          params.Email,
+		HashedPassword: params.HashedPassword,
+		Locale:         params.Locale,
+	}
+	if params.VerificationEmail != nil {
+		r.emails = append(r.emails, params.VerificationEmail)
+	}
+	return nil
+}
+
+// GetAccount gets an account by uid
+func (r *AccountRepository) GetAccount(ctx context.Context, uid string) (*domain.Account, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[uid]
+	if !ok {
+		return nil, notFound()
+	}
+	return copyOf(account), nil
+}
+
+// GetAccountByEmail gets an account by email
+func (r *AccountRepository) GetAccountByEmail(ctx context.Context, email string) (*domain.Account, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account := r.find(func(a *domain.Account) bool { return a.Email == email })
+	if account == nil {
+		return nil, notFound()
+	}
+	return copyOf(account), nil
+}
+
+// GetAccountByPhone gets the account of a verified phone number
+func (r *AccountRepository) GetAccountByPhone(ctx context.Context, phone string) (*domain.Account, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account := r.find(func(a *domain.Account) bool { return phone != "" && a.Phone == phone })
+	if account == nil {
+		return nil, notFound()
+	}
+	return copyOf(account), nil
+}
+
+// ActivateAccount activates an account
+func (r *AccountRepository) ActivateAccount(ctx context.Context, uid string) *code.CustomError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[uid]
+	if !ok {
+		return notFound()
+	}
+	if account.IsActive {
+		return code.NewCustomError(code.AccountAlreadyActive, http.StatusBadRequest, fmt.Errorf("account already active"))
+	}
+	account.IsActive = true
+	return nil
+}
+
+// UserExists checks if a user exists and is active
+func (r *AccountRepository) UserExists(ctx context.Context, uid string) *code.CustomError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[uid]
+	if !ok {
+		return code.NewCustomError(code.UserNotFound, http.StatusNotFound, fmt.Errorf("account not found"))
+	}
+	if !account.IsActive {
+		return code.NewCustomError(code.AccountNotActive, http.StatusBadRequest, fmt.Errorf("account not active"))
+	}
+	return nil
+}
+
+// UpdateAccount updates an account
+func (r *AccountRepository) UpdateAccount(ctx context.Context, uid string, params *domain.UpdateAccountParams) *code.CustomError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[uid]
+	if !ok {
+		return notFound()
+	}
+	if params.HashedPassword != nil {
+		account.HashedPassword = *params.HashedPassword
+	}
+	if params.SentAt != nil {
+		sentAt := *params.SentAt
+		account.SentAt = &sentAt
+	}
+	if params.PasswordResetRequired != nil {
+		account.PasswordResetRequired = *params.PasswordResetRequired
+	}
+	return nil
+}
+
+// SetPhone sets the verified phone number of an account
+func (r *AccountRepository) SetPhone(ctx context.Context, uid, phone string) *code.CustomError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[uid]
+	if !ok {
+		return notFound()
+	}
+	if owner := r.find(func(a *domain.Account) bool { return a.Phone == phone }); owner != nil && owner.UID != uid {
+		return code.NewCustomError(code.PhoneAlreadyUsed, http.StatusBadRequest, fmt.Errorf("phone is used by another account"))
+	}
+	account.Phone = phone
+	return nil
+}
+
+// ListPasswordHistory lists the latest previous password hashes of a user, the latest first
+func (r *AccountRepository) ListPasswordHistory(ctx context.Context, uid string, limit int) ([]string, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	history := r.history[uid]
+	hashes := []string{}
+	for i := len(history) - 1; i >= 0 && len(hashes) < limit; i-- {
+		hashes = append(hashes, history[i])
+	}
+	return hashes, nil
+}
+
+// ChangePassword replaces the password of an account, keeps the previous hash in the history,
+// and prunes the history to the given depth
+func (r *AccountRepository) ChangePassword(ctx context.Context, params *domain.ChangePasswordParams) *code.CustomError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	account, ok := r.accounts[params.UID]
+	if !ok {
+		return notFound()
+	}
+	if params.HistoryDepth > 0 {
+		history := append(r.history[params.UID], account.HashedPassword)
+		if len(history) > params.HistoryDepth {
+			history = history[len(history)-params.HistoryDepth:]
+		}
+		r.history[params.UID] = history
+	}
+	account.HashedPassword = params.HashedPassword
+	if params.ClearResetRequired {
+		account.PasswordResetRequired = false
+	}
+	return nil
+}
+
+// CreateSession records a login session of an account
+func (r *AccountRepository) CreateSession(ctx context.Context, params *domain.CreateSessionParams) *code.CustomError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.sessions[params.JTI] = &domain.Session{
+		JTI:        params.JTI,
+		UID:        params.UID,
+		UserAgent:  params.UserAgent,
+		IP:         params.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	return nil
+}
+
+// GetSession gets a session by jti
+func (r *AccountRepository) GetSession(ctx context.Context, jti string) (*domain.Session, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[jti]
+	if !ok {
+		return nil, code.NewCustomError(code.SessionNotFound, http.StatusNotFound, fmt.Errorf("session not found"))
+	}
+	copied := *session
+	return &copied, nil
+}
+
+// listSessions returns the sessions of an account for which match is true sorted by less, the caller holds the lock
+func (r *AccountRepository) listSessions(uid string, match func(*domain.Session) bool, less func(a, b *domain.Session) bool) []domain.Session {
+	sessions := []domain.Session{}
+	for _, session := range r.sessions {
+		if session.UID == uid && match(session) {
+			sessions = append(sessions, *session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return less(&sessions[i], &sessions[j]) })
+	return sessions
+}
+
+// ListSessions lists the sessions of an account which are not revoked, the latest active one first
+func (r *AccountRepository) ListSessions(ctx context.Context, uid string) ([]domain.Session, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.listSessions(uid,
+		func(s *domain.Session) bool { return s.RevokedAt == nil },
+		func(a, b *domain.Session) bool { return a.LastSeenAt.After(b.LastSeenAt) },
+	), nil
+}
+
+// ListRecentSessions lists the latest sessions of an account including the revoked ones, the latest created first
+func (r *AccountRepository) ListRecentSessions(ctx context.Context, uid string, limit int) ([]domain.Session, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sessions := r.listSessions(uid,
+		func(s *domain.Session) bool { return true },
+		func(a, b *domain.Session) bool { return a.CreatedAt.After(b.CreatedAt) },
+	)
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	return sessions, nil
+}
+
+// TouchSession sets the last seen time of a session unless it has been set since notAfter
+func (r *AccountRepository) TouchSession(ctx context.Context, jti string, seenAt, notAfter time.Time) *code.CustomError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session, ok := r.sessions[jti]; ok && session.LastSeenAt.Before(notAfter) {
+		session.LastSeenAt = seenAt
+	}
+	return nil
+}
+
+// RevokeSession revokes a session of an account
+func (r *AccountRepository) RevokeSession(ctx context.Context, uid, jti string) *code.CustomError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[jti]
+	if !ok || session.UID != uid || session.RevokedAt != nil {
+		return code.NewCustomError(code.SessionNotFound, http.StatusNotFound, fmt.Errorf("session not found"))
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	return nil
+}
+
+// RevokeOtherSessions revokes the sessions of an account except exceptJTI, empty for every session
+func (r *AccountRepository) RevokeOtherSessions(ctx context.Context, uid, exceptJTI string) (int64, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	revoked := int64(0)
+	for jti, session := range r.sessions {
+		if session.UID == uid && jti != exceptJTI && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			revoked++
+		}
+	}
+	return revoked, nil
+}
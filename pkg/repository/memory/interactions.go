@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+)
+
+// InteractionRepository keeps interactions in memory, it is meant for tests
+type InteractionRepository struct {
+	mu sync.Mutex
+	// interactions are in the order they are created
+	interactions []domain.Interaction
+}
+
+var _ domain.InteractionRepository = (*InteractionRepository)(nil)
+
+// NewInteractionRepository creates an empty InteractionRepository
+func NewInteractionRepository() *InteractionRepository {
+	return &InteractionRepository{}
+}
+
+// CreateInteractions records interactions
+func (r *InteractionRepository) CreateInteractions(ctx context.Context, interactions []domain.Interaction) *code.CustomError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, interactions...)
+	return nil
+}
+
+// ListInteractions lists the latest interactions of a user, the latest first
+func (r *InteractionRepository) ListInteractions(ctx context.Context, uid string, limit int) ([]domain.Interaction, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	interactions := []domain.Interaction{}
+	for i := len(r.interactions) - 1; i >= 0 && len(interactions) < limit; i-- {
+		if r.interactions[i].UID == uid {
+			interactions = append(interactions, r.interactions[i])
+		}
+	}
+	return interactions, nil
+}
+
+// scores sums domain.InteractionWeights of the interactions since by product id
+func (r *InteractionRepository) scores(since time.Time) map[int]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	scores := map[int]int{}
+	for _, interaction := range r.interactions {
+		if !interaction.CreatedAt.Before(since) {
+			scores[interaction.ProductID] += domain.InteractionWeights[interaction.Type]
+		}
+	}
+	return scores
+}
+
+// ListRelatedProductIDs lists the other products which the users of params.ProductIDs interacted with,
+// by the number of such users, the most first
+func (r *InteractionRepository) ListRelatedProductIDs(ctx context.Context, params *domain.RelatedProductsParams) ([]int, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seeds := map[int]bool{}
+	for _, id := range params.ProductIDs {
+		seeds[id] = true
+	}
+	// the users who interacted with the seeds, and then the other products of them
+	users := map[string]bool{}
+	for _, interaction := range r.interactions {
+		if seeds[interaction.ProductID] && interaction.UID != params.ExcludeUID && !interaction.CreatedAt.Before(params.Since) {
+			users[interaction.UID] = true
+		}
+	}
+	usersOf := map[int]map[string]bool{}
+	for _, interaction := range r.interactions {
+		if !users[interaction.UID] || seeds[interaction.ProductID] || interaction.CreatedAt.Before(params.Since) {
+			continue
+		}
+		if usersOf[interaction.ProductID] == nil {
+			usersOf[interaction.ProductID] = map[string]bool{}
+		}
+		usersOf[interaction.ProductID][interaction.UID] = true
+	}
+
+	ids := make([]int, 0, len(usersOf))
+	for id := range usersOf {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if len(usersOf[ids[i]]) != len(usersOf[ids[j]]) {
+			return len(usersOf[ids[i]]) > len(usersOf[ids[j]])
+		}
+		return ids[i] > ids[j]
+	})
+	if params.Limit < len(ids) {
+		ids = ids[:params.Limit]
+	}
+	return ids, nil
+}
+
+// CountOutcomes counts the interactions recorded in the experiment by variant and type
+func (r *InteractionRepository) CountOutcomes(ctx context.Context, experiment string) ([]domain.VariantOutcome, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	type group struct{ variant, interactionType string }
+	events := map[group]int64{}
+	users := map[group]map[string]bool{}
+	for _, interaction := range r.interactions {
+		if interaction.Experiment != experiment {
+			continue
+		}
+		g := group{interaction.Variant, interaction.Type}
+		events[g]++
+		if users[g] == nil {
+			users[g] = map[string]bool{}
+		}
+		users[g][interaction.UID] = true
+	}
+
+	outcomes := make([]domain.VariantOutcome, 0, len(events))
+	for g, n := range events {
+		outcomes = append(outcomes, domain.VariantOutcome{Variant: g.variant, Type: g.interactionType, Events: n, Users: int64(len(users[g]))})
+	}
+	sort.Slice(outcomes, func(i, j int) bool {
+		if outcomes[i].Variant != outcomes[j].Variant {
+			return outcomes[i].Variant < outcomes[j].Variant
+		}
+		return outcomes[i].Type < outcomes[j].Type
+	})
+	return outcomes, nil
+}
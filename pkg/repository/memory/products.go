@@ -0,0 +1,201 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+)
+
+// ProductRepository keeps products in memory, it is meant for tests
+type ProductRepository struct {
+	// Interactions ranks the popular products, none is counted if it is nil
+	Interactions *InteractionRepository
+
+	mu       sync.Mutex
+	products []domain.Product
+	nextID   int
+}
+
+var _ domain.ProductRepository = (*ProductRepository)(nil)
+
+// NewProductRepository creates a ProductRepository with the given products, the products without an ID get one
+func NewProductRepository(products ...domain.Product) *ProductRepository {
+	r := &ProductRepository{}
+	for _, product := range products {
+		if product.ID == 0 {
+			product.ID = r.nextID + 1
+		}
+		if product.ID > r.nextID {
+			r.nextID = product.ID
+		}
+		r.products = append(r.products, product)
+	}
+	return r
+}
+
+// index returns the position of the product, -1 if none, the caller holds the lock
+func (r *ProductRepository) index(id int) int {
+	for i := range r.products {
+		if r.products[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// latestFirst returns the products matching filter, the latest first, the caller holds the lock
+func (r *ProductRepository) latestFirst(filter func(*domain.Product) bool) []domain.Product {
+	products := []domain.Product{}
+	for i := range r.products {
+		if filter(&r.products[i]) {
+			products = append(products, r.products[i])
+		}
+	}
+	sort.SliceStable(products, func(i, j int) bool { return products[i].ID > products[j].ID })
+	return products
+}
+
+// CreateProduct creates a new product
+func (r *ProductRepository) CreateProduct(ctx context.Context, params *domain.CreateProductParams) (*domain.Product, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	now := time.Now()
+	product := domain.Product{
+		ID:          r.nextID,
+		Name:        params.Name,
+		Description: params.Description,
+		Price:       params.Price,
+		Category:    params.Category,
+		Stock:       params.Stock,
+		Status:      params.Status,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if product.Status == "" {
+		product.Status = domain.ProductStatusActive
+	}
+	r.products = append(r.products, product)
+	return &product, nil
+}
+
+// GetProduct gets a product by id
+func (r *ProductRepository) GetProduct(ctx context.Context, id int) (*domain.Product, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id)
+	if i < 0 {
+		return nil, code.NewCustomError(code.ProductNotFound, http.StatusNotFound, fmt.Errorf("product not found"))
+	}
+	product := r.products[i]
+	return &product, nil
+}
+
+// UpdateProduct updates a product and returns the updated product
+func (r *ProductRepository) UpdateProduct(ctx context.Context, id int, params *domain.UpdateProductParams) (*domain.Product, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id)
+	if i < 0 {
+		return nil, code.NewCustomError(code.ProductNotFound, http.StatusNotFound, fmt.Errorf("product not found"))
+	}
+	product := &r.products[i]
+	if params.Name != nil {
+		product.Name = *params.Name
+	}
+	if params.Description != nil {
+		product.Description = *params.Description
+	}
+	if params.Price != nil {
+		product.Price = *params.Price
+	}
+	if params.Category != nil {
+		product.Category = *params.Category
+	}
+	if params.Stock != nil {
+		product.Stock = *params.Stock
+	}
+	if params.Status != nil {
+		product.Status = *params.Status
+	}
+	product.UpdatedAt = time.Now()
+	updated := *product
+	return &updated, nil
+}
+
+// PurchaseProduct takes the quantity from the stock of an active product and returns the updated product
+func (r *ProductRepository) PurchaseProduct(ctx context.Context, id int, quantity int) (*domain.Product, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.index(id)
+	if i < 0 || r.products[i].Status != domain.ProductStatusActive {
+		return nil, code.NewCustomError(code.ProductNotFound, http.StatusNotFound, fmt.Errorf("product not found"))
+	}
+	product := &r.products[i]
+	if product.Stock < quantity {
+		return nil, code.NewCustomError(code.OutOfStock, http.StatusConflict, fmt.Errorf("product is out of stock"))
+	}
+	product.Stock -= quantity
+	product.UpdatedAt = time.Now()
+	updated := *product
+	return &updated, nil
+}
+
+// ListProducts lists a page of the products, the latest first, and the number of products matching the filters
+func (r *ProductRepository) ListProducts(ctx context.Context, params *domain.ListProductsParams) ([]domain.Product, int64, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	products := r.latestFirst(func(p *domain.Product) bool {
+		return (params.Status == "" || p.Status == params.Status) && (params.Category == "" || p.Category == params.Category)
+	})
+	total := int64(len(products))
+	if params.Offset >= len(products) {
+		return []domain.Product{}, total, nil
+	}
+	products = products[params.Offset:]
+	if params.Limit < len(products) {
+		products = products[:params.Limit]
+	}
+	return products, total, nil
+}
+
+// ListProductsByIDs lists the products of the ids, the unknown ids are skipped
+func (r *ProductRepository) ListProductsByIDs(ctx context.Context, ids []int) ([]domain.Product, *code.CustomError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	products := []domain.Product{}
+	for _, id := range ids {
+		if i := r.index(id); i >= 0 {
+			products = append(products, r.products[i])
+		}
+	}
+	return products, nil
+}
+
+// ListPopularProducts lists the active products in stock, by the weighted interactions since params.Since and then the latest first
+func (r *ProductRepository) ListPopularProducts(ctx context.Context, params *domain.PopularProductsParams) ([]domain.Product, *code.CustomError) {
+	scores := map[int]int{}
+	if r.Interactions != nil {
+		scores = r.Interactions.scores(params.Since)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	products := r.latestFirst(func(p *domain.Product) bool {
+		return p.Status == domain.ProductStatusActive && p.Stock > 0 &&
+			(len(params.Categories) == 0 || slices.Contains(params.Categories, p.Category)) &&
+			!slices.Contains(params.ExcludeIDs, p.ID)
+	})
+	sort.SliceStable(products, func(i, j int) bool { return scores[products[i].ID] > scores[products[j].ID] })
+	if params.Limit < len(products) {
+		products = products[:params.Limit]
+	}
+	return products, nil
+}
@@ -2,15 +2,20 @@ package accounts
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/Yu-Qi/GoAuth/domain"
 	"github.com/Yu-Qi/GoAuth/pkg/code"
-	"github.com/Yu-Qi/GoAuth/pkg/db"
+	"github.com/Yu-Qi/GoAuth/pkg/service/password"
 	"github.com/Yu-Qi/GoAuth/pkg/util"
 )
 
@@ -18,31 +23,24 @@ import (
 type RegisterParams struct {
 	Email    string
 	Password string
+	// Locale chooses the language of the emails, such as zh-TW
+	Locale string
 }
 
 // Register registers a new account
-func Register(ctx context.Context, account *RegisterParams, verificationSvc domain.VerificationCodeService, sendEmailSvc domain.SendEmailService) (customErr *code.CustomError) {
+func Register(ctx context.Context, account *RegisterParams, accountRepo domain.AccountRepository, verificationSvc domain.VerificationCodeService, sendEmailSvc domain.SendEmailService, passwordHasher domain.PasswordHasher) (customErr *code.CustomError) {
 	uid := util.UUID()
 	logrus.WithFields(logrus.Fields{
 		"uid":   uid,
 		"email": account.Email,
 	}).Debug("Registering new account")
 
-	hashedPassword, err := util.GenerateBcryptPassword(account.Password)
+	hashedPassword, err := passwordHasher.Hash(ctx, account.Password)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"error": err.Error(),
-		}).Warn("Register, GenerateBcryptPassword")
-		return
-	}
-
-	// check if account already exists
-	if customErr := db.CreateAccount(ctx, &db.CreateAccountParams{
-		UID:            uid,
-		Email:          account.Email,
-		HashedPassword: string(hashedPassword),
-	}); customErr != nil {
-		return customErr
+		}).Warn("Register, Hash")
+		return passwordHashError(err)
 	}
 
 	verificationCode, err := verificationSvc.GenerateCode(uid)
@@ -51,36 +49,71 @@ func Register(ctx context.Context, account *RegisterParams, verificationSvc doma
 			"uid":   uid,
 			"email": account.Email,
 			"error": err.Error(),
-		}).Error("Register, already registered, but failed to generate verification code")
+		}).Error("Register, failed to generate verification code")
 		return code.NewCustomError(code.CryptoError, http.StatusInternalServerError, err)
 	}
 
-	err = sendEmailSvc.SendEmail(account.Email, "Verification Code", verificationCode)
+	// the verification email is queued with the account, and delivered by the outbox dispatcher
+	if customErr := accountRepo.CreateAccount(ctx, &domain.CreateAccountParams{
+		UID:            uid,
+		Email:          account.Email,
+		HashedPassword: hashedPassword,
+		Locale:         account.Locale,
+		VerificationEmail: &domain.EmailMessage{
+			ID:       domain.EmailTemplateVerifyEmail + "/" + uid,
+			To:       account.Email,
+			Template: domain.EmailTemplateVerifyEmail,
+			Locale:   account.Locale,
+			Data: map[string]interface{}{
+				"Code": verificationCode,
+			},
+		},
+	}); customErr != nil {
+		if enumerationProtection && customErr.Code == code.AccountAlreadyExists {
+			return notifyAlreadyRegistered(ctx, account.Email, account.Locale, sendEmailSvc)
+		}
+		return customErr
+	}
+
+	return nil
+}
+
+// notifyAlreadyRegistered tells the owner of an email that someone tried to register it again,
+// the response to the client is the same as a new registration
+func notifyAlreadyRegistered(ctx context.Context, email, locale string, sendEmailSvc domain.SendEmailService) *code.CustomError {
+	err := sendEmailSvc.SendEmail(ctx, &domain.EmailMessage{
+		ID:       emailID(domain.EmailTemplateAlreadyRegistered, strings.ToLower(email), alreadyRegisteredEmailWindow, time.Now()),
+		To:       email,
+		Template: domain.EmailTemplateAlreadyRegistered,
+		Locale:   locale,
+	})
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
-			"uid":   uid,
-			"email": account.Email,
+			"email": email,
 			"error": err.Error(),
-		}).Error("Register, already registered, but failed to send email")
+		}).Error("Register, failed to send already registered email")
 		return code.NewCustomError(code.SendEmailError, http.StatusInternalServerError, err)
 	}
-	// update sent_at
-	db.UpdateAccount(ctx, uid, &domain.UpdateAccountParams{
-		SentAt: util.Ptr(time.Now()),
-	})
-
 	return nil
 }
 
+// emailID returns the idempotency key of an email about subject. The emails about the same subject in a window of time
+// are one email, so that repeated requests do not flood the inbox. The subject is hashed, as the key is sent to the
+// email providers.
+func emailID(template, subject string, window time.Duration, now time.Time) string {
+	sum := sha256.Sum256([]byte(subject))
+	return fmt.Sprintf("%s/%s/%d", template, hex.EncodeToString(sum[:8]), now.Unix()/int64(window/time.Second))
+}
+
 // VerifyEmail verifies the email
-func VerifyEmail(ctx context.Context, verificationCode string, verificationSvc domain.VerificationCodeService) (customErr *code.CustomError) {
+func VerifyEmail(ctx context.Context, verificationCode string, accountRepo domain.AccountRepository, verificationSvc domain.VerificationCodeService) (customErr *code.CustomError) {
 	uid, err := verificationSvc.VerifyCode(verificationCode)
 	if err != nil {
 		return code.NewCustomError(code.CryptoError, http.StatusBadRequest, err)
 	}
 
 	// activate the account
-	customError := db.ActiveAccount(ctx, uid)
+	customError := accountRepo.ActivateAccount(ctx, uid)
 	if customError != nil {
 		return customError
 	}
@@ -88,23 +121,67 @@ func VerifyEmail(ctx context.Context, verificationCode string, verificationSvc d
 	return nil
 }
 
+var (
+	dummyHash     string
+	dummyHashLock sync.Mutex
+)
+
+// dummyPasswordHash returns a hash with the same cost as real passwords, compared when the email is unknown.
+// It is made with the current algorithm, so until every legacy bcrypt hash is rehashed on login, an account with
+// one can be told from an unknown email by the response time. This is accepted, as it only exposes the accounts which
+// have not logged in since the algorithm changed, and they become fewer as the accounts log in.
+func dummyPasswordHash(ctx context.Context, passwordHasher domain.PasswordHasher) (string, error) {
+	dummyHashLock.Lock()
+	defer dummyHashLock.Unlock()
+	if dummyHash == "" {
+		hashed, err := passwordHasher.Hash(ctx, util.RandString(16))
+		if err != nil {
+			return "", err
+		}
+		dummyHash = hashed
+	}
+	return dummyHash, nil
+}
+
+// passwordHashError converts an error of hashing to a custom error, saturated hashing is reported as 503
+func passwordHashError(err error) *code.CustomError {
+	if errors.Is(err, password.ErrBusy) {
+		return code.NewCustomError(code.ServiceBusy, http.StatusServiceUnavailable, err)
+	}
+	return code.NewCustomError(code.CryptoError, http.StatusInternalServerError, err)
+}
+
 // LoginParams is the parameters for login
 type LoginParams struct {
 	Email    string
 	Password string
 }
 
-// Login login an active account
-func Login(ctx context.Context, params *LoginParams) (string, *code.CustomError) {
+// Login login an active account. A password hashed with an outdated policy is rehashed transparently.
+func Login(ctx context.Context, params *LoginParams, accountRepo domain.AccountRepository, passwordHasher domain.PasswordHasher) (string, *code.CustomError) {
 	// check if account already exists
-	account, customErr := db.Login(ctx, &domain.Account{
-		Email: params.Email,
-	})
+	account, customErr := accountRepo.GetAccountByEmail(ctx, params.Email)
 	if customErr != nil {
-		return "", customErr
+		if customErr.Code != code.UserNotFound {
+			return "", customErr
+		}
+		if enumerationProtection {
+			// pay the same cost as a known email so that the response time does not tell it is unknown
+			hash, err := dummyPasswordHash(ctx, passwordHasher)
+			if err == nil {
+				err = passwordHasher.Compare(ctx, hash, params.Password)
+			}
+			if errors.Is(err, password.ErrBusy) {
+				return "", passwordHashError(err)
+			}
+		}
+		return "", code.NewCustomError(code.AccountOrPasswordIncorrect, http.StatusBadRequest, fmt.Errorf("account or password incorrect"))
 	}
 	// check if password is correct
-	if util.CompareBcryptPassword(account.HashedPassword, params.Password) != nil {
+	if err := passwordHasher.Compare(ctx, account.HashedPassword, params.Password); err != nil {
+		if errors.Is(err, password.ErrBusy) {
+			return "", passwordHashError(err)
+		}
 		return "", code.NewCustomError(code.AccountOrPasswordIncorrect, http.StatusBadRequest, fmt.Errorf("account or password incorrect"))
 	}
 
@@ -113,5 +190,46 @@ func Login(ctx context.Context, params *LoginParams) (string, *code.CustomError)
 		return "", code.NewCustomError(code.AccountNotActive, http.StatusBadRequest, fmt.Errorf("account not active"))
 	}
 
+	// check if the password must be reset first, e.g. a login was reported as not made by the user
+	if account.PasswordResetRequired {
+		return "", code.NewCustomError(code.PasswordResetRequired, http.StatusBadRequest, fmt.Errorf("password reset required"))
+	}
+
+	if passwordHasher.NeedsRehash(account.HashedPassword) {
+		rehash(ctx, account.UID, params.Password, accountRepo, passwordHasher)
+	}
+
 	return account.UID, nil
 }
+
+// rehash hashes the password with the current policy. It is only an upgrade, so failures do not fail the login.
+func rehash(ctx context.Context, uid, password string, accountRepo domain.AccountRepository, passwordHasher domain.PasswordHasher) {
+	hashedPassword, err := passwordHasher.Hash(ctx, password)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"uid":   uid,
+			"error": err.Error(),
+		}).Warn("Login, rehash")
+		return
+	}
+	if customErr := accountRepo.UpdateAccount(ctx, uid, &domain.UpdateAccountParams{
+		HashedPassword: &hashedPassword,
+	}); customErr != nil {
+		logrus.WithFields(logrus.Fields{
+			"uid":   uid,
+			"error": customErr.Error.Error(),
+		}).Warn("Login, rehash, UpdateAccount")
+	}
+}
+
+// CheckAdmin checks the account is an active admin
+func CheckAdmin(ctx context.Context, uid string, accountRepo domain.AccountRepository) *code.CustomError {
+	account, customErr := accountRepo.GetAccount(ctx, uid)
+	if customErr != nil {
+		return customErr
+	}
+	if !account.IsActive || !account.IsAdmin {
+		return code.NewCustomError(code.PermissionDenied, http.StatusForbidden, fmt.Errorf("admin only"))
+	}
+	return nil
+}
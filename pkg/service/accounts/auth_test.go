@@ -0,0 +1,156 @@
+package accounts
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/repository/memory"
+	"github.com/Yu-Qi/GoAuth/pkg/service/crypto"
+	"github.com/Yu-Qi/GoAuth/pkg/service/password"
+)
+
+func testServices() (*memory.AccountRepository, domain.VerificationCodeService, domain.PasswordHasher) {
+	crypto.InitService("test-password", "test-salt", 16, 600)
+	return memory.NewAccountRepository(), crypto.GetService(), password.NewPolicyHasher(password.NewBcryptHasher(4))
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	ctx := context.Background()
+	repo, verificationSvc, hasher := testServices()
+
+	customErr := Register(ctx, &RegisterParams{Email: "a@example.com", Password: "Password1!", Locale: "zh-TW"}, repo, verificationSvc, nil, hasher)
+	assert.Nil(t, customErr)
+	emails := repo.Emails()
+	assert.Len(t, emails, 1)
+	assert.Equal(t, "a@example.com", emails[0].To)
+	assert.Equal(t, "zh-TW", emails[0].Locale)
+
+	customErr = Register(ctx, &RegisterParams{Email: "a@example.com", Password: "Password1!"}, repo, verificationSvc, nil, hasher)
+	assert.Equal(t, code.AccountAlreadyExists, customErr.Code)
+
+	// not verified yet
+	_, customErr = Login(ctx, &LoginParams{Email: "a@example.com", Password: "Password1!"}, repo, hasher)
+	assert.Equal(t, code.AccountNotActive, customErr.Code)
+
+	assert.Nil(t, VerifyEmail(ctx, emails[0].Data["Code"].(string), repo, verificationSvc))
+	customErr = VerifyEmail(ctx, emails[0].Data["Code"].(string), repo, verificationSvc)
+	assert.Equal(t, code.AccountAlreadyActive, customErr.Code)
+
+	uid, customErr := Login(ctx, &LoginParams{Email: "a@example.com", Password: "Password1!"}, repo, hasher)
+	assert.Nil(t, customErr)
+	assert.Equal(t, "verify_email/"+uid, emails[0].ID)
+
+	_, customErr = Login(ctx, &LoginParams{Email: "a@example.com", Password: "Password2!"}, repo, hasher)
+	assert.Equal(t, code.AccountOrPasswordIncorrect, customErr.Code)
+	// an unknown email gets the same error as a wrong password
+	_, customErr = Login(ctx, &LoginParams{Email: "b@example.com", Password: "Password1!"}, repo, hasher)
+	assert.Equal(t, code.AccountOrPasswordIncorrect, customErr.Code)
+}
+
+func TestSetPasswordReuse(t *testing.T) {
+	ctx := context.Background()
+	repo, verificationSvc, hasher := testServices()
+	Init(InitParam{PasswordHistoryDepth: 2})
+	defer Init(InitParam{})
+
+	assert.Nil(t, Register(ctx, &RegisterParams{Email: "a@example.com", Password: "Password1!"}, repo, verificationSvc, nil, hasher))
+	account, customErr := repo.GetAccountByEmail(ctx, "a@example.com")
+	assert.Nil(t, customErr)
+
+	setAndReload := func(newPassword string) *code.CustomError {
+		if customErr := setPassword(ctx, account, newPassword, repo, hasher); customErr != nil {
+			return customErr
+		}
+		account, customErr = repo.GetAccount(ctx, account.UID)
+		return customErr
+	}
+	assert.Equal(t, code.PasswordReused, setAndReload("Password1!").Code)
+	assert.Nil(t, setAndReload("Password2!"))
+	assert.Nil(t, setAndReload("Password3!"))
+	assert.Equal(t, code.PasswordReused, setAndReload("Password1!").Code)
+	// the oldest password falls out of the history
+	assert.Nil(t, setAndReload("Password4!"))
+	assert.Nil(t, setAndReload("Password1!"))
+}
+
+func TestChangePasswordRevokesOtherSessions(t *testing.T) {
+	ctx := context.Background()
+	repo, verificationSvc, hasher := testServices()
+
+	assert.Nil(t, Register(ctx, &RegisterParams{Email: "a@example.com", Password: "Password1!"}, repo, verificationSvc, nil, hasher))
+	account, customErr := repo.GetAccountByEmail(ctx, "a@example.com")
+	assert.Nil(t, customErr)
+	repo.AddSession(account.UID, "current")
+	repo.AddSession(account.UID, "other")
+
+	customErr = ChangePassword(ctx, &ChangePasswordParams{
+		UID:         account.UID,
+		CurrentJTI:  "current",
+		OldPassword: "Password1!",
+		NewPassword: "Password2!",
+	}, repo, hasher)
+	assert.Nil(t, customErr)
+	assert.Equal(t, []string{"current"}, repo.Sessions(account.UID))
+}
+
+// sentEmails keeps the emails sent
+type sentEmails struct {
+	messages []*domain.EmailMessage
+}
+
+func (s *sentEmails) SendEmail(ctx context.Context, msg *domain.EmailMessage) error {
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func TestResetPasswordOnce(t *testing.T) {
+	ctx := context.Background()
+	repo, verificationSvc, hasher := testServices()
+
+	assert.Nil(t, Register(ctx, &RegisterParams{Email: "a@example.com", Password: "Password1!"}, repo, verificationSvc, nil, hasher))
+	account, customErr := repo.GetAccountByEmail(ctx, "a@example.com")
+	assert.Nil(t, customErr)
+	repo.AddSession(account.UID, "jti")
+	sent := &sentEmails{}
+	assert.Nil(t, sendResetPasswordEmail(ctx, account, verificationSvc, sent))
+	assert.Len(t, sent.messages, 1)
+	resetCode := sent.messages[0].Data["Code"].(string)
+
+	assert.Nil(t, ResetPassword(ctx, resetCode, "Password2!", repo, verificationSvc, hasher))
+	assert.Empty(t, repo.Sessions(account.UID))
+	// the password has changed, so the code is no longer valid
+	customErr = ResetPassword(ctx, resetCode, "Password3!", repo, verificationSvc, hasher)
+	assert.Equal(t, code.CryptoError, customErr.Code)
+	account, _ = repo.GetAccount(ctx, account.UID)
+	assert.Nil(t, hasher.Compare(ctx, account.HashedPassword, "Password2!"))
+}
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	repo, _, _ := testServices()
+	for _, jti := range []string{"current", "other"} {
+		assert.Nil(t, CreateSession(ctx, &CreateSessionParams{UID: "uid", JTI: jti, UserAgent: strings.Repeat("a", maxUserAgentLength+1)}, repo))
+	}
+
+	sessions, customErr := ListSessions(ctx, "uid", "current", repo)
+	assert.Nil(t, customErr)
+	assert.Len(t, sessions, 2)
+	for _, session := range sessions {
+		assert.Equal(t, session.JTI == "current", session.Current)
+		assert.Len(t, session.UserAgent, maxUserAgentLength)
+	}
+	assert.Nil(t, CheckSession(ctx, "uid", "other", repo))
+	assert.Equal(t, code.TokenInValid, CheckSession(ctx, "another", "other", repo).Code)
+
+	// a revoked session is rejected, and cannot be revoked again
+	assert.Nil(t, RevokeSession(ctx, "uid", "other", repo))
+	assert.Equal(t, code.SessionRevoked, CheckSession(ctx, "uid", "other", repo).Code)
+	assert.Equal(t, code.SessionNotFound, RevokeSession(ctx, "uid", "other", repo).Code)
+	assert.Equal(t, code.TokenInValid, CheckSession(ctx, "uid", "unknown", repo).Code)
+	assert.Equal(t, []string{"current"}, repo.Sessions("uid"))
+}
@@ -1,19 +1,41 @@
 package accounts
 
-// import (
-// 	"github.com/Yu-Qi/GoAuth/domain"
-// )
+import (
+	"context"
+	"time"
 
-// var (
-// 	taskRepo domain.TaskRepository
-// )
+	"github.com/Yu-Qi/GoAuth/domain"
+)
 
-// // InitParam defines the parameters for initializing the service.
-// type InitParam struct {
-// 	TaskRepo domain.TaskRepository
-// }
+const (
+	// alreadyRegisteredEmailWindow and resetPasswordEmailWindow are how often the email can be sent about an email
+	// address or an account, see emailID
+	alreadyRegisteredEmailWindow = time.Hour
+	resetPasswordEmailWindow     = 10 * time.Minute
+)
 
-// // Init injects implementations into the service.
-// func Init(param InitParam) {
-// 	taskRepo = param.TaskRepo
-// }
+var (
+	enumerationProtection bool
+	passwordHistoryDepth  int
+)
+
+// InitParam defines the parameters for initializing the service.
+type InitParam struct {
+	// EnumerationProtection hides whether an email is registered: unknown emails pay the same
+	// password hashing cost on login, and registering a taken email succeeds with a notice email
+	EnumerationProtection bool
+	// PasswordHistoryDepth is the number of previous passwords which can not be reused, 0 disables the check
+	PasswordHistoryDepth int
+	// PasswordHasher warms up the dummy hash of EnumerationProtection, it is optional
+	PasswordHasher domain.PasswordHasher
+}
+
+// Init injects implementations into the service.
+func Init(param InitParam) {
+	enumerationProtection = param.EnumerationProtection
+	passwordHistoryDepth = param.PasswordHistoryDepth
+	if enumerationProtection && param.PasswordHasher != nil {
+		// warm up the dummy hash so that the first unknown email does not pay twice
+		go dummyPasswordHash(context.Background(), param.PasswordHasher)
+	}
+}
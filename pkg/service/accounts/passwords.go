@@ -0,0 +1,170 @@
+package accounts
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/service/password"
+)
+
+const (
+	// resetPasswordCodePrefix keeps a reset password code from being used as another kind of code
+	resetPasswordCodePrefix = "reset-password/"
+	// forgotPasswordTimeout bounds sending a reset password email in the background
+	forgotPasswordTimeout = 30 * time.Second
+)
+
+// ChangePasswordParams is the parameters for changing the password of a logged in account
+type ChangePasswordParams struct {
+	UID         string
+	CurrentJTI  string
+	OldPassword string
+	NewPassword string
+}
+
+// ChangePassword changes the password after checking the old one, and signs out the other sessions
+func ChangePassword(ctx context.Context, params *ChangePasswordParams, accountRepo domain.AccountRepository, passwordHasher domain.PasswordHasher) *code.CustomError {
+	account, customErr := accountRepo.GetAccount(ctx, params.UID)
+	if customErr != nil {
+		return customErr
+	}
+	if err := passwordHasher.Compare(ctx, account.HashedPassword, params.OldPassword); err != nil {
+		if errors.Is(err, password.ErrBusy) {
+			return passwordHashError(err)
+		}
+		return code.NewCustomError(code.AccountOrPasswordIncorrect, http.StatusBadRequest, fmt.Errorf("account or password incorrect"))
+	}
+
+	if customErr := setPassword(ctx, account, params.NewPassword, accountRepo, passwordHasher); customErr != nil {
+		return customErr
+	}
+	if _, customErr := accountRepo.RevokeOtherSessions(ctx, params.UID, params.CurrentJTI); customErr != nil {
+		return customErr
+	}
+	return nil
+}
+
+// ForgotPassword emails a reset password code in the background. The response, and its time, is the same whether
+// the email is registered or not, so that registered emails are not revealed.
+func ForgotPassword(ctx context.Context, email string, accountRepo domain.AccountRepository, verificationSvc domain.VerificationCodeService, sendEmailSvc domain.SendEmailService) *code.CustomError {
+	// the context of the request ends with the response
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), forgotPasswordTimeout)
+		defer cancel()
+		account, customErr := accountRepo.GetAccountByEmail(ctx, email)
+		if customErr != nil {
+			if customErr.Code != code.UserNotFound {
+				logrus.WithFields(logrus.Fields{
+					"error": customErr.Error.Error(),
+				}).Error("ForgotPassword, GetAccountByEmail")
+			}
+			return
+		}
+		_ = sendResetPasswordEmail(ctx, account, verificationSvc, sendEmailSvc)
+	}()
+	return nil
+}
+
+// passwordFingerprint identifies the current password of an account in a reset password code, so that the code
+// stops working once the password is changed, which makes it single use
+func passwordFingerprint(hashedPassword string) string {
+	sum := sha256.Sum256([]byte(hashedPassword))
+	return hex.EncodeToString(sum[:8])
+}
+
+// sendResetPasswordEmail emails a reset password code to the account
+func sendResetPasswordEmail(ctx context.Context, account *domain.Account, verificationSvc domain.VerificationCodeService, sendEmailSvc domain.SendEmailService) *code.CustomError {
+	fingerprint := passwordFingerprint(account.HashedPassword)
+	resetCode, err := verificationSvc.GenerateCode(resetPasswordCodePrefix + account.UID + "/" + fingerprint)
+	if err != nil {
+		return code.NewCustomError(code.CryptoError, http.StatusInternalServerError, err)
+	}
+	err = sendEmailSvc.SendEmail(ctx, &domain.EmailMessage{
+		// a new password gets a new email at once
+		ID:       emailID(domain.EmailTemplateResetPassword, account.UID+"/"+fingerprint, resetPasswordEmailWindow, time.Now()),
+		To:       account.Email,
+		Template: domain.EmailTemplateResetPassword,
+		Locale:   account.Locale,
+		Data: map[string]interface{}{
+			"Code": resetCode,
+		},
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"uid":   account.UID,
+			"error": err.Error(),
+		}).Error("sendResetPasswordEmail, failed to send email")
+		return code.NewCustomError(code.SendEmailError, http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a reset password code, and signs out every session. A code is only valid
+// for the password it was sent for, so it cannot be used again after the reset.
+func ResetPassword(ctx context.Context, resetCode, newPassword string, accountRepo domain.AccountRepository, verificationSvc domain.VerificationCodeService, passwordHasher domain.PasswordHasher) *code.CustomError {
+	invalidCode := code.NewCustomError(code.CryptoError, http.StatusBadRequest, fmt.Errorf("invalid code"))
+	data, err := verificationSvc.VerifyCode(resetCode)
+	if err != nil || !strings.HasPrefix(data, resetPasswordCodePrefix) {
+		return invalidCode
+	}
+	uid, fingerprint, ok := strings.Cut(strings.TrimPrefix(data, resetPasswordCodePrefix), "/")
+	if !ok {
+		return invalidCode
+	}
+
+	account, customErr := accountRepo.GetAccount(ctx, uid)
+	if customErr != nil {
+		return customErr
+	}
+	if subtle.ConstantTimeCompare([]byte(fingerprint), []byte(passwordFingerprint(account.HashedPassword))) != 1 {
+		return invalidCode
+	}
+	if customErr := setPassword(ctx, account, newPassword, accountRepo, passwordHasher); customErr != nil {
+		return customErr
+	}
+	if _, customErr := accountRepo.RevokeOtherSessions(ctx, account.UID, ""); customErr != nil {
+		return customErr
+	}
+	return nil
+}
+
+// setPassword rejects the current and the recent passwords, then replaces the password and clears the reset requirement
+func setPassword(ctx context.Context, account *domain.Account, newPassword string, accountRepo domain.AccountRepository, passwordHasher domain.PasswordHasher) *code.CustomError {
+	if passwordHistoryDepth > 0 {
+		history, customErr := accountRepo.ListPasswordHistory(ctx, account.UID, passwordHistoryDepth)
+		if customErr != nil {
+			return customErr
+		}
+		for _, hash := range append([]string{account.HashedPassword}, history...) {
+			err := passwordHasher.Compare(ctx, hash, newPassword)
+			if err == nil {
+				return code.NewCustomError(code.PasswordReused, http.StatusBadRequest, fmt.Errorf("password was used recently"))
+			}
+			if errors.Is(err, password.ErrBusy) {
+				return passwordHashError(err)
+			}
+		}
+	}
+
+	hashedPassword, err := passwordHasher.Hash(ctx, newPassword)
+	if err != nil {
+		return passwordHashError(err)
+	}
+	return accountRepo.ChangePassword(ctx, &domain.ChangePasswordParams{
+		UID:                account.UID,
+		HashedPassword:     hashedPassword,
+		HistoryDepth:       passwordHistoryDepth,
+		ClearResetRequired: true,
+	})
+}
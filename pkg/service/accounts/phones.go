@@ -0,0 +1,140 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/service/otp"
+)
+
+// e164Pattern is a phone number in E.164, the country code does not start with 0
+var e164Pattern = regexp.MustCompile(`^\+[1-9]\d{7,14}$`)
+
+// sendLoginCodeTimeout bounds sending a login code in the background
+const sendLoginCodeTimeout = 30 * time.Second
+
+// validatePhone rejects a number which is not in E.164, before a code is sent or a limit is counted for it
+func validatePhone(phone string) *code.CustomError {
+	if !e164Pattern.MatchString(phone) {
+		return code.NewCustomError(code.ParamIncorrect, http.StatusBadRequest, fmt.Errorf("phone is not in E.164"))
+	}
+	return nil
+}
+
+// StartPhoneVerification sends a code to a phone number which the account wants to verify
+func StartPhoneVerification(ctx context.Context, uid, phone string, accountRepo domain.AccountRepository, otpSvc domain.OTPService) *code.CustomError {
+	if customErr := validatePhone(phone); customErr != nil {
+		return customErr
+	}
+	account, customErr := accountRepo.GetAccountByPhone(ctx, phone)
+	if customErr == nil && account.UID != uid {
+		return code.NewCustomError(code.PhoneAlreadyUsed, http.StatusBadRequest, fmt.Errorf("phone is used by another account"))
+	} else if customErr != nil && customErr.Code != code.UserNotFound {
+		return customErr
+	}
+
+	// the code proves the phone number to this account only
+	if err := otpSvc.Send(ctx, otp.PurposeVerifyPhone, phone, uid); err != nil {
+		return otpError(err)
+	}
+	return nil
+}
+
+// VerifyPhone sets the phone number of the account after checking the code sent to it
+func VerifyPhone(ctx context.Context, uid, phone, verificationCode string, accountRepo domain.AccountRepository, otpSvc domain.OTPService) *code.CustomError {
+	if customErr := validatePhone(phone); customErr != nil {
+		return customErr
+	}
+	subject, err := otpSvc.Verify(ctx, otp.PurposeVerifyPhone, phone, verificationCode)
+	if err != nil {
+		return otpError(err)
+	}
+	if subject != uid {
+		return otpError(otp.ErrInvalidCode)
+	}
+	return accountRepo.SetPhone(ctx, uid, phone)
+}
+
+// SendLoginCode sends a login code to a verified phone number. Unknown numbers get no code but the
+// same rate limit, and the code is sent in the background, so that neither the response nor its time
+// reveals registered numbers.
+func SendLoginCode(ctx context.Context, phone string, accountRepo domain.AccountRepository, otpSvc domain.OTPService) *code.CustomError {
+	if customErr := validatePhone(phone); customErr != nil {
+		return customErr
+	}
+	account, customErr := accountRepo.GetAccountByPhone(ctx, phone)
+	if customErr != nil && customErr.Code != code.UserNotFound {
+		return customErr
+	}
+	if err := otpSvc.AllowSend(ctx, phone); err != nil {
+		return otpError(err)
+	}
+	if customErr != nil {
+		return nil
+	}
+
+	// the context of the request ends with the response
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sendLoginCodeTimeout)
+		defer cancel()
+		if err := otpSvc.Deliver(ctx, otp.PurposeLogin, phone, account.UID); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err.Error(),
+			}).Error("SendLoginCode, Deliver")
+		}
+	}()
+	return nil
+}
+
+// LoginWithCode logs in the account of a verified phone number with the code sent to it
+func LoginWithCode(ctx context.Context, phone, loginCode string, accountRepo domain.AccountRepository, otpSvc domain.OTPService) (*domain.Account, *code.CustomError) {
+	if customErr := validatePhone(phone); customErr != nil {
+		return nil, customErr
+	}
+	uid, err := otpSvc.Verify(ctx, otp.PurposeLogin, phone, loginCode)
+	if err != nil {
+		return nil, otpError(err)
+	}
+	account, customErr := accountRepo.GetAccountByPhone(ctx, phone)
+	if customErr != nil {
+		return nil, customErr
+	}
+	// the number may have moved to another account since the code was sent
+	if account.UID != uid {
+		return nil, otpError(otp.ErrInvalidCode)
+	}
+
+	// the same checks as a password login
+	if !account.IsActive {
+		return nil, code.NewCustomError(code.AccountNotActive, http.StatusBadRequest, fmt.Errorf("account not active"))
+	}
+	if account.PasswordResetRequired {
+		return nil, code.NewCustomError(code.PasswordResetRequired, http.StatusBadRequest, fmt.Errorf("password reset required"))
+	}
+	return account, nil
+}
+
+// otpError converts an error of the otp service to a custom error
+func otpError(err error) *code.CustomError {
+	switch {
+	case errors.Is(err, otp.ErrInvalidCode):
+		return code.NewCustomError(code.OTPIncorrect, http.StatusBadRequest, err)
+	case errors.Is(err, otp.ErrRateLimited):
+		return code.NewCustomError(code.TooManyRequests, http.StatusTooManyRequests, err)
+	}
+	logrus.WithFields(logrus.Fields{
+		"error": err.Error(),
+	}).Error("otp service")
+	if errors.Is(err, otp.ErrSendSMS) {
+		return code.NewCustomError(code.SendSMSError, http.StatusInternalServerError, err)
+	}
+	return code.NewCustomError(code.CacheError, http.StatusInternalServerError, err)
+}
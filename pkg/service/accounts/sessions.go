@@ -0,0 +1,137 @@
+package accounts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
+)
+
+const (
+	// sessionTouchInterval throttles how often last_seen_at of a session is written
+	sessionTouchInterval = time.Minute
+	// maxUserAgentLength is the length of the user_agent column
+	maxUserAgentLength = 512
+	// notMeCodePrefix keeps a "this wasn't me" code from being used as an email verification code and vice versa
+	notMeCodePrefix = "not-me/"
+)
+
+// CreateSessionParams is the parameters for recording a login session
+type CreateSessionParams struct {
+	UID       string
+	JTI       string
+	UserAgent string
+	IP        string
+}
+
+// CreateSession records a login session keyed by the jti of the access token
+func CreateSession(ctx context.Context, params *CreateSessionParams, accountRepo domain.AccountRepository) *code.CustomError {
+	userAgent := params.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	return accountRepo.CreateSession(ctx, &domain.CreateSessionParams{
+		JTI:       params.JTI,
+		UID:       params.UID,
+		UserAgent: userAgent,
+		IP:        params.IP,
+	})
+}
+
+// CheckSession checks the session of a token is still valid, and refreshes its last seen time
+func CheckSession(ctx context.Context, uid, jti string, accountRepo domain.AccountRepository) *code.CustomError {
+	session, customErr := accountRepo.GetSession(ctx, jti)
+	if customErr != nil {
+		if customErr.Code == code.SessionNotFound {
+			return code.NewCustomError(code.TokenInValid, http.StatusUnauthorized, fmt.Errorf("session not found"))
+		}
+		return customErr
+	}
+	if session.UID != uid {
+		return code.NewCustomError(code.TokenInValid, http.StatusUnauthorized, fmt.Errorf("session not found"))
+	}
+	if session.RevokedAt != nil {
+		return code.NewCustomError(code.SessionRevoked, http.StatusUnauthorized, fmt.Errorf("session revoked"))
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		if customErr := accountRepo.TouchSession(ctx, jti, now, now.Add(-sessionTouchInterval)); customErr != nil {
+			// last seen is informative only, do not block the request
+			logrus.WithFields(logrus.Fields{
+				"jti":   jti,
+				"error": customErr.Error.Error(),
+			}).Warn("CheckSession, TouchSession")
+		}
+	}
+	return nil
+}
+
+// ListSessions lists the active sessions of a user and marks the current one
+func ListSessions(ctx context.Context, uid, currentJTI string, accountRepo domain.AccountRepository) ([]domain.Session, *code.CustomError) {
+	sessions, customErr := accountRepo.ListSessions(ctx, uid)
+	if customErr != nil {
+		return nil, customErr
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].JTI == currentJTI
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes one session of a user
+func RevokeSession(ctx context.Context, uid, jti string, accountRepo domain.AccountRepository) *code.CustomError {
+	return accountRepo.RevokeSession(ctx, uid, jti)
+}
+
+// RevokeOtherSessions revokes all sessions of a user except the current one
+func RevokeOtherSessions(ctx context.Context, uid, currentJTI string, accountRepo domain.AccountRepository) (int64, *code.CustomError) {
+	return accountRepo.RevokeOtherSessions(ctx, uid, currentJTI)
+}
+
+// GenerateNotMeCode generates the code of a "this wasn't me" link for a session
+func GenerateNotMeCode(jti string, verificationSvc domain.VerificationCodeService) (string, error) {
+	return verificationSvc.GenerateCode(notMeCodePrefix + jti)
+}
+
+// ReportUnrecognizedSession handles a "this wasn't me" report: all sessions of the account are revoked
+// and the password must be reset before the next login, a reset password code is emailed for it
+func ReportUnrecognizedSession(ctx context.Context, notMeCode string, accountRepo domain.AccountRepository, verificationSvc domain.VerificationCodeService, sendEmailSvc domain.SendEmailService) *code.CustomError {
+	data, err := verificationSvc.VerifyCode(notMeCode)
+	if err != nil || !strings.HasPrefix(data, notMeCodePrefix) {
+		return code.NewCustomError(code.CryptoError, http.StatusBadRequest, fmt.Errorf("invalid code"))
+	}
+
+	session, customErr := accountRepo.GetSession(ctx, strings.TrimPrefix(data, notMeCodePrefix))
+	if customErr != nil {
+		return customErr
+	}
+
+	if _, customErr := accountRepo.RevokeOtherSessions(ctx, session.UID, ""); customErr != nil {
+		return customErr
+	}
+	if customErr := accountRepo.UpdateAccount(ctx, session.UID, &domain.UpdateAccountParams{
+		PasswordResetRequired: util.Ptr(true),
+	}); customErr != nil {
+		return customErr
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"uid": session.UID,
+		"jti": session.JTI,
+	}).Warn("Session reported as unrecognized, all sessions revoked")
+
+	// the login is blocked until the reset, so the way to reset is sent right away
+	account, customErr := accountRepo.GetAccount(ctx, session.UID)
+	if customErr != nil {
+		return customErr
+	}
+	return sendResetPasswordEmail(ctx, account, verificationSvc, sendEmailSvc)
+}
@@ -0,0 +1,122 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/breaker"
+)
+
+var providerResults = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "email_provider_results_total",
+	Help: "Number of emails by provider and result, which is sent, failed, rejected by the provider or skipped while the circuit is open.",
+}, []string{"provider", "result"})
+
+// Provider is a named way of sending emails, such as an email API or an SMTP server
+type Provider interface {
+	domain.SendEmailService
+	Name() string
+}
+
+// FailoverParams is the parameters for creating a FailoverEmailService
+type FailoverParams struct {
+	// Providers are tried in order
+	Providers []Provider
+	// FailureThreshold and OpenTimeout configure the circuit breaker of each provider
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+type guardedProvider struct {
+	Provider
+	breaker *breaker.Breaker
+}
+
+// send sends an email through the circuit breaker. A permanent error means the provider is up, so it
+// is not counted as a failure.
+func (p guardedProvider) send(ctx context.Context, msg *domain.EmailMessage) error {
+	if err := p.breaker.Allow(); err != nil {
+		return err
+	}
+	err := p.SendEmail(ctx, msg)
+	if err != nil && !IsPermanent(err) {
+		p.breaker.Failure()
+		return err
+	}
+	p.breaker.Success()
+	return err
+}
+
+// IsPermanent returns true if err is a ProviderError which should not be retried
+func IsPermanent(err error) bool {
+	providerErr := &ProviderError{}
+	return errors.As(err, &providerErr) && providerErr.Permanent()
+}
+
+// FailoverEmailService sends an email with the first provider which succeeds. A provider which keeps
+// failing is skipped by its circuit breaker, until a trial email succeeds after the open timeout.
+// An email rejected with a permanent error is not sent with the next provider.
+type FailoverEmailService struct {
+	providers []guardedProvider
+}
+
+// NewFailoverEmailService creates a FailoverEmailService
+func NewFailoverEmailService(params FailoverParams) *FailoverEmailService {
+	s := &FailoverEmailService{}
+	for _, provider := range params.Providers {
+		s.providers = append(s.providers, guardedProvider{
+			Provider: provider,
+			breaker: breaker.New(breaker.Params{
+				FailureThreshold: params.FailureThreshold,
+				OpenTimeout:      params.OpenTimeout,
+			}),
+		})
+	}
+	return s
+}
+
+// SendEmail sends an email, it fails with the errors of every provider if none succeeds
+func (s *FailoverEmailService) SendEmail(ctx context.Context, msg *domain.EmailMessage) error {
+	errs := []error{}
+	for _, provider := range s.providers {
+		err := provider.send(ctx, msg)
+		switch {
+		case err == nil:
+			providerResults.WithLabelValues(provider.Name(), "sent").Inc()
+			return nil
+		case errors.Is(err, breaker.ErrOpen):
+			providerResults.WithLabelValues(provider.Name(), "skipped").Inc()
+		case IsPermanent(err):
+			// the email is rejected, the other providers would reject it too
+			providerResults.WithLabelValues(provider.Name(), "rejected").Inc()
+			return fmt.Errorf("%s: %w", provider.Name(), err)
+		default:
+			providerResults.WithLabelValues(provider.Name(), "failed").Inc()
+			logrus.WithFields(logrus.Fields{
+				"provider": provider.Name(),
+				"error":    err.Error(),
+			}).Warn("FailoverEmailService, SendEmail")
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+	}
+	if len(errs) == 0 {
+		return errors.New("no email provider")
+	}
+	return errors.Join(errs...)
+}
+
+// States returns the circuit breaker state of each provider by name
+func (s *FailoverEmailService) States() map[string]breaker.State {
+	states := map[string]breaker.State{}
+	for _, provider := range s.providers {
+		states[provider.Name()] = provider.breaker.State()
+	}
+	return states
+}
@@ -0,0 +1,107 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/breaker"
+)
+
+// fakeProvider fails while err is set and counts the calls
+type fakeProvider struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) SendEmail(ctx context.Context, msg *domain.EmailMessage) error {
+	p.calls++
+	return p.err
+}
+
+func TestFailoverEmailService(t *testing.T) {
+	primary := &fakeProvider{name: "primary", err: errors.New("timeout")}
+	secondary := &fakeProvider{name: "secondary"}
+	svc := NewFailoverEmailService(FailoverParams{
+		Providers:        []Provider{primary, secondary},
+		FailureThreshold: 2,
+		OpenTimeout:      time.Hour,
+	})
+
+	// the primary fails over to the secondary until its circuit opens
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, svc.SendEmail(context.Background(), testMessage))
+	}
+	assert.Equal(t, 2, primary.calls)
+	assert.Equal(t, 4, secondary.calls)
+	assert.Equal(t, breaker.Open, svc.States()["primary"])
+	assert.Equal(t, breaker.Closed, svc.States()["secondary"])
+
+	// every provider failing returns all the errors
+	secondary.err = errors.New("refused")
+	err := svc.SendEmail(context.Background(), testMessage)
+	assert.ErrorIs(t, err, breaker.ErrOpen)
+	assert.ErrorIs(t, err, secondary.err)
+}
+
+func TestFailoverEmailServiceHTTP(t *testing.T) {
+	down := &providerStub{status: http.StatusServiceUnavailable}
+	up := &providerStub{status: http.StatusAccepted}
+	svc := NewFailoverEmailService(FailoverParams{
+		Providers: []Provider{
+			NewResendEmailService(HTTPProviderConfig{BaseURL: down.start(t), APIKey: "key", From: "no-reply@goauth.local"}),
+			NewSendGridEmailService(HTTPProviderConfig{BaseURL: up.start(t), APIKey: "key", From: "no-reply@goauth.local"}),
+		},
+		FailureThreshold: 5,
+		OpenTimeout:      time.Minute,
+	})
+
+	assert.Nil(t, svc.SendEmail(context.Background(), testMessage))
+	assert.Equal(t, "/emails", down.path)
+	assert.Equal(t, "/v3/mail/send", up.path)
+}
+
+func TestFailoverEmailServicePermanentError(t *testing.T) {
+	rejected := &ProviderError{Provider: "primary", StatusCode: http.StatusUnprocessableEntity}
+	primary := &fakeProvider{name: "primary", err: rejected}
+	secondary := &fakeProvider{name: "secondary"}
+	svc := NewFailoverEmailService(FailoverParams{
+		Providers:        []Provider{primary, secondary},
+		FailureThreshold: 1,
+		OpenTimeout:      time.Hour,
+	})
+
+	// a rejected email is neither sent with the secondary nor opens the circuit
+	for i := 0; i < 2; i++ {
+		err := svc.SendEmail(context.Background(), testMessage)
+		assert.ErrorIs(t, err, rejected)
+		assert.True(t, IsPermanent(err))
+	}
+	assert.Equal(t, 2, primary.calls)
+	assert.Equal(t, 0, secondary.calls)
+	assert.Equal(t, breaker.Closed, svc.States()["primary"])
+
+	// a rate limit or an invalid API key is a failure of the primary, the email is sent with the secondary
+	for i, status := range []int{http.StatusTooManyRequests, http.StatusUnauthorized, http.StatusForbidden} {
+		primary.err = &ProviderError{Provider: "primary", StatusCode: status}
+		assert.False(t, IsPermanent(primary.err), status)
+		svc := NewFailoverEmailService(FailoverParams{
+			Providers:        []Provider{primary, secondary},
+			FailureThreshold: 1,
+			OpenTimeout:      time.Hour,
+		})
+		assert.Nil(t, svc.SendEmail(context.Background(), testMessage), status)
+		assert.Equal(t, i+1, secondary.calls, status)
+		assert.Equal(t, breaker.Open, svc.States()["primary"], status)
+	}
+}
@@ -0,0 +1,213 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+// maxErrorBodyLength bounds the response body kept in a ProviderError
+const maxErrorBodyLength = 512
+
+// HTTPProviderConfig is the config of an email API provider
+type HTTPProviderConfig struct {
+	// BaseURL is optional, the public API of the provider is used when it is empty
+	BaseURL string
+	APIKey  string
+	From    string
+	// Client is optional, it should have a timeout
+	Client *http.Client
+}
+
+func (cfg HTTPProviderConfig) baseURL(defaultURL string) string {
+	if cfg.BaseURL != "" {
+		return strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+	return defaultURL
+}
+
+func (cfg HTTPProviderConfig) client() *http.Client {
+	if cfg.Client != nil {
+		return cfg.Client
+	}
+	return http.DefaultClient
+}
+
+// ProviderError is an unexpected response of an email API
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+// Error .
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("unexpected status %d from %s: %s", e.StatusCode, e.Provider, e.Body)
+}
+
+// Permanent returns true if the provider rejected the email itself, such as an invalid recipient or
+// content, so sending it again or with another provider would not help. Any other status, such as
+// an invalid API key, a timeout or a rate limit, is a failure of the provider.
+func (e *ProviderError) Permanent() bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return true
+	}
+	return false
+}
+
+// postJSON posts payload as JSON and returns a ProviderError unless the response status is 2xx
+func postJSON(ctx context.Context, provider string, client *http.Client, url string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyLength))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &ProviderError{Provider: provider, StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+	return nil
+}
+
+// SendGridEmailService sends emails with the SendGrid v3 mail send API
+type SendGridEmailService struct {
+	cfg HTTPProviderConfig
+}
+
+// NewSendGridEmailService creates a SendGridEmailService
+func NewSendGridEmailService(cfg HTTPProviderConfig) *SendGridEmailService {
+	return &SendGridEmailService{cfg: cfg}
+}
+
+// Name .
+func (s *SendGridEmailService) Name() string {
+	return "sendgrid"
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// SendEmail sends an email
+func (s *SendGridEmailService) SendEmail(ctx context.Context, msg *domain.EmailMessage) error {
+	from := sendGridAddress{Email: s.cfg.From}
+	if parsed, err := mail.ParseAddress(s.cfg.From); err == nil {
+		from = sendGridAddress{Email: parsed.Address, Name: parsed.Name}
+	}
+	content := []sendGridContent{{Type: "text/plain", Value: msg.Text}}
+	if msg.HTML != "" {
+		content = append(content, sendGridContent{Type: "text/html", Value: msg.HTML})
+	}
+	payload := map[string]interface{}{
+		"personalizations": []map[string]interface{}{
+			{"to": []sendGridAddress{{Email: msg.To}}},
+		},
+		"from":    from,
+		"subject": msg.Subject,
+		"content": content,
+	}
+	if msg.ID != "" {
+		payload["custom_args"] = map[string]string{"idempotency_key": msg.ID}
+	}
+	return postJSON(ctx, s.Name(), s.cfg.client(), s.cfg.baseURL("https://api.sendgrid.com")+"/v3/mail/send", map[string]string{
+		"Authorization": "Bearer " + s.cfg.APIKey,
+	}, payload)
+}
+
+// PostmarkEmailService sends emails with the Postmark email API
+type PostmarkEmailService struct {
+	cfg HTTPProviderConfig
+}
+
+// NewPostmarkEmailService creates a PostmarkEmailService, the APIKey is the server token
+func NewPostmarkEmailService(cfg HTTPProviderConfig) *PostmarkEmailService {
+	return &PostmarkEmailService{cfg: cfg}
+}
+
+// Name .
+func (s *PostmarkEmailService) Name() string {
+	return "postmark"
+}
+
+// SendEmail sends an email
+func (s *PostmarkEmailService) SendEmail(ctx context.Context, msg *domain.EmailMessage) error {
+	payload := map[string]interface{}{
+		"From":          s.cfg.From,
+		"To":            msg.To,
+		"Subject":       msg.Subject,
+		"TextBody":      msg.Text,
+		"MessageStream": "outbound",
+	}
+	if msg.HTML != "" {
+		payload["HtmlBody"] = msg.HTML
+	}
+	if msg.ID != "" {
+		payload["Metadata"] = map[string]string{"idempotency_key": msg.ID}
+	}
+	return postJSON(ctx, s.Name(), s.cfg.client(), s.cfg.baseURL("https://api.postmarkapp.com")+"/email", map[string]string{
+		"X-Postmark-Server-Token": s.cfg.APIKey,
+	}, payload)
+}
+
+// ResendEmailService sends emails with the Resend email API
+type ResendEmailService struct {
+	cfg HTTPProviderConfig
+}
+
+// NewResendEmailService creates a ResendEmailService
+func NewResendEmailService(cfg HTTPProviderConfig) *ResendEmailService {
+	return &ResendEmailService{cfg: cfg}
+}
+
+// Name .
+func (s *ResendEmailService) Name() string {
+	return "resend"
+}
+
+// SendEmail sends an email, a retried email with the same ID is not sent twice
+func (s *ResendEmailService) SendEmail(ctx context.Context, msg *domain.EmailMessage) error {
+	payload := map[string]interface{}{
+		"from":    s.cfg.From,
+		"to":      []string{msg.To},
+		"subject": msg.Subject,
+		"text":    msg.Text,
+	}
+	if msg.HTML != "" {
+		payload["html"] = msg.HTML
+	}
+	headers := map[string]string{
+		"Authorization": "Bearer " + s.cfg.APIKey,
+	}
+	if msg.ID != "" {
+		headers["Idempotency-Key"] = msg.ID
+	}
+	return postJSON(ctx, s.Name(), s.cfg.client(), s.cfg.baseURL("https://api.resend.com")+"/emails", headers, payload)
+}
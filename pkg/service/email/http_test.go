@@ -0,0 +1,89 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+// providerStub records the last request and responds with status
+type providerStub struct {
+	status int
+	path   string
+	header http.Header
+	body   map[string]interface{}
+}
+
+func (p *providerStub) start(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.path = r.URL.Path
+		p.header = r.Header
+		p.body = map[string]interface{}{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&p.body))
+		w.WriteHeader(p.status)
+		w.Write([]byte(`{"message":"stub"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+var testMessage = &domain.EmailMessage{
+	ID:      "verify_email/uid",
+	To:      "a@example.com",
+	Subject: "Verify your email",
+	Text:    "code",
+	HTML:    "<p>code</p>",
+}
+
+func TestSendGridEmailService(t *testing.T) {
+	stub := &providerStub{status: http.StatusAccepted}
+	svc := NewSendGridEmailService(HTTPProviderConfig{BaseURL: stub.start(t), APIKey: "key", From: "GoAuth <no-reply@goauth.local>"})
+
+	assert.Nil(t, svc.SendEmail(context.Background(), testMessage))
+	assert.Equal(t, "/v3/mail/send", stub.path)
+	assert.Equal(t, "Bearer key", stub.header.Get("Authorization"))
+	assert.Equal(t, map[string]interface{}{"email": "no-reply@goauth.local", "name": "GoAuth"}, stub.body["from"])
+	assert.Equal(t, "a@example.com", stub.body["personalizations"].([]interface{})[0].(map[string]interface{})["to"].([]interface{})[0].(map[string]interface{})["email"])
+	assert.Len(t, stub.body["content"], 2)
+
+	stub.status = http.StatusUnauthorized
+	err := svc.SendEmail(context.Background(), testMessage)
+	providerErr := &ProviderError{}
+	if assert.ErrorAs(t, err, &providerErr) {
+		assert.Equal(t, http.StatusUnauthorized, providerErr.StatusCode)
+		assert.Equal(t, "sendgrid", providerErr.Provider)
+	}
+}
+
+func TestPostmarkEmailService(t *testing.T) {
+	stub := &providerStub{status: http.StatusOK}
+	svc := NewPostmarkEmailService(HTTPProviderConfig{BaseURL: stub.start(t), APIKey: "token", From: "no-reply@goauth.local"})
+
+	assert.Nil(t, svc.SendEmail(context.Background(), testMessage))
+	assert.Equal(t, "/email", stub.path)
+	assert.Equal(t, "token", stub.header.Get("X-Postmark-Server-Token"))
+	assert.Equal(t, "a@example.com", stub.body["To"])
+	assert.Equal(t, "code", stub.body["TextBody"])
+	assert.Equal(t, "<p>code</p>", stub.body["HtmlBody"])
+
+	stub.status = http.StatusUnprocessableEntity
+	assert.NotNil(t, svc.SendEmail(context.Background(), testMessage))
+}
+
+func TestResendEmailService(t *testing.T) {
+	stub := &providerStub{status: http.StatusOK}
+	svc := NewResendEmailService(HTTPProviderConfig{BaseURL: stub.start(t) + "/", APIKey: "key", From: "no-reply@goauth.local"})
+
+	assert.Nil(t, svc.SendEmail(context.Background(), testMessage))
+	assert.Equal(t, "/emails", stub.path)
+	assert.Equal(t, "Bearer key", stub.header.Get("Authorization"))
+	assert.Equal(t, "verify_email/uid", stub.header.Get("Idempotency-Key"))
+	assert.Equal(t, []interface{}{"a@example.com"}, stub.body["to"])
+	assert.Equal(t, "<p>code</p>", stub.body["html"])
+}
@@ -1,6 +1,11 @@
 package email
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
 
 // PrintEmailService is a service that prints the email to the console
 type PrintEmailService struct{}
@@ -9,8 +14,13 @@ func NewPrintEmailService() *PrintEmailService {
 	return &PrintEmailService{}
 }
 
-// SendEmail prints the email to the console
-func (p PrintEmailService) SendEmail(email string, subject string, body string) error {
-	fmt.Printf("Email: %s\nSubject: %s\nBody: %s\n", email, subject, body)
+// Name .
+func (p PrintEmailService) Name() string {
+	return "print"
+}
+
+// SendEmail prints the email to the console, the html body is left out
+func (p PrintEmailService) SendEmail(ctx context.Context, email *domain.EmailMessage) error {
+	fmt.Printf("Email: %s\nSubject: %s\nBody: %s\n", email.To, email.Subject, email.Text)
 	return nil
 }
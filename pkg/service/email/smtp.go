@@ -0,0 +1,351 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
+)
+
+// TLS modes of SMTPConfig
+const (
+	SMTPTLSNone     = "none"
+	SMTPTLSStartTLS = "starttls"
+	SMTPTLSImplicit = "implicit"
+)
+
+// auth mechanisms of SMTPConfig
+const (
+	SMTPAuthNone  = "none"
+	SMTPAuthPlain = "plain"
+	SMTPAuthLogin = "login"
+)
+
+// SMTPConfig is the config of SMTPEmailService
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// Auth is one of SMTPAuthNone, SMTPAuthPlain and SMTPAuthLogin
+	Auth string
+	// TLS is one of SMTPTLSNone, SMTPTLSStartTLS and SMTPTLSImplicit
+	TLS  string
+	From string
+	// Timeout bounds dialing and sending a message
+	Timeout time.Duration
+	// IdleTimeout closes a reused connection which has not sent for a while, instead of finding it dropped by the server
+	IdleTimeout time.Duration
+	// TLSConfig is optional, the default verifies the certificate of Host
+	TLSConfig *tls.Config
+}
+
+// SMTPEmailService sends emails through an SMTP server, reusing one connection between emails
+type SMTPEmailService struct {
+	cfg SMTPConfig
+
+	mu       sync.Mutex
+	conn     net.Conn
+	client   *smtp.Client
+	lastUsed time.Time
+}
+
+// NewSMTPEmailService creates a SMTPEmailService
+func NewSMTPEmailService(cfg SMTPConfig) *SMTPEmailService {
+	return &SMTPEmailService{cfg: cfg}
+}
+
+// Name .
+func (s *SMTPEmailService) Name() string {
+	return "smtp"
+}
+
+// SendEmail sends an email, as multipart/alternative when it has a html body
+func (s *SMTPEmailService) SendEmail(ctx context.Context, email *domain.EmailMessage) error {
+	msg, err := buildMessage(s.cfg.From, email)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reused := s.client != nil
+	err = s.send(email.To, msg)
+	if err != nil && reused && !isSMTPReply(err) {
+		// the reused connection may have been dropped by the server, retry once on a new one
+		s.closeLocked()
+		err = s.send(email.To, msg)
+	}
+	if err != nil && !isSMTPReply(err) {
+		s.closeLocked()
+	}
+	return err
+}
+
+// Close closes the reused connection
+func (s *SMTPEmailService) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.Quit()
+	s.closeLocked()
+	return err
+}
+
+func (s *SMTPEmailService) send(to string, msg []byte) error {
+	client, err := s.connection()
+	if err != nil {
+		return err
+	}
+	if err := s.conn.SetDeadline(time.Now().Add(s.cfg.Timeout)); err != nil {
+		return err
+	}
+
+	if err := client.Mail(envelopeAddress(s.cfg.From)); err != nil {
+		_ = client.Reset()
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		_ = client.Reset()
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		_ = client.Reset()
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	s.lastUsed = time.Now()
+	return nil
+}
+
+// connection returns the reused client, or dials a new one if there is none or it has been idle too long
+func (s *SMTPEmailService) connection() (*smtp.Client, error) {
+	if s.client != nil {
+		if s.cfg.IdleTimeout > 0 && time.Since(s.lastUsed) > s.cfg.IdleTimeout {
+			_ = s.client.Quit()
+			s.closeLocked()
+		} else {
+			return s.client, nil
+		}
+	}
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+	var conn net.Conn
+	var err error
+	if s.cfg.TLS == SMTPTLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, s.tlsConfig())
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(s.cfg.Timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := s.handshake(client); err != nil {
+		client.Close()
+		return nil, err
+	}
+	s.conn = conn
+	s.client = client
+	s.lastUsed = time.Now()
+	return client, nil
+}
+
+func (s *SMTPEmailService) handshake(client *smtp.Client) error {
+	if err := client.Hello("localhost"); err != nil {
+		return err
+	}
+	if s.cfg.TLS == SMTPTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server %s does not support STARTTLS", s.cfg.Host)
+		}
+		if err := client.StartTLS(s.tlsConfig()); err != nil {
+			return err
+		}
+	}
+
+	var auth smtp.Auth
+	switch s.cfg.Auth {
+	case SMTPAuthPlain:
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	case SMTPAuthLogin:
+		auth = &loginAuth{username: s.cfg.Username, password: s.cfg.Password, host: s.cfg.Host}
+	}
+	if auth != nil {
+		return client.Auth(auth)
+	}
+	return nil
+}
+
+func (s *SMTPEmailService) tlsConfig() *tls.Config {
+	if s.cfg.TLSConfig != nil {
+		return s.cfg.TLSConfig
+	}
+	return &tls.Config{ServerName: s.cfg.Host}
+}
+
+func (s *SMTPEmailService) closeLocked() {
+	if s.client != nil {
+		s.client.Close()
+	}
+	s.client = nil
+	s.conn = nil
+}
+
+// isSMTPReply reports whether err is a reply of the server, which means the connection is still usable
+func isSMTPReply(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr)
+}
+
+// loginAuth implements the AUTH LOGIN mechanism, which net/smtp does not provide
+type loginAuth struct {
+	username, password, host string
+}
+
+// Start .
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// like smtp.PlainAuth, never send credentials in clear text except to localhost
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+// Next .
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected server challenge %q", fromServer)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+// buildMessage builds a RFC 5322 message with quoted-printable UTF-8 bodies,
+// the text and html bodies are the alternatives of a multipart/alternative message
+func buildMessage(from string, email *domain.EmailMessage) ([]byte, error) {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", email.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", messageID(email.ID), domainOf(from))
+	if email.Locale != "" {
+		fmt.Fprintf(&buf, "Content-Language: %s\r\n", email.Locale)
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if email.HTML == "" {
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&buf, email.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	// the last alternative is the preferred one
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", email.Text},
+		{"text/html; charset=UTF-8", email.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// messageID derives the local part of the Message-ID from the idempotency key, so that a retried email
+// has the same Message-ID and can be deduplicated by the receiver
+func messageID(id string) string {
+	if id == "" {
+		return util.UUID()
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16])
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// envelopeAddress returns the bare address of "Name <user@host>"
+func envelopeAddress(address string) string {
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		return parsed.Address
+	}
+	return address
+}
+
+func domainOf(address string) string {
+	address = envelopeAddress(address)
+	if i := strings.LastIndexByte(address, '@'); i >= 0 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
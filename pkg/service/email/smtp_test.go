@@ -0,0 +1,169 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/smtpsink"
+)
+
+func startSink(t *testing.T) (*smtpsink.Sink, int) {
+	sink := smtpsink.New()
+	addr, err := sink.Start("127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { sink.Close() })
+	return sink, addr.(*net.TCPAddr).Port
+}
+
+func TestSMTPEmailService(t *testing.T) {
+	for _, auth := range []string{SMTPAuthPlain, SMTPAuthLogin} {
+		t.Run(auth, func(t *testing.T) {
+			sink, port := startSink(t)
+			svc := NewSMTPEmailService(SMTPConfig{
+				Host:     "127.0.0.1",
+				Port:     port,
+				Username: "user",
+				Password: "secret",
+				Auth:     auth,
+				TLS:      SMTPTLSNone,
+				From:     "GoAuth <no-reply@goauth.local>",
+				Timeout:  5 * time.Second,
+			})
+			defer svc.Close()
+
+			assert.Nil(t, svc.SendEmail(context.Background(), &domain.EmailMessage{To: "a@example.com", Subject: "Verify Email", Text: "code-1"}))
+			assert.Nil(t, svc.SendEmail(context.Background(), &domain.EmailMessage{To: "b@example.com", Subject: "驗證信", Text: "code-2"}))
+
+			messages := sink.Messages()
+			assert.Len(t, messages, 2)
+			assert.Equal(t, "user", messages[0].Username)
+			assert.Equal(t, "no-reply@goauth.local", messages[0].From)
+			assert.Equal(t, []string{"a@example.com"}, messages[0].To)
+			assert.Equal(t, "Verify Email", messages[0].Subject)
+			assert.Contains(t, messages[0].Data, "code-1")
+			assert.Equal(t, "驗證信", messages[1].Subject)
+			// both emails are sent on the same connection
+			assert.Equal(t, 1, sink.Connections())
+		})
+	}
+}
+
+func TestSMTPEmailServiceMultipart(t *testing.T) {
+	sink, port := startSink(t)
+	svc := NewSMTPEmailService(SMTPConfig{
+		Host:    "127.0.0.1",
+		Port:    port,
+		Auth:    SMTPAuthNone,
+		TLS:     SMTPTLSNone,
+		From:    "no-reply@goauth.local",
+		Timeout: 5 * time.Second,
+	})
+	defer svc.Close()
+
+	assert.Nil(t, svc.SendEmail(context.Background(), &domain.EmailMessage{
+		To:      "a@example.com",
+		Subject: "Verify your email",
+		Text:    "code: abc",
+		HTML:    "<p>code: <b>abc</b></p>",
+	}))
+	messages := sink.Messages()
+	assert.Len(t, messages, 1)
+
+	msg, err := mail.ReadMessage(strings.NewReader(messages[0].Data))
+	assert.Nil(t, err)
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	assert.Nil(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	contentTypes := []string{}
+	bodies := []string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(part)
+		contentTypes = append(contentTypes, part.Header.Get("Content-Type"))
+		bodies = append(bodies, string(body))
+	}
+	assert.Equal(t, []string{"text/plain; charset=UTF-8", "text/html; charset=UTF-8"}, contentTypes)
+	assert.Equal(t, []string{"code: abc", "<p>code: <b>abc</b></p>"}, bodies)
+}
+
+func TestSMTPEmailServiceReconnect(t *testing.T) {
+	sink, port := startSink(t)
+	svc := NewSMTPEmailService(SMTPConfig{
+		Host:    "127.0.0.1",
+		Port:    port,
+		Auth:    SMTPAuthNone,
+		TLS:     SMTPTLSNone,
+		From:    "no-reply@goauth.local",
+		Timeout: 5 * time.Second,
+	})
+	defer svc.Close()
+
+	assert.Nil(t, svc.SendEmail(context.Background(), &domain.EmailMessage{To: "a@example.com", Subject: "first", Text: "body"}))
+	// the server drops the idle connection
+	svc.mu.Lock()
+	svc.conn.Close()
+	svc.mu.Unlock()
+	assert.Nil(t, svc.SendEmail(context.Background(), &domain.EmailMessage{To: "a@example.com", Subject: "second", Text: "body"}))
+	assert.Len(t, sink.Messages(), 2)
+	assert.Equal(t, 2, sink.Connections())
+}
+
+func TestSMTPSinkHandler(t *testing.T) {
+	sink, port := startSink(t)
+	svc := NewSMTPEmailService(SMTPConfig{
+		Host:    "127.0.0.1",
+		Port:    port,
+		Auth:    SMTPAuthNone,
+		TLS:     SMTPTLSNone,
+		From:    "no-reply@goauth.local",
+		Timeout: 5 * time.Second,
+	})
+	defer svc.Close()
+	assert.Nil(t, svc.SendEmail(context.Background(), &domain.EmailMessage{To: "a@example.com", Subject: "Reset Password", Text: "code"}))
+
+	server := httptest.NewServer(sink.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/messages")
+	assert.Nil(t, err)
+	var messages []smtpsink.Message
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&messages))
+	resp.Body.Close()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "Reset Password", messages[0].Subject)
+
+	resp, err = http.Get(server.URL + "/messages/" + strconv.Itoa(messages[0].ID))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/messages", nil)
+	resp, err = http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/messages/" + strconv.Itoa(messages[0].ID))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+}
@@ -0,0 +1,271 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+	texttemplate "text/template"
+
+	"golang.org/x/text/language"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+const (
+	layoutHTML   = "layout.html.tmpl"
+	layoutText   = "layout.txt.tmpl"
+	partialsHTML = "partials.html.tmpl"
+	partialsText = "partials.txt.tmpl"
+	htmlSuffix   = ".html.tmpl"
+	textSuffix   = ".txt.tmpl"
+)
+
+//go:embed templates
+var defaultTemplates embed.FS
+
+// RendererParams is the parameters for creating a Renderer
+type RendererParams struct {
+	// FS has the layouts at its root and a directory of templates for each locale:
+	//
+	//	layout.html.tmpl, layout.txt.tmpl
+	//	<locale>/partials.html.tmpl, <locale>/partials.txt.tmpl  optional, blocks shared by the locale
+	//	<locale>/<template>.txt.tmpl                              defines "subject" and "content"
+	//	<locale>/<template>.html.tmpl                             optional, defines "content"
+	//
+	// The embedded default templates are used when it is nil.
+	FS fs.FS
+	// DefaultLocale is used when no locale matches the locale of a message
+	DefaultLocale string
+	// Links are the base urls of the links in emails by name, see domain.EmailLinkVerifyEmail
+	Links map[string]string
+}
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	// html is nil for a text only template
+	html *htmltemplate.Template
+}
+
+// Renderer renders an EmailMessage from the template chosen by its Template and Locale.
+// The layouts are inherited through the "content" and "footer" blocks.
+type Renderer struct {
+	templates map[string]map[string]*emailTemplate
+	locales   []string
+	matcher   language.Matcher
+	links     map[string]string
+}
+
+// NewRenderer parses all templates, so that a broken template fails at startup instead of when sending
+func NewRenderer(params RendererParams) (*Renderer, error) {
+	fsys := params.FS
+	if fsys == nil {
+		sub, err := fs.Sub(defaultTemplates, "templates")
+		if err != nil {
+			return nil, err
+		}
+		fsys = sub
+	}
+	if params.DefaultLocale == "" {
+		params.DefaultLocale = "en"
+	}
+
+	r := &Renderer{
+		templates: map[string]map[string]*emailTemplate{},
+		links:     params.Links,
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		templates, err := r.parseLocale(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		r.templates[entry.Name()] = templates
+	}
+	if _, ok := r.templates[params.DefaultLocale]; !ok {
+		return nil, fmt.Errorf("no templates for the default locale %s", params.DefaultLocale)
+	}
+
+	// the default locale goes first, it is what the matcher falls back to
+	tags := []language.Tag{language.Make(params.DefaultLocale)}
+	r.locales = []string{params.DefaultLocale}
+	for locale := range r.templates {
+		if locale != params.DefaultLocale {
+			tags = append(tags, language.Make(locale))
+			r.locales = append(r.locales, locale)
+		}
+	}
+	r.matcher = language.NewMatcher(tags)
+	return r, nil
+}
+
+func (r *Renderer) parseLocale(fsys fs.FS, locale string) (map[string]*emailTemplate, error) {
+	entries, err := fs.ReadDir(fsys, locale)
+	if err != nil {
+		return nil, err
+	}
+	hasFile := func(name string) bool {
+		_, err := fs.Stat(fsys, path.Join(locale, name))
+		return err == nil
+	}
+
+	templates := map[string]*emailTemplate{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, textSuffix) || name == partialsText {
+			continue
+		}
+		purpose := strings.TrimSuffix(name, textSuffix)
+
+		textFiles := []string{layoutText}
+		if hasFile(partialsText) {
+			textFiles = append(textFiles, path.Join(locale, partialsText))
+		}
+		textFiles = append(textFiles, path.Join(locale, name))
+		text, err := texttemplate.New(layoutText).Funcs(texttemplate.FuncMap(r.funcs())).ParseFS(fsys, textFiles...)
+		if err != nil {
+			return nil, err
+		}
+		if text.Lookup("subject") == nil {
+			return nil, fmt.Errorf("%s/%s does not define subject", locale, name)
+		}
+		t := &emailTemplate{text: text}
+
+		if hasFile(purpose + htmlSuffix) {
+			htmlFiles := []string{layoutHTML}
+			if hasFile(partialsHTML) {
+				htmlFiles = append(htmlFiles, path.Join(locale, partialsHTML))
+			}
+			htmlFiles = append(htmlFiles, path.Join(locale, purpose+htmlSuffix))
+			t.html, err = htmltemplate.New(layoutHTML).Funcs(htmltemplate.FuncMap(r.funcs())).ParseFS(fsys, htmlFiles...)
+			if err != nil {
+				return nil, err
+			}
+		}
+		templates[purpose] = t
+	}
+	return templates, nil
+}
+
+func (r *Renderer) funcs() map[string]interface{} {
+	return map[string]interface{}{
+		// link returns the configured url of name with the code, or empty when it is not configured
+		"link": func(name, code string) string {
+			base := r.links[name]
+			if base == "" {
+				return ""
+			}
+			separator := "?"
+			if strings.Contains(base, "?") {
+				separator = "&"
+			}
+			return base + separator + "code=" + url.QueryEscape(code)
+		},
+		"dict": func(pairs ...interface{}) (map[string]interface{}, error) {
+			if len(pairs)%2 != 0 {
+				return nil, fmt.Errorf("dict needs key value pairs")
+			}
+			m := map[string]interface{}{}
+			for i := 0; i < len(pairs); i += 2 {
+				key, ok := pairs[i].(string)
+				if !ok {
+					return nil, fmt.Errorf("dict key %v is not a string", pairs[i])
+				}
+				m[key] = pairs[i+1]
+			}
+			return m, nil
+		},
+	}
+}
+
+// MatchLocale returns the available locale closest to locale, such as zh-TW for zh-Hant, or the default locale
+func (r *Renderer) MatchLocale(locale string) string {
+	if locale == "" {
+		return r.locales[0]
+	}
+	_, index, confidence := r.matcher.Match(language.Make(locale))
+	if confidence == language.No {
+		return r.locales[0]
+	}
+	return r.locales[index]
+}
+
+// Render returns a copy of msg with Subject, Text and HTML rendered from its template
+func (r *Renderer) Render(msg *domain.EmailMessage) (*domain.EmailMessage, error) {
+	locale := r.MatchLocale(msg.Locale)
+	t, ok := r.templates[locale][msg.Template]
+	if !ok {
+		// a template which is not translated yet falls back to the default locale
+		locale = r.locales[0]
+		if t, ok = r.templates[locale][msg.Template]; !ok {
+			return nil, fmt.Errorf("unknown email template %s", msg.Template)
+		}
+	}
+
+	data := map[string]interface{}{}
+	for k, v := range msg.Data {
+		data[k] = v
+	}
+	data["Locale"] = locale
+
+	subject := bytes.Buffer{}
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	data["Subject"] = strings.TrimSpace(subject.String())
+
+	text := bytes.Buffer{}
+	if err := t.text.Execute(&text, data); err != nil {
+		return nil, err
+	}
+
+	rendered := *msg
+	rendered.Locale = locale
+	rendered.Subject = data["Subject"].(string)
+	rendered.Text = strings.TrimSpace(text.String()) + "\n"
+	if t.html != nil {
+		html := bytes.Buffer{}
+		if err := t.html.Execute(&html, data); err != nil {
+			return nil, err
+		}
+		rendered.HTML = html.String()
+	}
+	return &rendered, nil
+}
+
+// TemplateEmailService renders messages with a template before sending them with another SendEmailService
+type TemplateEmailService struct {
+	renderer *Renderer
+	next     domain.SendEmailService
+}
+
+// NewTemplateEmailService creates a TemplateEmailService
+func NewTemplateEmailService(renderer *Renderer, next domain.SendEmailService) *TemplateEmailService {
+	return &TemplateEmailService{
+		renderer: renderer,
+		next:     next,
+	}
+}
+
+// SendEmail renders the message if it has a template, then sends it
+func (s *TemplateEmailService) SendEmail(ctx context.Context, msg *domain.EmailMessage) error {
+	if msg.Template == "" {
+		return s.next.SendEmail(ctx, msg)
+	}
+	rendered, err := s.renderer.Render(msg)
+	if err != nil {
+		return err
+	}
+	return s.next.SendEmail(ctx, rendered)
+}
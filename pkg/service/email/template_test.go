@@ -0,0 +1,119 @@
+package email
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+func newTestRenderer(t *testing.T) *Renderer {
+	renderer, err := NewRenderer(RendererParams{
+		DefaultLocale: "en",
+		Links: map[string]string{
+			domain.EmailLinkVerifyEmail: "https://example.com/verify?from=email",
+		},
+	})
+	assert.Nil(t, err)
+	return renderer
+}
+
+func TestMatchLocale(t *testing.T) {
+	renderer := newTestRenderer(t)
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"", "en"},
+		{"en-US", "en"},
+		{"zh-TW", "zh-TW"},
+		{"zh-Hant", "zh-TW"},
+		{"ja", "en"},
+		{"invalid locale", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			assert.Equal(t, tt.want, renderer.MatchLocale(tt.locale))
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	renderer := newTestRenderer(t)
+
+	msg, err := renderer.Render(&domain.EmailMessage{
+		To:       "a@example.com",
+		Template: domain.EmailTemplateVerifyEmail,
+		Locale:   "zh-TW",
+		Data:     map[string]interface{}{"Code": "a+b/c"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "a@example.com", msg.To)
+	assert.Equal(t, "zh-TW", msg.Locale)
+	assert.Equal(t, "驗證您的 Email", msg.Subject)
+	// the code is escaped in the link, and the layout and footer of the locale are inherited
+	assert.Contains(t, msg.Text, "https://example.com/verify?from=email&code=a%2Bb%2Fc")
+	assert.Contains(t, msg.Text, "GoAuth 帳號")
+	assert.Contains(t, msg.HTML, `href="https://example.com/verify?from=email&amp;code=a%2Bb%2Fc"`)
+	assert.Contains(t, msg.HTML, `<html lang="zh-TW">`)
+	assert.Contains(t, msg.HTML, "<title>驗證您的 Email</title>")
+
+	// links which are not configured are left out
+	msg, err = renderer.Render(&domain.EmailMessage{
+		Template: domain.EmailTemplateResetPassword,
+		Data:     map[string]interface{}{"Code": "code"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "Reset your password", msg.Subject)
+	assert.NotContains(t, msg.Text, "open this link")
+	assert.NotContains(t, msg.HTML, "href")
+
+	_, err = renderer.Render(&domain.EmailMessage{Template: "unknown"})
+	assert.NotNil(t, err)
+}
+
+func TestRenderFallback(t *testing.T) {
+	renderer, err := NewRenderer(RendererParams{
+		FS: fstest.MapFS{
+			"layout.html.tmpl":          {Data: []byte(`{{block "content" .}}{{end}}`)},
+			"layout.txt.tmpl":           {Data: []byte(`{{block "content" .}}{{end}}`)},
+			"en/welcome.txt.tmpl":       {Data: []byte(`{{define "subject"}}Welcome{{end}}{{define "content"}}Hi {{.Name}}{{end}}`)},
+			"en/welcome.html.tmpl":      {Data: []byte(`{{define "content"}}<p>Hi {{.Name}}</p>{{end}}`)},
+			"en/text_only.txt.tmpl":     {Data: []byte(`{{define "subject"}}Text{{end}}{{define "content"}}text{{end}}`)},
+			"fr/welcome.txt.tmpl":       {Data: []byte(`{{define "subject"}}Bienvenue{{end}}{{define "content"}}Salut {{.Name}}{{end}}`)},
+			"fr/partials.txt.tmpl":      {Data: []byte(`{{define "unused"}}{{end}}`)},
+			"fr/not_a_template.md":      {Data: []byte(`ignored`)},
+			"en/partials.html.tmpl.bak": {Data: []byte(`ignored`)},
+		},
+		DefaultLocale: "en",
+	})
+	assert.Nil(t, err)
+
+	// fr-CA matches fr, which has no html template
+	msg, err := renderer.Render(&domain.EmailMessage{Template: "welcome", Locale: "fr-CA", Data: map[string]interface{}{"Name": "<Ann>"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "Bienvenue", msg.Subject)
+	assert.Equal(t, "Salut <Ann>\n", msg.Text)
+	assert.Equal(t, "", msg.HTML)
+
+	// a template which is not translated falls back to the default locale
+	msg, err = renderer.Render(&domain.EmailMessage{Template: "text_only", Locale: "fr"})
+	assert.Nil(t, err)
+	assert.Equal(t, "en", msg.Locale)
+	assert.Equal(t, "Text", msg.Subject)
+
+	// the html body is escaped
+	msg, err = renderer.Render(&domain.EmailMessage{Template: "welcome", Data: map[string]interface{}{"Name": "<Ann>"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "<p>Hi &lt;Ann&gt;</p>", msg.HTML)
+
+	_, err = NewRenderer(RendererParams{
+		FS: fstest.MapFS{
+			"layout.txt.tmpl":     {Data: []byte(`{{block "content" .}}{{end}}`)},
+			"en/welcome.txt.tmpl": {Data: []byte(`{{define "content"}}no subject{{end}}`)},
+		},
+	})
+	assert.NotNil(t, err)
+}
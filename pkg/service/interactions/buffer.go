@@ -0,0 +1,169 @@
+package interactions
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+var (
+	recordedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "interaction_events_recorded_total",
+		Help: "Number of interaction events accepted into the buffer.",
+	})
+	droppedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "interaction_events_dropped_total",
+		Help: "Number of interaction events rejected because the buffer was full or stopped.",
+	})
+	flushedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "interaction_events_flushed_total",
+		Help: "Number of interaction events written to the database.",
+	})
+	flushFailedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "interaction_events_flush_failed_total",
+		Help: "Number of interaction events lost because the batch failed to be written to the database.",
+	})
+)
+
+var (
+	// ErrBufferFull is returned when the flusher cannot keep up with the events
+	ErrBufferFull = errors.New("interaction buffer is full")
+	// ErrStopped is returned for an event recorded after Stop
+	ErrStopped = errors.New("interaction buffer is stopped")
+)
+
+// BufferParams is the parameters for creating a Buffer
+type BufferParams struct {
+	Repo domain.InteractionRepository
+	// Counter keeps the trending counters, it is optional
+	Counter domain.InteractionCounter
+	// Capacity is the number of events which can wait for the flusher
+	Capacity  int
+	BatchSize int
+	// FlushInterval is the longest time an event waits for a full batch, it is defaultFlushInterval if not positive
+	FlushInterval time.Duration
+}
+
+// defaultFlushInterval is the flush interval of a Buffer without one
+const defaultFlushInterval = time.Second
+
+// Buffer keeps the interaction events in memory and writes them in batches in the background. Events are best
+// effort, they are rejected instead of blocking the request when the buffer is full, and a failed batch is dropped.
+type Buffer struct {
+	params BufferParams
+	events chan domain.Interaction
+	done   chan struct{}
+
+	// mu guards stopped, so that no event is sent after events is closed
+	mu       sync.RWMutex
+	stopped  bool
+	stopOnce sync.Once
+}
+
+var _ domain.InteractionRecorder = (*Buffer)(nil)
+
+// NewBuffer creates a Buffer
+func NewBuffer(params BufferParams) *Buffer {
+	if params.FlushInterval <= 0 {
+		params.FlushInterval = defaultFlushInterval
+	}
+	return &Buffer{
+		params: params,
+		events: make(chan domain.Interaction, params.Capacity),
+		done:   make(chan struct{}),
+	}
+}
+
+// Record queues an event without waiting, it returns ErrBufferFull or ErrStopped if the event is not queued
+func (b *Buffer) Record(interaction domain.Interaction) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.stopped {
+		droppedTotal.Inc()
+		return ErrStopped
+	}
+	select {
+	case b.events <- interaction:
+		recordedTotal.Inc()
+		return nil
+	default:
+		droppedTotal.Inc()
+		return ErrBufferFull
+	}
+}
+
+// Start flushes the events in the background until Stop
+func (b *Buffer) Start() {
+	go func() {
+		defer close(b.done)
+		ticker := time.NewTicker(b.params.FlushInterval)
+		defer ticker.Stop()
+		batch := make([]domain.Interaction, 0, b.params.BatchSize)
+		for {
+			select {
+			case interaction, ok := <-b.events:
+				if !ok {
+					// Stop closed the events after the last one is queued
+					b.flush(batch)
+					return
+				}
+				batch = append(batch, interaction)
+				if len(batch) >= b.params.BatchSize {
+					b.flush(batch)
+					batch = batch[:0]
+				}
+			case <-ticker.C:
+				b.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}()
+}
+
+// Stop rejects new events, and waits for the queued events to be flushed or ctx to be done
+func (b *Buffer) Stop(ctx context.Context) error {
+	b.stopOnce.Do(func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.stopped = true
+		close(b.events)
+	})
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Buffer) flush(batch []domain.Interaction) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx := context.Background()
+	if customErr := b.params.Repo.CreateInteractions(ctx, batch); customErr != nil {
+		flushFailedTotal.Add(float64(len(batch)))
+		logrus.WithFields(logrus.Fields{
+			"size":  len(batch),
+			"error": customErr.Error.Error(),
+		}).Error("Buffer, CreateInteractions")
+	} else {
+		flushedTotal.Add(float64(len(batch)))
+	}
+	// the counters do not depend on the database, they are kept even if the batch is lost
+	if b.params.Counter != nil {
+		if err := b.params.Counter.Add(ctx, batch); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"size":  len(batch),
+				"error": err.Error(),
+			}).Error("Buffer, Counter.Add")
+		}
+	}
+}
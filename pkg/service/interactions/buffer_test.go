@@ -0,0 +1,126 @@
+package interactions
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/repository/memory"
+)
+
+// countingCounter records the batches added to it
+type countingCounter struct {
+	mu      sync.Mutex
+	batches []int
+}
+
+func (c *countingCounter) Add(ctx context.Context, interactions []domain.Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batches = append(c.batches, len(interactions))
+	return nil
+}
+
+func (c *countingCounter) Trending(ctx context.Context, window time.Duration, limit int) ([]int, error) {
+	return nil, nil
+}
+
+func (c *countingCounter) sizes() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]int{}, c.batches...)
+}
+
+func view(uid string, productID int) domain.Interaction {
+	return domain.Interaction{UID: uid, ProductID: productID, Type: domain.InteractionView, CreatedAt: time.Now()}
+}
+
+func TestBufferBatches(t *testing.T) {
+	repo := memory.NewInteractionRepository()
+	counter := &countingCounter{}
+	buffer := NewBuffer(BufferParams{Repo: repo, Counter: counter, Capacity: 10, BatchSize: 3, FlushInterval: time.Hour})
+	buffer.Start()
+
+	for i := 1; i <= 4; i++ {
+		assert.Nil(t, buffer.Record(view("a", i)))
+	}
+	// a full batch is flushed without waiting for the interval
+	assert.Eventually(t, func() bool { return len(counter.sizes()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int{3}, counter.sizes())
+
+	// the rest is drained on stop
+	assert.Nil(t, buffer.Stop(context.Background()))
+	assert.Equal(t, []int{3, 1}, counter.sizes())
+	interactions, customErr := repo.ListInteractions(context.Background(), "a", 10)
+	assert.Nil(t, customErr)
+	assert.Len(t, interactions, 4)
+	assert.Equal(t, 4, interactions[0].ProductID)
+
+	assert.Equal(t, ErrStopped, buffer.Record(view("a", 5)))
+	assert.Nil(t, buffer.Stop(context.Background()))
+}
+
+func TestBufferInterval(t *testing.T) {
+	repo := memory.NewInteractionRepository()
+	buffer := NewBuffer(BufferParams{Repo: repo, Capacity: 10, BatchSize: 100, FlushInterval: 20 * time.Millisecond})
+	buffer.Start()
+	defer buffer.Stop(context.Background())
+
+	assert.Nil(t, buffer.Record(view("b", 1)))
+	assert.Eventually(t, func() bool {
+		interactions, _ := repo.ListInteractions(context.Background(), "b", 10)
+		return len(interactions) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestBufferDefaultInterval(t *testing.T) {
+	// no interval does not panic the flusher
+	buffer := NewBuffer(BufferParams{Repo: memory.NewInteractionRepository(), Capacity: 1, BatchSize: 1})
+	assert.Equal(t, defaultFlushInterval, buffer.params.FlushInterval)
+	buffer.Start()
+	assert.Nil(t, buffer.Stop(context.Background()))
+}
+
+func TestBufferFull(t *testing.T) {
+	// the flusher is not started, so nothing leaves the buffer
+	buffer := NewBuffer(BufferParams{Repo: memory.NewInteractionRepository(), Capacity: 1, BatchSize: 1, FlushInterval: time.Hour})
+	assert.Nil(t, buffer.Record(view("c", 1)))
+	assert.Equal(t, ErrBufferFull, buffer.Record(view("c", 2)))
+}
+
+func TestBucketKeys(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	hour := bucketOf(now)
+	assert.Equal(t, []string{bucketKey(hour - 1), bucketKey(hour)}, bucketKeys(now, time.Hour))
+	assert.Equal(t, []string{bucketKey(hour)}, bucketKeys(now, 30*time.Minute))
+	assert.Len(t, bucketKeys(now, 24*time.Hour), 25)
+}
+
+func TestLimiter(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	limiter := NewLimiter(client, 2, time.Minute)
+	ctx := context.Background()
+
+	for _, want := range []bool{true, true, false} {
+		ok, err := limiter.Allow(ctx, "a")
+		assert.Nil(t, err)
+		assert.Equal(t, want, ok)
+	}
+	// the users are limited apart
+	ok, err := limiter.Allow(ctx, "b")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	server.FastForward(time.Minute)
+	ok, err = limiter.Allow(ctx, "a")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
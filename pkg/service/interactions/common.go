@@ -0,0 +1,39 @@
+package interactions
+
+import "github.com/Yu-Qi/GoAuth/domain"
+
+var (
+	buffer  *Buffer
+	counter domain.InteractionCounter
+	limiter domain.InteractionLimiter
+)
+
+// GetBuffer returns the buffer, it is nil if not initialized
+func GetBuffer() *Buffer {
+	return buffer
+}
+
+// InitBuffer initializes the buffer
+func InitBuffer(b *Buffer) {
+	buffer = b
+}
+
+// GetCounter returns the trending counter
+func GetCounter() domain.InteractionCounter {
+	return counter
+}
+
+// InitCounter initializes the trending counter
+func InitCounter(c domain.InteractionCounter) {
+	counter = c
+}
+
+// GetLimiter returns the limiter of the events of a user, it is nil if not initialized
+func GetLimiter() domain.InteractionLimiter {
+	return limiter
+}
+
+// InitLimiter initializes the limiter of the events of a user
+func InitLimiter(l domain.InteractionLimiter) {
+	limiter = l
+}
@@ -0,0 +1,48 @@
+package interactions
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+// countEvent counts an event in the window, which starts from the first event. The counter gets its
+// expiry in the same call, so it can not be left without one.
+var countEvent = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 or redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// Limiter limits the events of a user in a fixed window, the counters are kept in redis so that every
+// instance shares them
+type Limiter struct {
+	client redis.UniversalClient
+	limit  int
+	window time.Duration
+}
+
+var _ domain.InteractionLimiter = (*Limiter)(nil)
+
+// NewLimiter creates a Limiter which allows limit events of a user in window
+func NewLimiter(client redis.UniversalClient, limit int, window time.Duration) *Limiter {
+	return &Limiter{client: client, limit: limit, window: window}
+}
+
+func limitKey(uid string) string {
+	return "interaction_limit:" + uid
+}
+
+// Allow counts an event of the user and returns false if the user has more than the limit in the window
+func (l *Limiter) Allow(ctx context.Context, uid string) (bool, error) {
+	count, err := countEvent.Run(ctx, l.client, []string{limitKey(uid)}, l.window.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return count <= int64(l.limit), nil
+}
@@ -0,0 +1,110 @@
+package interactions
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+// trendingBucket is the time span of a sorted set, a trending window is the union of the buckets in it
+const trendingBucket = time.Hour
+
+// trendingUnionTTL is how long the union of a window is reused by the next queries
+const trendingUnionTTL = time.Minute
+
+// TrendingCounter keeps the weighted interactions of the products in a redis sorted set per hour
+type TrendingCounter struct {
+	client redis.UniversalClient
+	// retention is how long a bucket is kept, it is the longest window which can be queried
+	retention time.Duration
+}
+
+var _ domain.InteractionCounter = (*TrendingCounter)(nil)
+
+// NewTrendingCounter creates a TrendingCounter
+func NewTrendingCounter(client redis.UniversalClient, retention time.Duration) *TrendingCounter {
+	return &TrendingCounter{client: client, retention: retention}
+}
+
+func bucketOf(t time.Time) int64 {
+	return t.Unix() / int64(trendingBucket/time.Second)
+}
+
+// bucketKey returns the key of a bucket, the hash tag keeps the buckets and their unions in one slot of a cluster
+func bucketKey(bucket int64) string {
+	return fmt.Sprintf("{product_trending}:%d", bucket)
+}
+
+// bucketKeys returns the keys of the buckets overlapping the window which ends at now, the oldest first
+func bucketKeys(now time.Time, window time.Duration) []string {
+	last := bucketOf(now)
+	first := bucketOf(now.Add(-window + time.Nanosecond))
+	keys := make([]string, 0, last-first+1)
+	for bucket := first; bucket <= last; bucket++ {
+		keys = append(keys, bucketKey(bucket))
+	}
+	return keys
+}
+
+// Add adds the weights of the interactions to the buckets of their time
+func (c *TrendingCounter) Add(ctx context.Context, interactions []domain.Interaction) error {
+	if len(interactions) == 0 {
+		return nil
+	}
+	pipe := c.client.Pipeline()
+	expires := map[int64]bool{}
+	for _, interaction := range interactions {
+		bucket := bucketOf(interaction.CreatedAt)
+		pipe.ZIncrBy(ctx, bucketKey(bucket), float64(domain.InteractionWeights[interaction.Type]), strconv.Itoa(interaction.ProductID))
+		if !expires[bucket] {
+			expires[bucket] = true
+			end := time.Unix((bucket+1)*int64(trendingBucket/time.Second), 0)
+			pipe.ExpireAt(ctx, bucketKey(bucket), end.Add(c.retention))
+		}
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Trending returns the ids of the products with the most weighted interactions in the last window, the most first.
+// The window is rounded up to whole hours, and is at most the retention.
+func (c *TrendingCounter) Trending(ctx context.Context, window time.Duration, limit int) ([]int, error) {
+	if window > c.retention {
+		window = c.retention
+	}
+	keys := bucketKeys(time.Now(), window)
+	union := fmt.Sprintf("{product_trending}:union:%s:%d", keys[0], len(keys))
+
+	// the union of the same window is shared for a while, so a query reads only one sorted set
+	n, err := c.client.Exists(ctx, union).Result()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		pipe := c.client.TxPipeline()
+		pipe.ZUnionStore(ctx, union, &redis.ZStore{Keys: keys, Aggregate: "SUM"})
+		pipe.Expire(ctx, union, trendingUnionTTL)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	members, err := c.client.ZRevRange(ctx, union, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.Atoi(member)
+		if err != nil {
+			return nil, fmt.Errorf("invalid product id %q in %s: %w", member, union, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
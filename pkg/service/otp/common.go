@@ -0,0 +1,17 @@
+package otp
+
+import "github.com/Yu-Qi/GoAuth/domain"
+
+var (
+	service domain.OTPService
+)
+
+// GetService returns the otp service
+func GetService() domain.OTPService {
+	return service
+}
+
+// InitService initializes the otp service
+func InitService(s domain.OTPService) {
+	service = s
+}
@@ -0,0 +1,184 @@
+package otp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+// purposes of a code
+const (
+	PurposeVerifyPhone = "verify-phone"
+	PurposeLogin       = "login"
+)
+
+var (
+	// ErrRateLimited is returned when too many codes are sent to a phone number
+	ErrRateLimited = errors.New("too many codes sent to this phone number")
+	// ErrInvalidCode is returned for a wrong, expired or used code
+	ErrInvalidCode = errors.New("invalid code")
+	// ErrSendSMS wraps the error of sending a code
+	ErrSendSMS = errors.New("failed to send sms")
+)
+
+// verifyCode consumes the code if the hash matches, or counts a wrong guess and drops the code after the max
+// attempts. The check and the count are one step, so that concurrent guesses can not make more than the max
+// attempts. It returns the subject of the code, or false for a wrong, expired or used code.
+var verifyCode = redis.NewScript(`
+local stored = redis.call("HMGET", KEYS[1], "hash", "subject", "attempts")
+if not stored[1] then
+	return false
+end
+if tonumber(stored[3]) >= tonumber(ARGV[2]) then
+	redis.call("DEL", KEYS[1])
+	return false
+end
+if stored[1] == ARGV[1] then
+	redis.call("DEL", KEYS[1])
+	return stored[2]
+end
+if redis.call("HINCRBY", KEYS[1], "attempts", 1) >= tonumber(ARGV[2]) then
+	redis.call("DEL", KEYS[1])
+end
+return false
+`)
+
+// countSend counts a code sent in the window, which starts from the first code. The counter gets its
+// expiry in the same call, so it can not be left without one.
+var countSend = redis.NewScript(`
+local sent = redis.call("INCR", KEYS[1])
+if sent == 1 or redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return sent
+`)
+
+// Params is the parameters for creating a Service
+type Params struct {
+	Client redis.UniversalClient
+	SMSSvc domain.SendSMSService
+	// CodeLength is the number of digits of a code
+	CodeLength int
+	TTL        time.Duration
+	// MaxAttempts is the number of wrong guesses after which a code is dropped
+	MaxAttempts int
+	// SendInterval is the least time between two codes sent to a phone number
+	SendInterval time.Duration
+	// SendLimit is the number of codes which can be sent to a phone number in SendWindow
+	SendLimit  int
+	SendWindow time.Duration
+}
+
+// Service sends one-time passwords by SMS and keeps them in redis, only the hash of a code is stored
+type Service struct {
+	params Params
+}
+
+// NewService creates a Service
+func NewService(params Params) *Service {
+	return &Service{params: params}
+}
+
+func codeKey(purpose, phone string) string {
+	return fmt.Sprintf("otp:code:%s:%s", purpose, phone)
+}
+
+func cooldownKey(phone string) string {
+	return "otp:cooldown:" + phone
+}
+
+func sentKey(phone string) string {
+	return "otp:sent:" + phone
+}
+
+func hashCode(purpose, phone, code string) string {
+	sum := sha256.Sum256([]byte(purpose + "|" + phone + "|" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// Send sends a new code, which replaces the previous one of the same purpose
+func (s *Service) Send(ctx context.Context, purpose, phone, subject string) error {
+	if err := s.AllowSend(ctx, phone); err != nil {
+		return err
+	}
+	return s.Deliver(ctx, purpose, phone, subject)
+}
+
+// Deliver sends a new code without applying the send limits, the caller has applied them with AllowSend
+func (s *Service) Deliver(ctx context.Context, purpose, phone, subject string) error {
+	code, err := randomDigits(s.params.CodeLength)
+	if err != nil {
+		return err
+	}
+	key := codeKey(purpose, phone)
+	_, err = s.params.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, "hash", hashCode(purpose, phone, code), "subject", subject, "attempts", 0)
+		pipe.PExpire(ctx, key, s.params.TTL)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.params.SMSSvc.SendSMS(&domain.SMSMessage{
+		To:   phone,
+		Text: fmt.Sprintf("Your GoAuth code is %s. It expires in %d minutes.", code, int(s.params.TTL.Minutes())),
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSendSMS, err)
+	}
+	return nil
+}
+
+// AllowSend applies the per number limits, the least interval between two codes and the number of codes in a window
+func (s *Service) AllowSend(ctx context.Context, phone string) error {
+	ok, err := s.params.Client.SetNX(ctx, cooldownKey(phone), 1, s.params.SendInterval).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrRateLimited
+	}
+
+	sent, err := countSend.Run(ctx, s.params.Client, []string{sentKey(phone)}, s.params.SendWindow.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if sent > int64(s.params.SendLimit) {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// Verify consumes a code and returns its subject. A code can be verified once, and is dropped after too many wrong guesses.
+func (s *Service) Verify(ctx context.Context, purpose, phone, code string) (string, error) {
+	// only the hash of the guess leaves the process, so comparing it in redis tells nothing about the code
+	hash := hashCode(purpose, phone, strings.TrimSpace(code))
+	subject, err := verifyCode.Run(ctx, s.params.Client, []string{codeKey(purpose, phone)}, hash, s.params.MaxAttempts).Text()
+	if err == redis.Nil {
+		return "", ErrInvalidCode
+	} else if err != nil {
+		return "", err
+	}
+	return subject, nil
+}
+
+func randomDigits(n int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+	v, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", n, v.Int64()), nil
+}
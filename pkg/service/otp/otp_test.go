@@ -0,0 +1,93 @@
+package otp
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomDigits(t *testing.T) {
+	digits := regexp.MustCompile(`^[0-9]{6}$`)
+	for i := 0; i < 100; i++ {
+		code, err := randomDigits(6)
+		assert.Nil(t, err)
+		assert.Regexp(t, digits, code)
+	}
+}
+
+func TestHashCode(t *testing.T) {
+	// a code is bound to its purpose and phone number
+	hash := hashCode(PurposeLogin, "+886912345678", "123456")
+	assert.Equal(t, hash, hashCode(PurposeLogin, "+886912345678", "123456"))
+	assert.NotEqual(t, hash, hashCode(PurposeVerifyPhone, "+886912345678", "123456"))
+	assert.NotEqual(t, hash, hashCode(PurposeLogin, "+886912345679", "123456"))
+}
+
+func TestAllowSend(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	svc := NewService(Params{
+		Client:       client,
+		SendInterval: time.Second,
+		SendLimit:    2,
+		SendWindow:   time.Hour,
+	})
+	ctx := context.Background()
+	phone := "+886912345678"
+
+	assert.Nil(t, svc.AllowSend(ctx, phone))
+	assert.ErrorIs(t, svc.AllowSend(ctx, phone), ErrRateLimited)
+	// the window counter expires with the first code
+	assert.Equal(t, time.Hour, server.TTL(sentKey(phone)))
+
+	server.FastForward(time.Second)
+	assert.Nil(t, svc.AllowSend(ctx, phone))
+	server.FastForward(time.Second)
+	assert.ErrorIs(t, svc.AllowSend(ctx, phone), ErrRateLimited)
+
+	// a counter left without an expiry gets one
+	server.FastForward(time.Hour)
+	server.Set(sentKey(phone), "5")
+	assert.ErrorIs(t, svc.AllowSend(ctx, phone), ErrRateLimited)
+	assert.Equal(t, time.Hour, server.TTL(sentKey(phone)))
+}
+
+func TestVerify(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	svc := NewService(Params{Client: client, MaxAttempts: 2})
+	ctx := context.Background()
+	phone := "+886912345678"
+	key := codeKey(PurposeLogin, phone)
+	server.HSet(key, "hash", hashCode(PurposeLogin, phone, "123456"), "subject", "uid", "attempts", "0")
+
+	// a code is consumed once
+	subject, err := svc.Verify(ctx, PurposeLogin, phone, " 123456 ")
+	assert.Nil(t, err)
+	assert.Equal(t, "uid", subject)
+	_, err = svc.Verify(ctx, PurposeLogin, phone, "123456")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	// the code is dropped after the max wrong guesses, the right one is rejected too
+	server.HSet(key, "hash", hashCode(PurposeLogin, phone, "123456"), "subject", "uid", "attempts", "0")
+	_, err = svc.Verify(ctx, PurposeLogin, phone, "000000")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	assert.Equal(t, "1", server.HGet(key, "attempts"))
+	_, err = svc.Verify(ctx, PurposeLogin, phone, "000000")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	assert.False(t, server.Exists(key))
+	_, err = svc.Verify(ctx, PurposeLogin, phone, "123456")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	// a code which reached the max attempts is not verified
+	server.HSet(key, "hash", hashCode(PurposeLogin, phone, "123456"), "subject", "uid", "attempts", "2")
+	_, err = svc.Verify(ctx, PurposeLogin, phone, "123456")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+}
@@ -0,0 +1,15 @@
+package outbox
+
+var (
+	dispatcher *Dispatcher
+)
+
+// GetDispatcher returns the dispatcher, it is nil if not initialized
+func GetDispatcher() *Dispatcher {
+	return dispatcher
+}
+
+// InitDispatcher initializes the dispatcher
+func InitDispatcher(d *Dispatcher) {
+	dispatcher = d
+}
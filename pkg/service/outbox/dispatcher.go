@@ -0,0 +1,180 @@
+package outbox
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/db"
+	emailsvc "github.com/Yu-Qi/GoAuth/pkg/service/email"
+)
+
+var (
+	sentTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "email_outbox_sent_total",
+		Help: "Number of emails delivered from the outbox.",
+	})
+	retriedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "email_outbox_retried_total",
+		Help: "Number of failed email deliveries which will be retried.",
+	})
+	deadTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "email_outbox_dead_total",
+		Help: "Number of emails given up after the last attempt failed.",
+	})
+)
+
+// Backoff is an exponential backoff between delivery attempts
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+	// Jitter is the fraction of the delay which is randomized, so that emails failed together are not retried together
+	Jitter float64
+}
+
+// Delay returns the delay after the given failed attempt, which starts from 1
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := float64(b.Base) * math.Pow(2, float64(attempt-1))
+	if delay > float64(b.Max) || math.IsInf(delay, 1) {
+		delay = float64(b.Max)
+	}
+	delay -= delay * b.Jitter * rand.Float64()
+	return time.Duration(delay)
+}
+
+// DispatcherParams is the parameters for creating a Dispatcher
+type DispatcherParams struct {
+	// SendEmailSvc delivers the emails, such as a TemplateEmailService
+	SendEmailSvc domain.SendEmailService
+	BatchSize    int
+	PollInterval time.Duration
+	// Lease is how long a claimed email is not claimed again, it should be longer than sending a batch
+	Lease time.Duration
+	// MaxAttempts is the number of attempts after which an email is dead
+	MaxAttempts int
+	Backoff     Backoff
+}
+
+// Dispatcher delivers the emails in the outbox in the background. Delivery is at least once, an email
+// may be sent again if the dispatcher dies before recording it, so the idempotency key is sent as the Message-ID.
+type Dispatcher struct {
+	params DispatcherParams
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewDispatcher creates a Dispatcher
+func NewDispatcher(params DispatcherParams) *Dispatcher {
+	return &Dispatcher{
+		params: params,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start polls the outbox until Stop
+func (d *Dispatcher) Start() {
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(d.params.PollInterval)
+		defer ticker.Stop()
+		for {
+			// drain the due emails before waiting for the next tick
+			for {
+				n, err := d.DispatchOnce(context.Background())
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"error": err.Error(),
+					}).Error("Dispatcher, DispatchOnce")
+				}
+				if err != nil || n < d.params.BatchSize || d.stopped() {
+					break
+				}
+			}
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops polling and waits for the current batch to finish or ctx to be done
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.stopOnce.Do(func() { close(d.stop) })
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) stopped() bool {
+	select {
+	case <-d.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// DispatchOnce delivers a batch of due emails and returns how many were claimed
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	emails, customErr := db.ClaimEmails(ctx, d.params.BatchSize, d.params.Lease)
+	if customErr != nil {
+		return 0, customErr.Error
+	}
+	for _, email := range emails {
+		d.deliver(ctx, email)
+	}
+	return len(emails), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, email *domain.OutboxEmail) {
+	err := d.params.SendEmailSvc.SendEmail(ctx, email.Message)
+	if err == nil {
+		sentTotal.Inc()
+		if customErr := db.MarkEmailSent(ctx, email); customErr != nil {
+			// the email will be sent again after the lease
+			logrus.WithFields(logrus.Fields{
+				"id":    email.ID,
+				"error": customErr.Error.Error(),
+			}).Error("Dispatcher, MarkEmailSent")
+		}
+		return
+	}
+
+	// an email rejected by the provider is not retried
+	dead := email.Attempts >= d.params.MaxAttempts || emailsvc.IsPermanent(err)
+	fields := logrus.Fields{
+		"id":       email.ID,
+		"template": email.Message.Template,
+		"attempts": email.Attempts,
+		"error":    err.Error(),
+	}
+	if dead {
+		deadTotal.Inc()
+		logrus.WithFields(fields).Error("Dispatcher, email is dead")
+	} else {
+		retriedTotal.Inc()
+		logrus.WithFields(fields).Warn("Dispatcher, SendEmail")
+	}
+	nextAttemptAt := time.Now().Add(d.params.Backoff.Delay(email.Attempts))
+	if customErr := db.MarkEmailFailed(ctx, email, err.Error(), nextAttemptAt, dead); customErr != nil {
+		logrus.WithFields(logrus.Fields{
+			"id":    email.ID,
+			"error": customErr.Error.Error(),
+		}).Error("Dispatcher, MarkEmailFailed")
+	}
+}
@@ -0,0 +1,23 @@
+package outbox
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	backoff := Backoff{Base: time.Second, Max: time.Minute}
+	assert.Equal(t, time.Second, backoff.Delay(1))
+	assert.Equal(t, 2*time.Second, backoff.Delay(2))
+	assert.Equal(t, 32*time.Second, backoff.Delay(6))
+	assert.Equal(t, time.Minute, backoff.Delay(7))
+	assert.Equal(t, time.Minute, backoff.Delay(10000))
+
+	backoff.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		delay := backoff.Delay(3)
+		assert.True(t, delay > 3200*time.Millisecond && delay <= 4*time.Second, delay)
+	}
+}
@@ -0,0 +1,31 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/db"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
+)
+
+// EmailService queues emails in the outbox instead of sending them, they are sent by a Dispatcher
+type EmailService struct{}
+
+// NewEmailService creates an EmailService
+func NewEmailService() *EmailService {
+	return &EmailService{}
+}
+
+// SendEmail queues an email, an email whose ID is already queued is not queued again. An email without an ID gets
+// a random one, so it is never deduplicated.
+func (s *EmailService) SendEmail(ctx context.Context, msg *domain.EmailMessage) error {
+	if msg.ID == "" {
+		queued := *msg
+		queued.ID = util.UUID()
+		msg = &queued
+	}
+	if customErr := db.EnqueueEmail(ctx, msg, ""); customErr != nil {
+		return customErr.Error
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// breachedPrefixLength is the length of the SHA-1 prefix of the k-anonymity range files
+	breachedPrefixLength = 5
+)
+
+// sha1Hex returns the upper case hex SHA-1 of a password, the format of Pwned Passwords
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// parseBreachedLine parses a line of HASH or HASH:COUNT
+func parseBreachedLine(line string) string {
+	line = strings.TrimSpace(line)
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.ToUpper(line)
+}
+
+// BreachedList is an in-memory BreachedChecker loaded from a single file of SHA-1 hashes,
+// one HASH or HASH:COUNT per line
+type BreachedList struct {
+	hashes map[string]struct{}
+}
+
+// NewBreachedListFromFile loads a BreachedList from a local file
+func NewBreachedListFromFile(path string) (*BreachedList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewBreachedList(f)
+}
+
+// NewBreachedList loads a BreachedList from file content
+func NewBreachedList(r io.Reader) (*BreachedList, error) {
+	list := &BreachedList{hashes: map[string]struct{}{}}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if hash := parseBreachedLine(scanner.Text()); hash != "" {
+			list.hashes[hash] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// IsBreached .
+func (l *BreachedList) IsBreached(password string) (bool, error) {
+	_, ok := l.hashes[sha1Hex(password)]
+	return ok, nil
+}
+
+// BreachedRangeDir is a BreachedChecker over a directory of k-anonymity range files, as served by the
+// Pwned Passwords range API: a file named by the first 5 hex of the SHA-1 holds SUFFIX:COUNT lines.
+// Only the file of the prefix is read for a lookup, so the whole list is never held in memory.
+type BreachedRangeDir struct {
+	dir string
+}
+
+// NewBreachedRangeDir creates a BreachedRangeDir
+func NewBreachedRangeDir(dir string) *BreachedRangeDir {
+	return &BreachedRangeDir{dir: dir}
+}
+
+// IsBreached .
+func (d *BreachedRangeDir) IsBreached(password string) (bool, error) {
+	hash := sha1Hex(password)
+	prefix, suffix := hash[:breachedPrefixLength], hash[breachedPrefixLength:]
+	f, err := os.Open(filepath.Join(d.dir, prefix))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if parseBreachedLine(scanner.Text()) == suffix {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// NewBreachedChecker loads a BreachedRangeDir if path is a directory, or a BreachedList if it is a file
+func NewBreachedChecker(path string) (BreachedChecker, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return NewBreachedRangeDir(path), nil
+	}
+	return NewBreachedListFromFile(path)
+}
@@ -0,0 +1,28 @@
+package password
+
+import "github.com/Yu-Qi/GoAuth/domain"
+
+var (
+	service domain.PasswordHasher = NewPolicyHasher(NewArgon2idHasher(DefaultArgon2idParams))
+	policy                        = NewPolicy(DefaultPolicyParams)
+)
+
+// GetService returns the password hasher
+func GetService() domain.PasswordHasher {
+	return service
+}
+
+// InitService initializes the password hasher, it is Argon2id with DefaultArgon2idParams if not initialized
+func InitService(s domain.PasswordHasher) {
+	service = s
+}
+
+// GetPolicy returns the password policy
+func GetPolicy() *Policy {
+	return policy
+}
+
+// InitPolicy initializes the password policy, it is DefaultPolicyParams if not initialized
+func InitPolicy(p *Policy) {
+	policy = p
+}
@@ -0,0 +1,121 @@
+package password
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+var (
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "password_hash_queue_depth",
+		Help: "Number of password hashing calls waiting for a worker.",
+	})
+	inFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "password_hash_in_flight",
+		Help: "Number of password hashing calls running.",
+	})
+	waitSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "password_hash_wait_seconds",
+		Help:    "Time password hashing calls wait for a worker.",
+		Buckets: []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5},
+	})
+	rejectedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "password_hash_rejected_total",
+		Help: "Number of password hashing calls rejected because no worker was free in time.",
+	})
+)
+
+// Executor runs password hashing with bounded concurrency, so that a burst of logins
+// cannot starve the other requests of CPU
+type Executor struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// NewExecutor creates an Executor which runs at most concurrency calls at once,
+// a call waiting longer than queueTimeout for a worker is rejected with ErrBusy, 0 waits as long as the context
+func NewExecutor(concurrency int, queueTimeout time.Duration) *Executor {
+	return &Executor{
+		slots:        make(chan struct{}, concurrency),
+		queueTimeout: queueTimeout,
+	}
+}
+
+// Do runs fn once a worker is free. It returns ErrBusy without running fn if the queue timeout
+// or the context is done first.
+func (e *Executor) Do(ctx context.Context, fn func()) error {
+	start := time.Now()
+	queueDepth.Inc()
+	var timeout <-chan time.Time
+	if e.queueTimeout > 0 {
+		timer := time.NewTimer(e.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case e.slots <- struct{}{}:
+	case <-timeout:
+		queueDepth.Dec()
+		waitSeconds.Observe(time.Since(start).Seconds())
+		rejectedTotal.Inc()
+		return ErrBusy
+	case <-ctx.Done():
+		queueDepth.Dec()
+		waitSeconds.Observe(time.Since(start).Seconds())
+		rejectedTotal.Inc()
+		return fmt.Errorf("%w: %v", ErrBusy, ctx.Err())
+	}
+	queueDepth.Dec()
+	waitSeconds.Observe(time.Since(start).Seconds())
+
+	inFlight.Inc()
+	defer func() {
+		inFlight.Dec()
+		<-e.slots
+	}()
+	fn()
+	return nil
+}
+
+// LimitedHasher runs the hashing and comparing of a PasswordHasher on an Executor
+type LimitedHasher struct {
+	hasher   domain.PasswordHasher
+	executor *Executor
+}
+
+// NewLimitedHasher creates a LimitedHasher
+func NewLimitedHasher(hasher domain.PasswordHasher, executor *Executor) *LimitedHasher {
+	return &LimitedHasher{hasher: hasher, executor: executor}
+}
+
+// Hash .
+func (h *LimitedHasher) Hash(ctx context.Context, password string) (hash string, err error) {
+	if doErr := h.executor.Do(ctx, func() {
+		hash, err = h.hasher.Hash(ctx, password)
+	}); doErr != nil {
+		return "", doErr
+	}
+	return
+}
+
+// Compare .
+func (h *LimitedHasher) Compare(ctx context.Context, hash string, password string) (err error) {
+	if doErr := h.executor.Do(ctx, func() {
+		err = h.hasher.Compare(ctx, hash, password)
+	}); doErr != nil {
+		return doErr
+	}
+	return
+}
+
+// NeedsRehash only parses the hash, so it does not take a worker
+func (h *LimitedHasher) NeedsRehash(hash string) bool {
+	return h.hasher.NeedsRehash(hash)
+}
@@ -0,0 +1,65 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecutor(t *testing.T) {
+	executor := NewExecutor(1, 50*time.Millisecond)
+
+	// occupy the only worker
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_ = executor.Do(context.Background(), func() {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+
+	ran := false
+	err := executor.Do(context.Background(), func() { ran = true })
+	assert.True(t, errors.Is(err, ErrBusy))
+	assert.False(t, ran)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = executor.Do(ctx, func() { ran = true })
+	assert.True(t, errors.Is(err, ErrBusy))
+	assert.False(t, ran)
+
+	close(release)
+	err = executor.Do(context.Background(), func() { ran = true })
+	assert.Nil(t, err)
+	assert.True(t, ran)
+}
+
+func TestExecutorWithoutQueueTimeout(t *testing.T) {
+	executor := NewExecutor(1, 0)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_ = executor.Do(context.Background(), func() {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+
+	// a call waits for the worker instead of being rejected at once
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	ran := false
+	err := executor.Do(context.Background(), func() { ran = true })
+	assert.Nil(t, err)
+	assert.True(t, ran)
+}
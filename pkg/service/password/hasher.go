@@ -0,0 +1,82 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// errors of password hashing
+var (
+	ErrMismatchedPassword  = errors.New("password does not match")
+	ErrInvalidHash         = errors.New("invalid password hash")
+	ErrIncompatibleVersion = errors.New("incompatible hash version")
+	ErrBusy                = errors.New("password hashing is saturated")
+)
+
+// hasher is a single hashing algorithm
+type hasher interface {
+	Hash(password string) (string, error)
+	Compare(hash string, password string) error
+	NeedsRehash(hash string) bool
+}
+
+// PolicyHasher hashes new passwords with the current algorithm and verifies hashes of every supported algorithm,
+// chosen by the prefix of the hash string
+type PolicyHasher struct {
+	current  hasher
+	argon2id *Argon2idHasher
+	bcrypt   *BcryptHasher
+}
+
+// NewPolicyHasher creates a PolicyHasher which hashes with current. current is an *Argon2idHasher or a *BcryptHasher
+func NewPolicyHasher(current hasher) *PolicyHasher {
+	// the cost of verifying is read from the hash, the parameters only matter for the current algorithm
+	h := &PolicyHasher{
+		current:  current,
+		argon2id: NewArgon2idHasher(DefaultArgon2idParams),
+		bcrypt:   NewBcryptHasher(bcrypt.DefaultCost),
+	}
+	switch c := current.(type) {
+	case *Argon2idHasher:
+		h.argon2id = c
+	case *BcryptHasher:
+		h.bcrypt = c
+	}
+	return h
+}
+
+// Hash .
+func (h *PolicyHasher) Hash(ctx context.Context, password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+// Compare .
+func (h *PolicyHasher) Compare(ctx context.Context, hash string, password string) error {
+	algorithm, ok := h.algorithmOf(hash)
+	if !ok {
+		return ErrInvalidHash
+	}
+	return algorithm.Compare(hash, password)
+}
+
+// NeedsRehash reports true if the hash is not made by the current algorithm, or its parameters are weaker
+func (h *PolicyHasher) NeedsRehash(hash string) bool {
+	algorithm, ok := h.algorithmOf(hash)
+	if !ok || algorithm != h.current {
+		return true
+	}
+	return h.current.NeedsRehash(hash)
+}
+
+func (h *PolicyHasher) algorithmOf(hash string) (hasher, bool) {
+	switch {
+	case strings.HasPrefix(hash, Argon2idPrefix):
+		return h.argon2id, true
+	case isBcryptHash(hash):
+		return h.bcrypt, true
+	}
+	return nil, false
+}
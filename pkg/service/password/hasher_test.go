@@ -0,0 +1,60 @@
+package password
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testArgon2idParams = Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestPolicyHasher(t *testing.T) {
+	ctx := context.Background()
+	hasher := NewPolicyHasher(NewArgon2idHasher(testArgon2idParams))
+	longPassword := strings.Repeat("Password1!", 10)
+
+	hash, err := hasher.Hash(ctx, longPassword)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.Nil(t, hasher.Compare(ctx, hash, longPassword))
+	// no truncation at 72 bytes like bcrypt
+	assert.Equal(t, ErrMismatchedPassword, hasher.Compare(ctx, hash, longPassword[:72]))
+	assert.False(t, hasher.NeedsRehash(hash))
+
+	// bcrypt hashes are still verified, and rehashed to argon2id
+	bcryptHash, err := NewBcryptHasher(4).Hash("Password1!")
+	assert.Nil(t, err)
+	assert.Nil(t, hasher.Compare(ctx, bcryptHash, "Password1!"))
+	assert.Equal(t, ErrMismatchedPassword, hasher.Compare(ctx, bcryptHash, "Password2!"))
+	assert.True(t, hasher.NeedsRehash(bcryptHash))
+
+	assert.Equal(t, ErrInvalidHash, hasher.Compare(ctx, "plain", "plain"))
+}
+
+func TestNeedsRehash(t *testing.T) {
+	ctx := context.Background()
+	weak := NewArgon2idHasher(testArgon2idParams)
+	hash, err := weak.Hash("Password1!")
+	assert.Nil(t, err)
+
+	stronger := testArgon2idParams
+	stronger.Memory *= 2
+	hasher := NewPolicyHasher(NewArgon2idHasher(stronger))
+	assert.Nil(t, hasher.Compare(ctx, hash, "Password1!"))
+	assert.True(t, hasher.NeedsRehash(hash))
+
+	bcryptHasher := NewPolicyHasher(NewBcryptHasher(5))
+	lowCost, err := NewBcryptHasher(4).Hash("Password1!")
+	assert.Nil(t, err)
+	assert.True(t, bcryptHasher.NeedsRehash(lowCost))
+	assert.True(t, bcryptHasher.NeedsRehash(hash))
+	assert.Nil(t, bcryptHasher.Compare(ctx, hash, "Password1!"))
+}
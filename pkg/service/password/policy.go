@@ -0,0 +1,192 @@
+package password
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	specialChars = `()[]{}<>+-*/?,.:;"'_\|~` + "`" + `!@#$%^&=`
+)
+
+// rules of Policy, used as the rule of a Violation
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleUppercase = "uppercase"
+	RuleLowercase = "lowercase"
+	RuleDigit     = "digit"
+	RuleSpecial   = "special"
+	RuleEntropy   = "entropy"
+	RuleBreached  = "breached"
+)
+
+// Violation is a rule of the policy that a password fails
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError is returned when a password fails the policy
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return "invalid password: " + strings.Join(messages, "; ")
+}
+
+// BreachedChecker checks if a password appears in known breaches
+type BreachedChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+// PolicyParams is the rules of a Policy, lengths are counted in characters
+type PolicyParams struct {
+	MinLength      int
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSpecial bool
+	// MinEntropyBits is the minimum estimated entropy, 0 disables the check
+	MinEntropyBits float64
+	// Breached is optional, nil disables the check
+	Breached BreachedChecker
+}
+
+// DefaultPolicyParams allows long passphrases while keeping the character classes the service always required
+var DefaultPolicyParams = PolicyParams{
+	MinLength:      8,
+	MaxLength:      128,
+	RequireUpper:   true,
+	RequireLower:   true,
+	RequireSpecial: true,
+	MinEntropyBits: 40,
+}
+
+// Policy validates passwords against configurable rules
+type Policy struct {
+	params PolicyParams
+}
+
+// NewPolicy creates a Policy
+func NewPolicy(params PolicyParams) *Policy {
+	return &Policy{params: params}
+}
+
+// Validate returns a *PolicyError listing every failed rule, or nil if the password is acceptable
+func (p *Policy) Validate(password string) error {
+	violations := []Violation{}
+	length := utf8.RuneCountInString(password)
+	if length < p.params.MinLength {
+		violations = append(violations, Violation{RuleMinLength, fmt.Sprintf("must be at least %d characters", p.params.MinLength)})
+	}
+	if p.params.MaxLength > 0 && length > p.params.MaxLength {
+		violations = append(violations, Violation{RuleMaxLength, fmt.Sprintf("must be at most %d characters", p.params.MaxLength)})
+	}
+
+	classes := characterClassesOf(password)
+	if p.params.RequireUpper && !classes.upper {
+		violations = append(violations, Violation{RuleUppercase, "must contain an uppercase letter"})
+	}
+	if p.params.RequireLower && !classes.lower {
+		violations = append(violations, Violation{RuleLowercase, "must contain a lowercase letter"})
+	}
+	if p.params.RequireDigit && !classes.digit {
+		violations = append(violations, Violation{RuleDigit, "must contain a digit"})
+	}
+	if p.params.RequireSpecial && !classes.special {
+		violations = append(violations, Violation{RuleSpecial, "must contain a special character " + specialChars})
+	}
+	if p.params.MinEntropyBits > 0 && EstimateEntropy(password) < p.params.MinEntropyBits {
+		violations = append(violations, Violation{RuleEntropy, "is too easy to guess"})
+	}
+
+	if p.params.Breached != nil {
+		breached, err := p.params.Breached.IsBreached(password)
+		if err != nil {
+			// the breach list is an extra safety net, an unreadable list should not block registration
+			logrus.WithFields(logrus.Fields{
+				"error": err.Error(),
+			}).Warn("Policy, IsBreached")
+		} else if breached {
+			violations = append(violations, Violation{RuleBreached, "has appeared in a data breach"})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+type characterClasses struct {
+	upper, lower, digit, special, other bool
+}
+
+func characterClassesOf(password string) characterClasses {
+	classes := characterClasses{}
+	for _, r := range password {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			classes.upper = true
+		case r >= 'a' && r <= 'z':
+			classes.lower = true
+		case r >= '0' && r <= '9':
+			classes.digit = true
+		case strings.ContainsRune(specialChars, r):
+			classes.special = true
+		case unicode.IsSpace(r):
+			// spaces of a passphrase count towards the pool as special characters
+			classes.special = true
+		default:
+			classes.other = true
+		}
+	}
+	return classes
+}
+
+// EstimateEntropy estimates the entropy in bits as length * log2(pool), where the pool is the size of
+// the character classes used. Runs of a repeated character only count once, so "aaaaaaaa" stays weak.
+func EstimateEntropy(password string) float64 {
+	classes := characterClassesOf(password)
+	pool := 0
+	if classes.upper {
+		pool += 26
+	}
+	if classes.lower {
+		pool += 26
+	}
+	if classes.digit {
+		pool += 10
+	}
+	if classes.special {
+		pool += len(specialChars) + 1
+	}
+	if classes.other {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	length := 0
+	var last rune = -1
+	for _, r := range password {
+		if r != last {
+			length++
+		}
+		last = r
+	}
+	return float64(length) * math.Log2(float64(pool))
+}
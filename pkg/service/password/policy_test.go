@@ -0,0 +1,118 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func violatedRules(err error) []string {
+	policyErr := &PolicyError{}
+	if !errors.As(err, &policyErr) {
+		return nil
+	}
+	rules := []string{}
+	for _, v := range policyErr.Violations {
+		rules = append(rules, v.Rule)
+	}
+	return rules
+}
+
+func TestPolicy(t *testing.T) {
+	breached, err := NewBreachedList(strings.NewReader(sha1Hex("Password123!") + ":1000\n"))
+	assert.Nil(t, err)
+	params := DefaultPolicyParams
+	params.Breached = breached
+	policy := NewPolicy(params)
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{
+			name:     "Valid password",
+			password: "Password1!",
+			want:     nil,
+		},
+		{
+			name:     "Valid passphrase",
+			password: "correct horse battery staple Tr0ub4dor&3",
+			want:     nil,
+		},
+		{
+			name:     "Password too short",
+			password: "Pass1",
+			want:     []string{RuleMinLength, RuleSpecial, RuleEntropy},
+		},
+		{
+			name:     "Password too long",
+			password: strings.Repeat("Password123!", 11),
+			want:     []string{RuleMaxLength},
+		},
+		{
+			name:     "Password missing uppercase",
+			password: "password123!",
+			want:     []string{RuleUppercase},
+		},
+		{
+			name:     "Password missing lowercase",
+			password: "PASSWORD123!",
+			want:     []string{RuleLowercase},
+		},
+		{
+			name:     "Password missing special character",
+			password: "Password123",
+			want:     []string{RuleSpecial},
+		},
+		{
+			name:     "Repeated characters",
+			password: "Aaaaaaaaaaaa!",
+			want:     []string{RuleEntropy},
+		},
+		{
+			name:     "Breached password",
+			password: "Password123!",
+			want:     []string{RuleBreached},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, violatedRules(policy.Validate(tt.password)))
+		})
+	}
+}
+
+func TestLegacyPolicy(t *testing.T) {
+	// the rules before the policy was configurable
+	policy := NewPolicy(PolicyParams{
+		MinLength:      6,
+		MaxLength:      16,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireSpecial: true,
+	})
+	assert.Nil(t, policy.Validate("Password123!"))
+	assert.Equal(t, []string{RuleMaxLength}, violatedRules(policy.Validate("Password123!Password123!")))
+}
+
+func TestBreachedRangeDir(t *testing.T) {
+	dir := t.TempDir()
+	hash := sha1Hex("Password123!")
+	err := os.WriteFile(filepath.Join(dir, hash[:5]), []byte("0000000000000000000000000000000000A:1\n"+hash[5:]+":1000\n"), 0o600)
+	assert.Nil(t, err)
+
+	checker, err := NewBreachedChecker(dir)
+	assert.Nil(t, err)
+	breached, err := checker.IsBreached("Password123!")
+	assert.Nil(t, err)
+	assert.True(t, breached)
+	breached, err = checker.IsBreached("Password124!")
+	assert.Nil(t, err)
+	assert.False(t, breached)
+}
@@ -0,0 +1,100 @@
+package products
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/cache"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+)
+
+const (
+	// DefaultPageSize is the page size when none is given
+	DefaultPageSize = 20
+	// MaxPageSize is the largest page size
+	MaxPageSize = 100
+)
+
+// ListProductsParams is the parameters for listing products
+type ListProductsParams struct {
+	// Page starts from 1
+	Page     int
+	PageSize int
+	Status   string
+	Category string
+}
+
+// Paging describes a page of a list
+type Paging struct {
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+	Total    int64 `json:"total"`
+}
+
+// CreateProduct creates a product
+func CreateProduct(ctx context.Context, params *domain.CreateProductParams, productRepo domain.ProductRepository) (*domain.Product, *code.CustomError) {
+	product, customErr := productRepo.CreateProduct(ctx, params)
+	if customErr != nil {
+		return nil, customErr
+	}
+	invalidateRecommendations(ctx)
+	return product, nil
+}
+
+// GetProduct gets a product
+func GetProduct(ctx context.Context, id int, productRepo domain.ProductRepository) (*domain.Product, *code.CustomError) {
+	return productRepo.GetProduct(ctx, id)
+}
+
+// UpdateProduct updates a product
+func UpdateProduct(ctx context.Context, id int, params *domain.UpdateProductParams, productRepo domain.ProductRepository) (*domain.Product, *code.CustomError) {
+	product, customErr := productRepo.UpdateProduct(ctx, id, params)
+	if customErr != nil {
+		return nil, customErr
+	}
+	invalidateRecommendations(ctx)
+	return product, nil
+}
+
+// ArchiveProduct archives a product, an archived product is kept but no longer recommended
+func ArchiveProduct(ctx context.Context, id int, productRepo domain.ProductRepository) (*domain.Product, *code.CustomError) {
+	status := domain.ProductStatusArchived
+	return UpdateProduct(ctx, id, &domain.UpdateProductParams{Status: &status}, productRepo)
+}
+
+// ListProducts lists a page of the products, the latest first
+func ListProducts(ctx context.Context, params *ListProductsParams, productRepo domain.ProductRepository) ([]domain.Product, *Paging, *code.CustomError) {
+	paging := &Paging{Page: params.Page, PageSize: params.PageSize}
+	if paging.Page < 1 {
+		paging.Page = 1
+	}
+	if paging.PageSize < 1 {
+		paging.PageSize = DefaultPageSize
+	} else if paging.PageSize > MaxPageSize {
+		paging.PageSize = MaxPageSize
+	}
+
+	products, total, customErr := productRepo.ListProducts(ctx, &domain.ListProductsParams{
+		Status:   params.Status,
+		Category: params.Category,
+		Offset:   (paging.Page - 1) * paging.PageSize,
+		Limit:    paging.PageSize,
+	})
+	if customErr != nil {
+		return nil, nil, customErr
+	}
+	paging.Total = total
+	return products, paging, nil
+}
+
+// invalidateRecommendations drops the cached recommendations of every user from every instance after a product changes,
+// they expire anyway if it fails
+func invalidateRecommendations(ctx context.Context) {
+	if err := cache.GetLayered().DelByPattern(ctx, cache.ProductRecommendationKey("*", "*")); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Warn("invalidateRecommendations, DelByPattern")
+	}
+}
@@ -0,0 +1,43 @@
+package products
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/service/recommend"
+)
+
+// RecordExposure counts the recommendations of a variant shown to a user, a failure only loses the count
+func RecordExposure(ctx context.Context, experiment *recommend.Experiment, variant, uid string, counter domain.ExposureCounter) {
+	if err := counter.AddExposure(ctx, experiment.Name, variant, uid); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"experiment": experiment.Name,
+			"variant":    variant,
+			"error":      err.Error(),
+		}).Warn("RecordExposure, AddExposure")
+	}
+}
+
+// RemoveExposure forgets the variant shown to a user who named another strategy, so that the later interactions
+// of the user are not attributed to the variant
+func RemoveExposure(ctx context.Context, experiment *recommend.Experiment, uid string, counter domain.ExposureCounter) {
+	if err := counter.RemoveExposure(ctx, experiment.Name, uid); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"experiment": experiment.Name,
+			"error":      err.Error(),
+		}).Warn("RemoveExposure, RemoveExposure")
+	}
+}
+
+// GetExperimentReport reports the exposures and the outcomes of each variant of the running experiment
+func GetExperimentReport(ctx context.Context, experiment *recommend.Experiment, counter domain.ExposureCounter, interactionRepo domain.InteractionRepository) (*recommend.ExperimentReport, *code.CustomError) {
+	if experiment == nil {
+		return nil, code.NewCustomError(code.ExperimentNotFound, http.StatusNotFound, fmt.Errorf("no experiment is running"))
+	}
+	return recommend.Report(ctx, experiment, counter, interactionRepo)
+}
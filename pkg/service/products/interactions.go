@@ -0,0 +1,133 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/service/interactions"
+	"github.com/Yu-Qi/GoAuth/pkg/service/recommend"
+)
+
+// RecordInteraction records an interaction of a user with an active product in the background, with the
+// variant last shown to the user if an experiment is running. The limiter is optional. A purchase is not recorded from
+// the client, see PurchaseProduct.
+func RecordInteraction(ctx context.Context, uid string, productID int, interactionType string, experiment *recommend.Experiment, exposures domain.ExposureCounter, recorder domain.InteractionRecorder, limiter domain.InteractionLimiter, productRepo domain.ProductRepository) *code.CustomError {
+	if interactionType == domain.InteractionPurchase {
+		return code.NewCustomError(code.ParamIncorrect, http.StatusBadRequest, fmt.Errorf("purchase is recorded when the product is purchased"))
+	}
+	if limiter != nil {
+		ok, err := limiter.Allow(ctx, uid)
+		if err != nil {
+			// the events are best effort, they are not rejected because the limiter is down
+			logrus.WithFields(logrus.Fields{
+				"uid":   uid,
+				"error": err.Error(),
+			}).Warn("RecordInteraction, limiter.Allow")
+		} else if !ok {
+			return code.NewCustomError(code.TooManyRequests, http.StatusTooManyRequests, fmt.Errorf("too many interactions"))
+		}
+	}
+
+	product, customErr := productRepo.GetProduct(ctx, productID)
+	if customErr != nil {
+		return customErr
+	}
+	if product.Status != domain.ProductStatusActive {
+		return code.NewCustomError(code.ProductNotFound, http.StatusNotFound, fmt.Errorf("product is not active"))
+	}
+	return record(ctx, uid, productID, interactionType, experiment, exposures, recorder)
+}
+
+// PurchaseProduct takes the quantity of an active product from its stock for a user and records the purchase. The
+// purchase stands even if it is not recorded, the interactions are best effort.
+func PurchaseProduct(ctx context.Context, uid string, productID int, quantity int, experiment *recommend.Experiment, exposures domain.ExposureCounter, recorder domain.InteractionRecorder, productRepo domain.ProductRepository) (*domain.Product, *code.CustomError) {
+	product, customErr := productRepo.PurchaseProduct(ctx, productID, quantity)
+	if customErr != nil {
+		return nil, customErr
+	}
+	if customErr := RecordPurchase(ctx, uid, productID, experiment, exposures, recorder); customErr != nil {
+		logrus.WithFields(logrus.Fields{
+			"uid":        uid,
+			"product_id": productID,
+			"error":      customErr.Error.Error(),
+		}).Warn("PurchaseProduct, RecordPurchase")
+	}
+	// a product out of stock is no longer recommended
+	if product.Stock <= 0 {
+		invalidateRecommendations(ctx)
+	}
+	return product, nil
+}
+
+// RecordPurchase records the purchase of a product by a user in the background, it is called once the product is
+// taken from the stock
+func RecordPurchase(ctx context.Context, uid string, productID int, experiment *recommend.Experiment, exposures domain.ExposureCounter, recorder domain.InteractionRecorder) *code.CustomError {
+	return record(ctx, uid, productID, domain.InteractionPurchase, experiment, exposures, recorder)
+}
+
+func record(ctx context.Context, uid string, productID int, interactionType string, experiment *recommend.Experiment, exposures domain.ExposureCounter, recorder domain.InteractionRecorder) *code.CustomError {
+	interaction := domain.Interaction{
+		UID:       uid,
+		ProductID: productID,
+		Type:      interactionType,
+		CreatedAt: time.Now(),
+	}
+	// only a user shown the recommendations of a variant is in the experiment, the bucket of a user who was not
+	// shown them or named another strategy tells nothing about the variant
+	if experiment != nil && exposures != nil {
+		variant, err := exposures.ExposedVariant(ctx, experiment.Name, uid)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"experiment": experiment.Name,
+				"error":      err.Error(),
+			}).Warn("RecordInteraction, ExposedVariant")
+		} else if variant != "" {
+			interaction.Experiment = experiment.Name
+			interaction.Variant = variant
+		}
+	}
+	err := recorder.Record(interaction)
+	if errors.Is(err, interactions.ErrBufferFull) || errors.Is(err, interactions.ErrStopped) {
+		return code.NewCustomError(code.ServiceBusy, http.StatusServiceUnavailable, err)
+	} else if err != nil {
+		return code.NewCustomError(code.InternalUnknownError, http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// GetTrending gets the active products in stock with the most weighted interactions in the last window
+func GetTrending(ctx context.Context, window time.Duration, limit int, counter domain.InteractionCounter, productRepo domain.ProductRepository) ([]domain.Product, *code.CustomError) {
+	// the archived and the out of stock products are counted too, get some more so that enough are left
+	ids, err := counter.Trending(ctx, window, 2*limit)
+	if err != nil {
+		return nil, code.NewCustomError(code.CacheError, http.StatusInternalServerError, err)
+	}
+	products, customErr := productRepo.ListProductsByIDs(ctx, ids)
+	if customErr != nil {
+		return nil, customErr
+	}
+	byID := map[int]domain.Product{}
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	trending := []domain.Product{}
+	for _, id := range ids {
+		product, ok := byID[id]
+		if !ok || product.Status != domain.ProductStatusActive || product.Stock <= 0 {
+			continue
+		}
+		trending = append(trending, product)
+		if len(trending) == limit {
+			break
+		}
+	}
+	return trending, nil
+}
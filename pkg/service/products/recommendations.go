@@ -2,64 +2,96 @@ package products
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
-	"golang.org/x/sync/singleflight"
-
 	"github.com/Yu-Qi/GoAuth/domain"
 	"github.com/Yu-Qi/GoAuth/pkg/cache"
 	"github.com/Yu-Qi/GoAuth/pkg/code"
-	"github.com/Yu-Qi/GoAuth/pkg/db"
-)
-
-var (
-	g singleflight.Group
 )
 
 const (
 	ProductRecommendationCacheTTLSec = 60 * 10
+	// ProductRecommendationStaleSec is how long the recommendations are still served while they are refreshed
+	ProductRecommendationStaleSec = 60
+	// RecommendationLoadTimeout bounds computing the recommendations of a user
+	RecommendationLoadTimeout = 5 * time.Second
+	// RecommendationLockTTL is how long the lock of computing the recommendations of a user is held by an instance
+	// which has crashed, a live instance renews it
+	RecommendationLockTTL = 10 * time.Second
+	// RecommendationLimit is the number of the recommended products in a page by default
+	RecommendationLimit = 10
+	// RecommendationPoolSize is the number of the products recommended and cached for a user, the pages are cut from them
+	RecommendationPoolSize = 100
 )
 
-// GetRecommendations gets product recommendations from cache or db
-func GetRecommendations(ctx context.Context, uid string) (products []domain.Product, customErr *code.CustomError) {
+// Recommendations is the recommended products of a user, the best first
+type Recommendations struct {
+	Products []domain.Product `json:"products"`
+	// Version is the sha256 of the products, it changes whenever the products change
+	Version string `json:"version"`
+}
+
+// recommendationLoader caches the recommendations in the layered cache, which is initialized before the first request.
+// The recommendations of a user are computed by one instance at a time, the others serve the stale ones or wait.
+var recommendationLoader = sync.OnceValue(func() *cache.Loader[*Recommendations] {
+	return cache.NewLoader[*Recommendations](cache.LoaderParams{
+		Store:       cache.GetLayered(),
+		TTL:         ProductRecommendationCacheTTLSec * time.Second,
+		Jitter:      0.1,
+		StaleTTL:    ProductRecommendationStaleSec * time.Second,
+		LoadTimeout: RecommendationLoadTimeout,
+		Locker:      cache.NewLocker(cache.Client, RecommendationLockTTL),
+		LockWait:    RecommendationLoadTimeout,
+	})
+})
+
+// recommendError carries the CustomError of a load through the loader
+type recommendError struct {
+	customErr *code.CustomError
+}
+
+func (e *recommendError) Error() string {
+	return e.customErr.Error.Error()
+}
+
+// GetRecommendations gets the product recommendations of a user by the recommender from cache or db
+func GetRecommendations(ctx context.Context, uid string, accountRepo domain.AccountRepository, recommender domain.Recommender) (*Recommendations, *code.CustomError) {
 	// check user exists and is active
-	if customErr := db.UserExists(ctx, uid); customErr != nil {
+	if customErr := accountRepo.UserExists(ctx, uid); customErr != nil {
 		return nil, customErr
 	}
 
-	// if hit cache
-	if cache.Exists(ctx, cache.CacheKeyProductRecommendation) {
-		v, err := cache.Get(ctx, cache.CacheKeyProductRecommendation)
-		if err != nil {
-			return nil, code.NewCustomError(code.CacheError, http.StatusInternalServerError, err)
-		}
-		err = json.Unmarshal([]byte(v.(string)), &products)
-		if err != nil {
-			return nil, code.NewCustomError(code.JsonUnmarshalErr, http.StatusInternalServerError, err)
-		}
-		return products, nil
-	}
-
-	// if not hit cache, get from db and set cache
-	// use singleflight to avoid cache breakdown.
-	data, err, _ := g.Do("", func() (any, error) {
-		products, customErr = db.GetProductRecommendations(ctx)
+	// the loader keys the loads by the user and the strategy to avoid cache breakdown
+	key := cache.ProductRecommendationKey(uid, recommender.Name())
+	recommendations, err := recommendationLoader().Get(ctx, key, func(ctx context.Context) (*Recommendations, error) {
+		products, customErr := recommender.Recommend(ctx, uid, RecommendationPoolSize)
 		if customErr != nil {
-			return nil, customErr.Error
+			return nil, &recommendError{customErr: customErr}
 		}
-
-		err := cache.SetWithObject(ctx, cache.CacheKeyProductRecommendation, products, ProductRecommendationCacheTTLSec*time.Second)
+		payload, err := json.Marshal(products)
 		if err != nil {
-			customErr = code.NewCustomError(code.CacheError, http.StatusInternalServerError, err)
 			return nil, err
 		}
-		return products, nil
+		sum := sha256.Sum256(payload)
+		return &Recommendations{Products: products, Version: hex.EncodeToString(sum[:])}, nil
 	})
-	if err != nil {
-		return
-	}
 
-	return data.([]domain.Product), nil
+	var recommendErr *recommendError
+	if errors.As(err, &recommendErr) {
+		return nil, recommendErr.customErr
+	} else if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return nil, code.NewCustomError(code.ServiceBusy, http.StatusServiceUnavailable, err)
+	} else if err != nil {
+		return nil, code.NewCustomError(code.InternalUnknownError, http.StatusInternalServerError, err)
+	} else if recommendations == nil {
+		return nil, code.NewCustomError(code.InternalUnknownError, http.StatusInternalServerError, fmt.Errorf("no recommendations"))
+	}
+	return recommendations, nil
 }
@@ -0,0 +1,72 @@
+package recommend
+
+import (
+	"fmt"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+var (
+	recommenders    = map[string]domain.Recommender{}
+	defaultName     string
+	experiment      *Experiment
+	exposureCounter domain.ExposureCounter
+)
+
+// Init initializes the strategies which can be chosen by name, defaultStrategy is used when none is chosen
+func Init(strategies []domain.Recommender, defaultStrategy string) error {
+	byName := map[string]domain.Recommender{}
+	for _, strategy := range strategies {
+		byName[strategy.Name()] = strategy
+	}
+	if _, ok := byName[defaultStrategy]; !ok {
+		return fmt.Errorf("unknown recommendation strategy %q", defaultStrategy)
+	}
+	recommenders = byName
+	defaultName = defaultStrategy
+	return nil
+}
+
+// Get returns the strategy of the name, or the default one if name is empty
+func Get(name string) (domain.Recommender, bool) {
+	if name == "" {
+		name = defaultName
+	}
+	recommender, ok := recommenders[name]
+	return recommender, ok
+}
+
+// InitExperiment initializes the running experiment, nil if none, every variant is the name of a strategy
+func InitExperiment(e *Experiment, counter domain.ExposureCounter) error {
+	if e != nil {
+		for _, variant := range e.Variants {
+			if _, ok := recommenders[variant.Name]; !ok {
+				return fmt.Errorf("variant %s of experiment %s is not a strategy", variant.Name, e.Name)
+			}
+		}
+	}
+	experiment = e
+	exposureCounter = counter
+	return nil
+}
+
+// GetExperiment returns the running experiment, it is nil if none
+func GetExperiment() *Experiment {
+	return experiment
+}
+
+// GetExposureCounter returns the exposure counter of the experiments
+func GetExposureCounter() domain.ExposureCounter {
+	return exposureCounter
+}
+
+// Choose returns the strategy recommending to a user. If none is named, it is the variant of the user in the running
+// experiment, or the default one if none is running. variant is empty unless the user is bucketed.
+func Choose(uid, name string) (recommender domain.Recommender, variant string, ok bool) {
+	if name == "" && experiment != nil {
+		variant = experiment.Assign(uid)
+		name = variant
+	}
+	recommender, ok = Get(name)
+	return recommender, variant, ok
+}
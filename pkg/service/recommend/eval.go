@@ -0,0 +1,126 @@
+package recommend
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/repository/memory"
+)
+
+// EvalParams is the parameters for evaluating the strategies offline
+type EvalParams struct {
+	// Products are the products as they are now, the strategies only recommend the active ones in stock
+	Products []domain.Product
+	// Events is the log of the interactions, the ones before Split train the strategies and the others test them
+	Events []domain.Interaction
+	Split  time.Time
+	// K is the number of products recommended to each user
+	K int
+	// Strategies creates the strategies evaluated, NewStrategies if nil
+	Strategies func(Params) []domain.Recommender
+}
+
+// EvalResult is how well a strategy predicts the products the users interacted with after the split
+type EvalResult struct {
+	Strategy string
+	// Users is the number of users who interacted with any product after the split
+	Users        int
+	PrecisionAtK float64
+	RecallAtK    float64
+}
+
+// Evaluate replays the events before params.Split, and recommends params.K products to every user interacting after it
+// by each strategy. The precision@k and recall@k of a strategy are the averages over the users.
+func Evaluate(ctx context.Context, params EvalParams) ([]EvalResult, error) {
+	if params.K < 1 {
+		return nil, fmt.Errorf("k must be positive")
+	}
+	events := make([]domain.Interaction, len(params.Events))
+	copy(events, params.Events)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt.Before(events[j].CreatedAt) })
+
+	// the products each user interacted with after the split are the relevant ones
+	train := []domain.Interaction{}
+	relevant := map[string]map[int]bool{}
+	for _, event := range events {
+		if event.CreatedAt.Before(params.Split) {
+			train = append(train, event)
+			continue
+		}
+		if relevant[event.UID] == nil {
+			relevant[event.UID] = map[int]bool{}
+		}
+		relevant[event.UID][event.ProductID] = true
+	}
+	uids := make([]string, 0, len(relevant))
+	for uid := range relevant {
+		uids = append(uids, uid)
+	}
+	sort.Strings(uids)
+
+	// the memory repositories keep the interactions in the order they are created, so that the latest come first
+	interactionRepo := memory.NewInteractionRepository()
+	if customErr := interactionRepo.CreateInteractions(ctx, train); customErr != nil {
+		return nil, customErr.Error
+	}
+	productRepo := memory.NewProductRepository(params.Products...)
+	productRepo.Interactions = interactionRepo
+
+	newStrategies := params.Strategies
+	if newStrategies == nil {
+		newStrategies = NewStrategies
+	}
+	strategies := newStrategies(Params{
+		ProductRepo:     productRepo,
+		InteractionRepo: interactionRepo,
+		Now:             func() time.Time { return params.Split },
+	})
+
+	results := make([]EvalResult, 0, len(strategies))
+	for _, strategy := range strategies {
+		result := EvalResult{Strategy: strategy.Name(), Users: len(uids)}
+		for _, uid := range uids {
+			products, customErr := strategy.Recommend(ctx, uid, params.K)
+			if customErr != nil {
+				return nil, fmt.Errorf("%s recommends to %s: %w", strategy.Name(), uid, customErr.Error)
+			}
+			hits := 0
+			for _, product := range products {
+				if relevant[uid][product.ID] {
+					hits++
+				}
+			}
+			result.PrecisionAtK += float64(hits) / float64(params.K)
+			result.RecallAtK += float64(hits) / float64(len(relevant[uid]))
+		}
+		if len(uids) > 0 {
+			result.PrecisionAtK /= float64(len(uids))
+			result.RecallAtK /= float64(len(uids))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// SplitByRatio returns the time which leaves about testRatio of the events at or after it
+func SplitByRatio(events []domain.Interaction, testRatio float64) time.Time {
+	if len(events) == 0 {
+		return time.Time{}
+	}
+	times := make([]time.Time, 0, len(events))
+	for _, event := range events {
+		times = append(times, event.CreatedAt)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	i := int(float64(len(times)) * (1 - testRatio))
+	if i < 0 {
+		i = 0
+	} else if i >= len(times) {
+		// nothing is left for testing, every event trains
+		return times[len(times)-1].Add(time.Nanosecond)
+	}
+	return times[i]
+}
@@ -0,0 +1,66 @@
+package recommend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+func TestEvaluate(t *testing.T) {
+	ctx := context.Background()
+	products := []domain.Product{
+		{ID: 1, Name: "novel", Category: "books", Stock: 1, Status: domain.ProductStatusActive},
+		{ID: 2, Name: "comic", Category: "books", Stock: 1, Status: domain.ProductStatusActive},
+		{ID: 3, Name: "keyboard", Category: "peripherals", Stock: 1, Status: domain.ProductStatusActive},
+		{ID: 4, Name: "mouse", Category: "peripherals", Stock: 1, Status: domain.ProductStatusActive},
+	}
+	split := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	before, after := split.Add(-time.Hour), split.Add(time.Hour)
+	events := []domain.Interaction{
+		// the test events may come first in the log
+		{UID: "reader", ProductID: 2, Type: domain.InteractionPurchase, CreatedAt: after},
+		{UID: "gamer", ProductID: 4, Type: domain.InteractionView, CreatedAt: after},
+		{UID: "gamer", ProductID: 1, Type: domain.InteractionView, CreatedAt: after},
+		{UID: "reader", ProductID: 1, Type: domain.InteractionView, CreatedAt: before},
+		{UID: "gamer", ProductID: 3, Type: domain.InteractionView, CreatedAt: before},
+		{UID: "other", ProductID: 3, Type: domain.InteractionPurchase, CreatedAt: before.Add(-time.Hour)},
+		// the user only interacted before the split, so is not evaluated
+		{UID: "gone", ProductID: 2, Type: domain.InteractionView, CreatedAt: before},
+	}
+
+	results, err := Evaluate(ctx, EvalParams{
+		Products: products,
+		Events:   events,
+		Split:    split,
+		K:        1,
+		Strategies: func(params Params) []domain.Recommender {
+			return []domain.Recommender{NewCategoryAffinity(params), NewRecentlyViewed(params)}
+		},
+	})
+	assert.Nil(t, err)
+	// category affinity recommends the comic to the reader and the keyboard to the gamer,
+	// recently viewed recommends the novel to the reader and the keyboard to the gamer
+	assert.Equal(t, []EvalResult{
+		{Strategy: StrategyCategoryAffinity, Users: 2, PrecisionAtK: 0.5, RecallAtK: 0.5},
+		{Strategy: StrategyRecentlyViewed, Users: 2, PrecisionAtK: 0, RecallAtK: 0},
+	}, results)
+
+	_, err = Evaluate(ctx, EvalParams{Products: products, Events: events, Split: split})
+	assert.NotNil(t, err)
+}
+
+func TestSplitByRatio(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []domain.Interaction{}
+	for i := 9; i >= 0; i-- {
+		events = append(events, domain.Interaction{CreatedAt: start.Add(time.Duration(i) * time.Hour)})
+	}
+	assert.Equal(t, start.Add(8*time.Hour), SplitByRatio(events, 0.2))
+	assert.Equal(t, start, SplitByRatio(events, 1))
+	assert.Equal(t, start.Add(9*time.Hour+time.Nanosecond), SplitByRatio(events, 0))
+	assert.True(t, SplitByRatio(nil, 0.2).IsZero())
+}
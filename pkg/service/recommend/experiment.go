@@ -0,0 +1,200 @@
+package recommend
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+)
+
+// exposureTTL is how long the exposures of a variant are kept after the last one
+const exposureTTL = 90 * 24 * time.Hour
+
+// Experiment buckets the users into the variants, each variant is the name of a strategy
+type Experiment struct {
+	Name     string
+	Variants []domain.ExperimentVariant
+	total    int
+}
+
+// NewExperiment creates an Experiment, the weights are positive
+func NewExperiment(name string, variants []domain.ExperimentVariant) (*Experiment, error) {
+	if name == "" {
+		return nil, fmt.Errorf("experiment has no name")
+	}
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("experiment %s has no variant", name)
+	}
+	e := &Experiment{Name: name, Variants: variants}
+	seen := map[string]bool{}
+	for _, variant := range variants {
+		if variant.Weight <= 0 {
+			return nil, fmt.Errorf("variant %s of experiment %s has weight %d", variant.Name, name, variant.Weight)
+		}
+		if seen[variant.Name] {
+			return nil, fmt.Errorf("variant %s of experiment %s is duplicated", variant.Name, name)
+		}
+		seen[variant.Name] = true
+		e.total += variant.Weight
+	}
+	return e, nil
+}
+
+// ParseVariants parses the variants such as "category_affinity:50,co_occurrence:50"
+func ParseVariants(s string) ([]domain.ExperimentVariant, error) {
+	variants := []domain.ExperimentVariant{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, weight, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("variant %q has no weight", item)
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil {
+			return nil, fmt.Errorf("variant %q has an invalid weight", item)
+		}
+		variants = append(variants, domain.ExperimentVariant{Name: strings.TrimSpace(name), Weight: w})
+	}
+	return variants, nil
+}
+
+// Assign returns the variant of a user, a user always gets the same variant of an experiment,
+// and the users are bucketed independently in another experiment
+func (e *Experiment) Assign(uid string) string {
+	h := fnv.New32a()
+	h.Write([]byte(e.Name + ":" + uid))
+	bucket := int(h.Sum32() % uint32(e.total))
+	for _, variant := range e.Variants {
+		if bucket < variant.Weight {
+			return variant.Name
+		}
+		bucket -= variant.Weight
+	}
+	return e.Variants[len(e.Variants)-1].Name
+}
+
+// ExperimentReport is the exposures and the outcomes of each variant of an experiment
+type ExperimentReport struct {
+	Experiment string          `json:"experiment"`
+	Variants   []VariantReport `json:"variants"`
+}
+
+// VariantReport is the exposures and the outcomes of a variant
+type VariantReport struct {
+	domain.ExperimentVariant
+	// ExposedUsers is the approximate number of the users shown the recommendations of the variant
+	ExposedUsers int64 `json:"exposed_users"`
+	Impressions  int64 `json:"impressions"`
+	// Outcomes are the interactions of the users of the variant by type
+	Outcomes map[string]Outcome `json:"outcomes"`
+	// ConversionRate is the number of the purchasing users over the exposed users
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// Outcome is the number of the interactions of a type, and of the distinct users
+type Outcome struct {
+	Events int64 `json:"events"`
+	Users  int64 `json:"users"`
+}
+
+// Report reports the exposures and the outcomes of each variant of the experiment
+func Report(ctx context.Context, experiment *Experiment, counter domain.ExposureCounter, interactionRepo domain.InteractionRepository) (*ExperimentReport, *code.CustomError) {
+	outcomes, customErr := interactionRepo.CountOutcomes(ctx, experiment.Name)
+	if customErr != nil {
+		return nil, customErr
+	}
+	report := &ExperimentReport{Experiment: experiment.Name, Variants: []VariantReport{}}
+	for _, variant := range experiment.Variants {
+		users, impressions, err := counter.CountExposures(ctx, experiment.Name, variant.Name)
+		if err != nil {
+			return nil, code.NewCustomError(code.CacheError, http.StatusInternalServerError, err)
+		}
+		v := VariantReport{ExperimentVariant: variant, ExposedUsers: users, Impressions: impressions, Outcomes: map[string]Outcome{}}
+		for _, outcome := range outcomes {
+			if outcome.Variant == variant.Name {
+				v.Outcomes[outcome.Type] = Outcome{Events: outcome.Events, Users: outcome.Users}
+			}
+		}
+		if users > 0 {
+			v.ConversionRate = float64(v.Outcomes[domain.InteractionPurchase].Users) / float64(users)
+		}
+		report.Variants = append(report.Variants, v)
+	}
+	return report, nil
+}
+
+// ExposureCounter counts the exposures of each variant in redis, the users in a HyperLogLog and the exposures in a counter
+type ExposureCounter struct {
+	client redis.UniversalClient
+}
+
+var _ domain.ExposureCounter = (*ExposureCounter)(nil)
+
+// NewExposureCounter creates an ExposureCounter
+func NewExposureCounter(client redis.UniversalClient) *ExposureCounter {
+	return &ExposureCounter{client: client}
+}
+
+func exposureKeys(experiment, variant string) (users, impressions string) {
+	prefix := fmt.Sprintf("experiment_exposure:%s:%s", experiment, variant)
+	return prefix + ":users", prefix + ":impressions"
+}
+
+// exposedKey is the key of the variant last shown to a user, the HyperLogLog can not tell if a user is in it
+func exposedKey(experiment, uid string) string {
+	return fmt.Sprintf("experiment_exposed:%s:%s", experiment, uid)
+}
+
+// AddExposure counts an exposure of a user to a variant
+func (c *ExposureCounter) AddExposure(ctx context.Context, experiment, variant, uid string) error {
+	usersKey, impressionsKey := exposureKeys(experiment, variant)
+	pipe := c.client.Pipeline()
+	pipe.Set(ctx, exposedKey(experiment, uid), variant, exposureTTL)
+	pipe.PFAdd(ctx, usersKey, uid)
+	pipe.Incr(ctx, impressionsKey)
+	pipe.Expire(ctx, usersKey, exposureTTL)
+	pipe.Expire(ctx, impressionsKey, exposureTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ExposedVariant returns the variant last shown to the user, it is empty if the user was not shown one
+func (c *ExposureCounter) ExposedVariant(ctx context.Context, experiment, uid string) (string, error) {
+	variant, err := c.client.Get(ctx, exposedKey(experiment, uid)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return variant, err
+}
+
+// RemoveExposure forgets the variant shown to the user, the counts are kept
+func (c *ExposureCounter) RemoveExposure(ctx context.Context, experiment, uid string) error {
+	return c.client.Del(ctx, exposedKey(experiment, uid)).Err()
+}
+
+// CountExposures returns the approximate number of the distinct users, and the number of the exposures
+func (c *ExposureCounter) CountExposures(ctx context.Context, experiment, variant string) (users, impressions int64, err error) {
+	usersKey, impressionsKey := exposureKeys(experiment, variant)
+	pipe := c.client.Pipeline()
+	usersCmd := pipe.PFCount(ctx, usersKey)
+	impressionsCmd := pipe.Get(ctx, impressionsKey)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, 0, err
+	}
+	impressions, err = impressionsCmd.Int64()
+	if err == redis.Nil {
+		err = nil
+	}
+	return usersCmd.Val(), impressions, err
+}
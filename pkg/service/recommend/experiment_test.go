@@ -0,0 +1,192 @@
+package recommend
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/repository/memory"
+)
+
+func TestParseVariants(t *testing.T) {
+	variants, err := ParseVariants(" category_affinity:70, co_occurrence : 30 ,")
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.ExperimentVariant{{Name: "category_affinity", Weight: 70}, {Name: "co_occurrence", Weight: 30}}, variants)
+
+	_, err = ParseVariants("category_affinity")
+	assert.NotNil(t, err)
+	_, err = ParseVariants("category_affinity:half")
+	assert.NotNil(t, err)
+}
+
+func TestNewExperiment(t *testing.T) {
+	_, err := NewExperiment("", []domain.ExperimentVariant{{Name: "a", Weight: 1}})
+	assert.NotNil(t, err)
+	_, err = NewExperiment("exp", nil)
+	assert.NotNil(t, err)
+	_, err = NewExperiment("exp", []domain.ExperimentVariant{{Name: "a", Weight: 0}})
+	assert.NotNil(t, err)
+	_, err = NewExperiment("exp", []domain.ExperimentVariant{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}})
+	assert.NotNil(t, err)
+}
+
+func TestAssign(t *testing.T) {
+	experiment, err := NewExperiment("exp", []domain.ExperimentVariant{{Name: "a", Weight: 80}, {Name: "b", Weight: 20}})
+	assert.Nil(t, err)
+	other, err := NewExperiment("other", []domain.ExperimentVariant{{Name: "a", Weight: 80}, {Name: "b", Weight: 20}})
+	assert.Nil(t, err)
+
+	counts := map[string]int{}
+	differs := false
+	for i := 0; i < 10000; i++ {
+		uid := fmt.Sprintf("user-%d", i)
+		variant := experiment.Assign(uid)
+		// a user always gets the same variant
+		assert.Equal(t, variant, experiment.Assign(uid))
+		counts[variant]++
+		differs = differs || variant != other.Assign(uid)
+	}
+	assert.InDelta(t, 8000, counts["a"], 300)
+	assert.InDelta(t, 2000, counts["b"], 300)
+	// the users are bucketed independently in another experiment
+	assert.True(t, differs)
+}
+
+type fakeExposureCounter struct {
+	users       map[string]map[string]bool
+	impressions map[string]int64
+}
+
+func (c *fakeExposureCounter) AddExposure(ctx context.Context, experiment, variant, uid string) error {
+	key := experiment + ":" + variant
+	if c.users[key] == nil {
+		c.users[key] = map[string]bool{}
+	}
+	c.users[key][uid] = true
+	c.impressions[key]++
+	return nil
+}
+
+func (c *fakeExposureCounter) CountExposures(ctx context.Context, experiment, variant string) (int64, int64, error) {
+	key := experiment + ":" + variant
+	return int64(len(c.users[key])), c.impressions[key], nil
+}
+
+func (c *fakeExposureCounter) ExposedVariant(ctx context.Context, experiment, uid string) (string, error) {
+	return "", nil
+}
+
+func (c *fakeExposureCounter) RemoveExposure(ctx context.Context, experiment, uid string) error {
+	return nil
+}
+
+func TestReport(t *testing.T) {
+	ctx := context.Background()
+	experiment, err := NewExperiment("exp", []domain.ExperimentVariant{{Name: "a", Weight: 1}, {Name: "b", Weight: 1}})
+	assert.Nil(t, err)
+	counter := &fakeExposureCounter{users: map[string]map[string]bool{}, impressions: map[string]int64{}}
+	for _, exposure := range []struct{ variant, uid string }{{"a", "u1"}, {"a", "u1"}, {"a", "u2"}, {"b", "u3"}} {
+		assert.Nil(t, counter.AddExposure(ctx, experiment.Name, exposure.variant, exposure.uid))
+	}
+	interactionRepo := memory.NewInteractionRepository()
+	now := time.Now()
+	assert.Nil(t, interactionRepo.CreateInteractions(ctx, []domain.Interaction{
+		{UID: "u1", ProductID: 1, Type: domain.InteractionView, CreatedAt: now, Experiment: "exp", Variant: "a"},
+		{UID: "u1", ProductID: 2, Type: domain.InteractionView, CreatedAt: now, Experiment: "exp", Variant: "a"},
+		{UID: "u1", ProductID: 1, Type: domain.InteractionPurchase, CreatedAt: now, Experiment: "exp", Variant: "a"},
+		{UID: "u3", ProductID: 1, Type: domain.InteractionView, CreatedAt: now, Experiment: "exp", Variant: "b"},
+		// recorded in another experiment
+		{UID: "u3", ProductID: 1, Type: domain.InteractionPurchase, CreatedAt: now, Experiment: "old", Variant: "b"},
+	}))
+
+	report, customErr := Report(ctx, experiment, counter, interactionRepo)
+	assert.Nil(t, customErr)
+	assert.Equal(t, &ExperimentReport{
+		Experiment: "exp",
+		Variants: []VariantReport{
+			{
+				ExperimentVariant: domain.ExperimentVariant{Name: "a", Weight: 1},
+				ExposedUsers:      2,
+				Impressions:       3,
+				Outcomes: map[string]Outcome{
+					domain.InteractionView:     {Events: 2, Users: 1},
+					domain.InteractionPurchase: {Events: 1, Users: 1},
+				},
+				ConversionRate: 0.5,
+			},
+			{
+				ExperimentVariant: domain.ExperimentVariant{Name: "b", Weight: 1},
+				ExposedUsers:      1,
+				Impressions:       1,
+				Outcomes: map[string]Outcome{
+					domain.InteractionView: {Events: 1, Users: 1},
+				},
+			},
+		},
+	}, report)
+}
+
+func TestChoose(t *testing.T) {
+	productRepo, interactionRepo := testRepositories()
+	assert.Nil(t, Init(NewStrategies(Params{ProductRepo: productRepo, InteractionRepo: interactionRepo}), StrategyPopularity))
+	defer func() { assert.Nil(t, InitExperiment(nil, nil)) }()
+
+	recommender, variant, ok := Choose("user", "")
+	assert.True(t, ok)
+	assert.Equal(t, StrategyPopularity, recommender.Name())
+	assert.Empty(t, variant)
+
+	experiment, err := NewExperiment("exp", []domain.ExperimentVariant{{Name: StrategyCoOccurrence, Weight: 1}})
+	assert.Nil(t, err)
+	assert.Nil(t, InitExperiment(experiment, nil))
+	recommender, variant, ok = Choose("user", "")
+	assert.True(t, ok)
+	assert.Equal(t, StrategyCoOccurrence, recommender.Name())
+	assert.Equal(t, StrategyCoOccurrence, variant)
+
+	// a request naming a strategy is not in the experiment
+	recommender, variant, ok = Choose("user", StrategyRecentlyViewed)
+	assert.True(t, ok)
+	assert.Equal(t, StrategyRecentlyViewed, recommender.Name())
+	assert.Empty(t, variant)
+
+	_, _, ok = Choose("user", "unknown")
+	assert.False(t, ok)
+
+	experiment, err = NewExperiment("exp", []domain.ExperimentVariant{{Name: "unknown", Weight: 1}})
+	assert.Nil(t, err)
+	assert.NotNil(t, InitExperiment(experiment, nil))
+}
+
+func TestExposedVariant(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	counter := NewExposureCounter(client)
+	ctx := context.Background()
+
+	variant, err := counter.ExposedVariant(ctx, "exp", "user")
+	assert.Nil(t, err)
+	assert.Empty(t, variant)
+
+	assert.Nil(t, counter.AddExposure(ctx, "exp", StrategyCoOccurrence, "user"))
+	variant, err = counter.ExposedVariant(ctx, "exp", "user")
+	assert.Nil(t, err)
+	assert.Equal(t, StrategyCoOccurrence, variant)
+
+	// the exposure is forgotten, but still counted
+	assert.Nil(t, counter.RemoveExposure(ctx, "exp", "user"))
+	variant, err = counter.ExposedVariant(ctx, "exp", "user")
+	assert.Nil(t, err)
+	assert.Empty(t, variant)
+	users, impressions, err := counter.CountExposures(ctx, "exp", StrategyCoOccurrence)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), users)
+	assert.Equal(t, int64(1), impressions)
+}
@@ -0,0 +1,147 @@
+package recommend
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+)
+
+// PageParams is the parameters for a page of the recommended products
+type PageParams struct {
+	// Cursor is the next cursor of the previous page, empty for the first page
+	Cursor string
+	Limit  int
+	// Category, MinPrice and MaxPrice filter the products, empty or nil for no filter
+	Category string
+	MinPrice *int64
+	MaxPrice *int64
+}
+
+func (p *PageParams) match(product *domain.Product) bool {
+	return (p.Category == "" || product.Category == p.Category) &&
+		(p.MinPrice == nil || product.Price >= *p.MinPrice) &&
+		(p.MaxPrice == nil || product.Price <= *p.MaxPrice)
+}
+
+// cursor is where a page ends, the product id finds the position again if the recommendations have changed
+type cursor struct {
+	offset    int
+	productID int
+}
+
+func (c cursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", c.offset, c.productID)))
+}
+
+func decodeCursor(s string) (cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, err
+	}
+	offset, productID, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return cursor{}, fmt.Errorf("malformed cursor")
+	}
+	c := cursor{}
+	if c.offset, err = strconv.Atoi(offset); err != nil || c.offset < 0 {
+		return cursor{}, fmt.Errorf("malformed cursor")
+	}
+	if c.productID, err = strconv.Atoi(productID); err != nil {
+		return cursor{}, fmt.Errorf("malformed cursor")
+	}
+	return c, nil
+}
+
+// Page returns a page of the products matching the filters in their order, and the cursor of the next page,
+// which is empty for the last page. The page continues after the last product of the previous page, or at the
+// same offset if the product is no longer recommended.
+func Page(products []domain.Product, params *PageParams) ([]domain.Product, string, *code.CustomError) {
+	matched := []domain.Product{}
+	for i := range products {
+		if params.match(&products[i]) {
+			matched = append(matched, products[i])
+		}
+	}
+
+	start := 0
+	if params.Cursor != "" {
+		c, err := decodeCursor(params.Cursor)
+		if err != nil {
+			return nil, "", code.NewCustomError(code.ParamIncorrect, http.StatusBadRequest, fmt.Errorf("invalid cursor"))
+		}
+		start = min(c.offset, len(matched))
+		for i := range matched {
+			if matched[i].ID == c.productID {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	end := min(start+params.Limit, len(matched))
+	page := matched[start:end]
+	next := ""
+	if end < len(matched) && len(page) > 0 {
+		next = cursor{offset: end, productID: page[len(page)-1].ID}.encode()
+	}
+	return page, next, nil
+}
+
+// productFields are the json fields of domain.Product
+var productFields = map[string]bool{
+	"product_id":  true,
+	"name":        true,
+	"description": true,
+	"price":       true,
+	"category":    true,
+	"stock":       true,
+	"status":      true,
+	"created_at":  true,
+	"updated_at":  true,
+}
+
+// ParseFields parses the comma separated json fields of domain.Product, nil for every field
+func ParseFields(s string) ([]string, *code.CustomError) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	fields := []string{}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if !productFields[field] {
+			return nil, code.NewCustomError(code.ParamIncorrect, http.StatusBadRequest, fmt.Errorf("unknown field %s", field))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// SelectFields returns the products with only the json fields, or the products themselves if fields is nil
+func SelectFields(products []domain.Product, fields []string) (interface{}, error) {
+	if fields == nil {
+		return products, nil
+	}
+	selected := make([]map[string]json.RawMessage, 0, len(products))
+	for _, product := range products {
+		b, err := json.Marshal(product)
+		if err != nil {
+			return nil, err
+		}
+		all := map[string]json.RawMessage{}
+		if err := json.Unmarshal(b, &all); err != nil {
+			return nil, err
+		}
+		item := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			item[field] = all[field]
+		}
+		selected = append(selected, item)
+	}
+	return selected, nil
+}
@@ -0,0 +1,90 @@
+package recommend
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/util"
+)
+
+func pageProducts() []domain.Product {
+	return []domain.Product{
+		{ID: 5, Name: "kettle", Category: "kitchen", Price: 3000},
+		{ID: 4, Name: "mouse", Category: "peripherals", Price: 1500},
+		{ID: 3, Name: "keyboard", Category: "peripherals", Price: 4500},
+		{ID: 2, Name: "comic", Category: "books", Price: 500},
+		{ID: 1, Name: "novel", Category: "books", Price: 1200},
+	}
+}
+
+func TestPage(t *testing.T) {
+	products := pageProducts()
+
+	page, next, customErr := Page(products, &PageParams{Limit: 2})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{5, 4}, ids(page))
+	page, next, customErr = Page(products, &PageParams{Cursor: next, Limit: 2})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{3, 2}, ids(page))
+	page, next, customErr = Page(products, &PageParams{Cursor: next, Limit: 2})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{1}, ids(page))
+	assert.Empty(t, next)
+
+	// the filters apply before the paging
+	page, next, customErr = Page(products, &PageParams{Limit: 1, MinPrice: util.Ptr(int64(1000)), MaxPrice: util.Ptr(int64(3000))})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{5}, ids(page))
+	page, _, customErr = Page(products, &PageParams{Cursor: next, Limit: 10, MinPrice: util.Ptr(int64(1000)), MaxPrice: util.Ptr(int64(3000))})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{4, 1}, ids(page))
+	page, next, customErr = Page(products, &PageParams{Limit: 10, Category: "books"})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{2, 1}, ids(page))
+	assert.Empty(t, next)
+
+	_, _, customErr = Page(products, &PageParams{Cursor: "not a cursor", Limit: 2})
+	assert.NotNil(t, customErr)
+	assert.Equal(t, 400, customErr.HttpStatus)
+}
+
+func TestPageChanged(t *testing.T) {
+	products := pageProducts()
+	_, next, customErr := Page(products, &PageParams{Limit: 2})
+	assert.Nil(t, customErr)
+
+	// the first product is gone, the next page still continues after the mouse
+	page, _, customErr := Page(products[1:], &PageParams{Cursor: next, Limit: 2})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{3, 2}, ids(page))
+
+	// the mouse is gone, the next page starts at the same offset
+	page, _, customErr = Page(append([]domain.Product{products[0]}, products[2:]...), &PageParams{Cursor: next, Limit: 2})
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{2, 1}, ids(page))
+}
+
+func TestSelectFields(t *testing.T) {
+	fields, customErr := ParseFields("product_id, name")
+	assert.Nil(t, customErr)
+	assert.Equal(t, []string{"product_id", "name"}, fields)
+	_, customErr = ParseFields("product_id,secret")
+	assert.NotNil(t, customErr)
+	fields, customErr = ParseFields("")
+	assert.Nil(t, customErr)
+	assert.Nil(t, fields)
+
+	products := pageProducts()[:1]
+	selected, err := SelectFields(products, []string{"product_id", "name"})
+	assert.Nil(t, err)
+	b, err := json.Marshal(selected)
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"name":"kettle","product_id":5}]`, string(b))
+
+	all, err := SelectFields(products, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, products, all)
+}
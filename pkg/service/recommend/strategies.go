@@ -0,0 +1,338 @@
+package recommend
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+)
+
+// names of the strategies
+const (
+	StrategyPopularity       = "popularity"
+	StrategyRecentlyViewed   = "recently_viewed"
+	StrategyCoOccurrence     = "co_occurrence"
+	StrategyCategoryAffinity = "category_affinity"
+)
+
+const (
+	// HistoryLimit is the number of the latest interactions of a user which are considered
+	HistoryLimit = 100
+	// TopCategories is the number of the favorite categories of a user which are recommended from
+	TopCategories = 3
+	// SeedLimit is the number of the latest products of a user which the related products are looked up for
+	SeedLimit = 20
+	// PopularityWindow is how long an interaction counts for the popularity of a product
+	PopularityWindow = 7 * 24 * time.Hour
+	// CoOccurrenceWindow is how long an interaction counts for relating two products
+	CoOccurrenceWindow = 30 * 24 * time.Hour
+)
+
+// Params is the parameters shared by the strategies
+type Params struct {
+	ProductRepo     domain.ProductRepository
+	InteractionRepo domain.InteractionRepository
+	// Now is the time the recommendations are made at, time.Now if nil. The offline evaluation replays the past with it.
+	Now func() time.Time
+}
+
+func (p Params) now() time.Time {
+	if p.Now == nil {
+		return time.Now()
+	}
+	return p.Now()
+}
+
+// NewStrategies creates every strategy, the personalized ones are filled with the popular products, so that a user
+// without interactions gets the popular products
+func NewStrategies(params Params) []domain.Recommender {
+	popularity := NewPopularity(params)
+	return []domain.Recommender{
+		popularity,
+		WithFallback(NewRecentlyViewed(params), popularity),
+		WithFallback(NewCoOccurrence(params), popularity),
+		WithFallback(NewCategoryAffinity(params), popularity),
+	}
+}
+
+// Popularity recommends the products with the most weighted interactions of every user in PopularityWindow, except
+// the ones the user purchased
+type Popularity struct {
+	params Params
+}
+
+// NewPopularity creates a Popularity
+func NewPopularity(params Params) *Popularity {
+	return &Popularity{params: params}
+}
+
+// Name returns StrategyPopularity
+func (s *Popularity) Name() string {
+	return StrategyPopularity
+}
+
+// Recommend recommends the popular products which the user has not purchased
+func (s *Popularity) Recommend(ctx context.Context, uid string, limit int) ([]domain.Product, *code.CustomError) {
+	h, customErr := loadHistory(ctx, s.params, uid)
+	if customErr != nil {
+		return nil, customErr
+	}
+	return s.params.ProductRepo.ListPopularProducts(ctx, &domain.PopularProductsParams{
+		ExcludeIDs: h.purchasedIDs(),
+		Since:      s.params.now().Add(-PopularityWindow),
+		Limit:      limit,
+	})
+}
+
+// RecentlyViewed recommends the products a user interacted with lately but has not purchased
+type RecentlyViewed struct {
+	params Params
+}
+
+// NewRecentlyViewed creates a RecentlyViewed
+func NewRecentlyViewed(params Params) *RecentlyViewed {
+	return &RecentlyViewed{params: params}
+}
+
+// Name returns StrategyRecentlyViewed
+func (s *RecentlyViewed) Name() string {
+	return StrategyRecentlyViewed
+}
+
+// Recommend recommends the products a user interacted with lately, the latest first
+func (s *RecentlyViewed) Recommend(ctx context.Context, uid string, limit int) ([]domain.Product, *code.CustomError) {
+	h, customErr := loadHistory(ctx, s.params, uid)
+	if customErr != nil {
+		return nil, customErr
+	}
+	ids := []int{}
+	for _, id := range h.productIDs {
+		if !h.purchased[id] {
+			ids = append(ids, id)
+		}
+	}
+	return availableProducts(ctx, s.params.ProductRepo, ids, nil, limit)
+}
+
+// CoOccurrence recommends the products which the users of the latest products of a user also interacted with
+type CoOccurrence struct {
+	params Params
+}
+
+// NewCoOccurrence creates a CoOccurrence
+func NewCoOccurrence(params Params) *CoOccurrence {
+	return &CoOccurrence{params: params}
+}
+
+// Name returns StrategyCoOccurrence
+func (s *CoOccurrence) Name() string {
+	return StrategyCoOccurrence
+}
+
+// Recommend recommends the products related to the latest products of a user, by the number of users relating them
+func (s *CoOccurrence) Recommend(ctx context.Context, uid string, limit int) ([]domain.Product, *code.CustomError) {
+	h, customErr := loadHistory(ctx, s.params, uid)
+	if customErr != nil {
+		return nil, customErr
+	}
+	if len(h.productIDs) == 0 {
+		return []domain.Product{}, nil
+	}
+	seeds := h.productIDs
+	if len(seeds) > SeedLimit {
+		seeds = seeds[:SeedLimit]
+	}
+	// some related products may be unavailable, get some more so that enough are left
+	ids, customErr := s.params.InteractionRepo.ListRelatedProductIDs(ctx, &domain.RelatedProductsParams{
+		ProductIDs: seeds,
+		ExcludeUID: uid,
+		Since:      s.params.now().Add(-CoOccurrenceWindow),
+		Limit:      2 * limit,
+	})
+	if customErr != nil {
+		return nil, customErr
+	}
+	return availableProducts(ctx, s.params.ProductRepo, ids, h.purchased, limit)
+}
+
+// CategoryAffinity recommends the popular products of the categories a user interacts with the most
+type CategoryAffinity struct {
+	params Params
+}
+
+// NewCategoryAffinity creates a CategoryAffinity
+func NewCategoryAffinity(params Params) *CategoryAffinity {
+	return &CategoryAffinity{params: params}
+}
+
+// Name returns StrategyCategoryAffinity
+func (s *CategoryAffinity) Name() string {
+	return StrategyCategoryAffinity
+}
+
+// Recommend recommends the popular products of up to TopCategories favorite categories of a user, the purchased
+// products are not recommended again
+func (s *CategoryAffinity) Recommend(ctx context.Context, uid string, limit int) ([]domain.Product, *code.CustomError) {
+	h, customErr := loadHistory(ctx, s.params, uid)
+	if customErr != nil {
+		return nil, customErr
+	}
+	categories, customErr := favoriteCategories(ctx, s.params.ProductRepo, h)
+	if customErr != nil {
+		return nil, customErr
+	}
+	if len(categories) == 0 {
+		return []domain.Product{}, nil
+	}
+	return s.params.ProductRepo.ListPopularProducts(ctx, &domain.PopularProductsParams{
+		Categories: categories,
+		ExcludeIDs: h.purchasedIDs(),
+		Since:      s.params.now().Add(-PopularityWindow),
+		Limit:      limit,
+	})
+}
+
+// fallback fills the recommendations of a strategy with the ones of another
+type fallback struct {
+	primary   domain.Recommender
+	secondary domain.Recommender
+}
+
+// WithFallback returns a strategy named after primary, which fills the rest with the recommendations of secondary
+func WithFallback(primary, secondary domain.Recommender) domain.Recommender {
+	return &fallback{primary: primary, secondary: secondary}
+}
+
+// Name returns the name of the primary strategy
+func (s *fallback) Name() string {
+	return s.primary.Name()
+}
+
+// Recommend recommends the products of the primary strategy, and then the others of the secondary one
+func (s *fallback) Recommend(ctx context.Context, uid string, limit int) ([]domain.Product, *code.CustomError) {
+	products, customErr := s.primary.Recommend(ctx, uid, limit)
+	if customErr != nil {
+		return nil, customErr
+	}
+	if len(products) >= limit {
+		return products, nil
+	}
+	// the secondary strategy may return the same products, get some more so that enough are left
+	more, customErr := s.secondary.Recommend(ctx, uid, 2*limit)
+	if customErr != nil {
+		return nil, customErr
+	}
+	seen := map[int]bool{}
+	for _, product := range products {
+		seen[product.ID] = true
+	}
+	for _, product := range more {
+		if len(products) == limit {
+			break
+		}
+		if !seen[product.ID] {
+			seen[product.ID] = true
+			products = append(products, product)
+		}
+	}
+	return products, nil
+}
+
+// history is the latest interactions of a user
+type history struct {
+	interactions []domain.Interaction
+	// productIDs are the distinct products, the latest first
+	productIDs []int
+	purchased  map[int]bool
+}
+
+func loadHistory(ctx context.Context, params Params, uid string) (*history, *code.CustomError) {
+	interactions, customErr := params.InteractionRepo.ListInteractions(ctx, uid, HistoryLimit)
+	if customErr != nil {
+		return nil, customErr
+	}
+	h := &history{interactions: interactions, purchased: map[int]bool{}}
+	seen := map[int]bool{}
+	for _, interaction := range interactions {
+		if !seen[interaction.ProductID] {
+			seen[interaction.ProductID] = true
+			h.productIDs = append(h.productIDs, interaction.ProductID)
+		}
+		if interaction.Type == domain.InteractionPurchase {
+			h.purchased[interaction.ProductID] = true
+		}
+	}
+	return h, nil
+}
+
+// purchasedIDs returns the purchased products in order
+func (h *history) purchasedIDs() []int {
+	ids := make([]int, 0, len(h.purchased))
+	for id := range h.purchased {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// availableProducts returns up to limit active products in stock of the ids in their order, except the excluded ones
+func availableProducts(ctx context.Context, productRepo domain.ProductRepository, ids []int, exclude map[int]bool, limit int) ([]domain.Product, *code.CustomError) {
+	products, customErr := productRepo.ListProductsByIDs(ctx, ids)
+	if customErr != nil {
+		return nil, customErr
+	}
+	byID := map[int]domain.Product{}
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+	available := []domain.Product{}
+	for _, id := range ids {
+		product, ok := byID[id]
+		if !ok || exclude[id] || product.Status != domain.ProductStatusActive || product.Stock <= 0 {
+			continue
+		}
+		available = append(available, product)
+		if len(available) == limit {
+			break
+		}
+	}
+	return available, nil
+}
+
+// favoriteCategories returns up to TopCategories categories by the weighted interactions
+func favoriteCategories(ctx context.Context, productRepo domain.ProductRepository, h *history) ([]string, *code.CustomError) {
+	if len(h.productIDs) == 0 {
+		return nil, nil
+	}
+	products, customErr := productRepo.ListProductsByIDs(ctx, h.productIDs)
+	if customErr != nil {
+		return nil, customErr
+	}
+	categoryOf := map[int]string{}
+	for _, product := range products {
+		categoryOf[product.ID] = product.Category
+	}
+
+	scores := map[string]int{}
+	for _, interaction := range h.interactions {
+		if category := categoryOf[interaction.ProductID]; category != "" {
+			scores[category] += domain.InteractionWeights[interaction.Type]
+		}
+	}
+	categories := make([]string, 0, len(scores))
+	for category := range scores {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if scores[categories[i]] != scores[categories[j]] {
+			return scores[categories[i]] > scores[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	if len(categories) > TopCategories {
+		categories = categories[:TopCategories]
+	}
+	return categories, nil
+}
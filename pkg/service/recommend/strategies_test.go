@@ -0,0 +1,146 @@
+package recommend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/repository/memory"
+)
+
+func testRepositories() (*memory.ProductRepository, *memory.InteractionRepository) {
+	interactionRepo := memory.NewInteractionRepository()
+	productRepo := memory.NewProductRepository(
+		domain.Product{ID: 1, Name: "novel", Category: "books", Stock: 1, Status: domain.ProductStatusActive},
+		domain.Product{ID: 2, Name: "comic", Category: "books", Stock: 1, Status: domain.ProductStatusActive},
+		domain.Product{ID: 3, Name: "keyboard", Category: "peripherals", Stock: 1, Status: domain.ProductStatusActive},
+		domain.Product{ID: 4, Name: "mouse", Category: "peripherals", Stock: 1, Status: domain.ProductStatusActive},
+		domain.Product{ID: 5, Name: "kettle", Category: "kitchen", Stock: 0, Status: domain.ProductStatusActive},
+	)
+	productRepo.Interactions = interactionRepo
+	return productRepo, interactionRepo
+}
+
+func ids(products []domain.Product) []int {
+	result := []int{}
+	for _, product := range products {
+		result = append(result, product.ID)
+	}
+	return result
+}
+
+func newStrategies(productRepo *memory.ProductRepository, interactionRepo *memory.InteractionRepository) map[string]domain.Recommender {
+	strategies := map[string]domain.Recommender{}
+	for _, strategy := range NewStrategies(Params{ProductRepo: productRepo, InteractionRepo: interactionRepo}) {
+		strategies[strategy.Name()] = strategy
+	}
+	return strategies
+}
+
+func TestCategoryAffinity(t *testing.T) {
+	ctx := context.Background()
+	productRepo, interactionRepo := testRepositories()
+	now := time.Now()
+	assert.Nil(t, interactionRepo.CreateInteractions(ctx, []domain.Interaction{
+		// keyboard is the most popular
+		{UID: "other", ProductID: 3, Type: domain.InteractionPurchase, CreatedAt: now},
+		{UID: "other", ProductID: 4, Type: domain.InteractionView, CreatedAt: now},
+		// the user likes books, and bought the novel already
+		{UID: "reader", ProductID: 1, Type: domain.InteractionPurchase, CreatedAt: now},
+		{UID: "reader", ProductID: 2, Type: domain.InteractionView, CreatedAt: now},
+	}))
+	strategy := newStrategies(productRepo, interactionRepo)[StrategyCategoryAffinity]
+
+	products, customErr := strategy.Recommend(ctx, "reader", 3)
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{2, 3, 4}, ids(products))
+
+	products, customErr = strategy.Recommend(ctx, "reader", 1)
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{2}, ids(products))
+
+	// without the fallback only the favorite categories are recommended
+	products, customErr = NewCategoryAffinity(Params{ProductRepo: productRepo, InteractionRepo: interactionRepo}).Recommend(ctx, "reader", 3)
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{2}, ids(products))
+}
+
+func TestColdStart(t *testing.T) {
+	ctx := context.Background()
+	productRepo, interactionRepo := testRepositories()
+	assert.Nil(t, interactionRepo.CreateInteractions(ctx, []domain.Interaction{
+		{UID: "other", ProductID: 1, Type: domain.InteractionAddToCart, CreatedAt: time.Now()},
+		// too old to count
+		{UID: "other", ProductID: 3, Type: domain.InteractionPurchase, CreatedAt: time.Now().Add(-2 * PopularityWindow)},
+	}))
+
+	// every strategy falls back to the popular products, then the latest, the out of stock kettle is left out
+	for name, strategy := range newStrategies(productRepo, interactionRepo) {
+		assert.Equal(t, name, strategy.Name())
+		products, customErr := strategy.Recommend(ctx, "newcomer", 10)
+		assert.Nil(t, customErr)
+		assert.Equal(t, []int{1, 4, 3, 2}, ids(products), name)
+	}
+}
+
+func TestRecentlyViewed(t *testing.T) {
+	ctx := context.Background()
+	productRepo, interactionRepo := testRepositories()
+	now := time.Now()
+	assert.Nil(t, interactionRepo.CreateInteractions(ctx, []domain.Interaction{
+		{UID: "browser", ProductID: 3, Type: domain.InteractionView, CreatedAt: now},
+		{UID: "browser", ProductID: 1, Type: domain.InteractionView, CreatedAt: now},
+		{UID: "browser", ProductID: 5, Type: domain.InteractionView, CreatedAt: now},
+		{UID: "browser", ProductID: 2, Type: domain.InteractionView, CreatedAt: now},
+		{UID: "browser", ProductID: 2, Type: domain.InteractionPurchase, CreatedAt: now},
+		{UID: "browser", ProductID: 3, Type: domain.InteractionClick, CreatedAt: now},
+	}))
+
+	// the latest first, the purchased comic and the out of stock kettle are left out
+	products, customErr := NewRecentlyViewed(Params{ProductRepo: productRepo, InteractionRepo: interactionRepo}).Recommend(ctx, "browser", 10)
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{3, 1}, ids(products))
+
+	products, customErr = NewRecentlyViewed(Params{ProductRepo: productRepo, InteractionRepo: interactionRepo}).Recommend(ctx, "browser", 1)
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{3}, ids(products))
+}
+
+func TestCoOccurrence(t *testing.T) {
+	ctx := context.Background()
+	productRepo, interactionRepo := testRepositories()
+	now := time.Now()
+	assert.Nil(t, interactionRepo.CreateInteractions(ctx, []domain.Interaction{
+		// the buyers of the keyboard also buy the mouse, and one of them the novel
+		{UID: "a", ProductID: 3, Type: domain.InteractionPurchase, CreatedAt: now},
+		{UID: "a", ProductID: 4, Type: domain.InteractionPurchase, CreatedAt: now},
+		{UID: "a", ProductID: 1, Type: domain.InteractionView, CreatedAt: now},
+		{UID: "b", ProductID: 3, Type: domain.InteractionView, CreatedAt: now},
+		{UID: "b", ProductID: 4, Type: domain.InteractionView, CreatedAt: now},
+		// too old to relate
+		{UID: "c", ProductID: 3, Type: domain.InteractionView, CreatedAt: now.Add(-2 * CoOccurrenceWindow)},
+		{UID: "c", ProductID: 2, Type: domain.InteractionView, CreatedAt: now.Add(-2 * CoOccurrenceWindow)},
+		{UID: "gamer", ProductID: 3, Type: domain.InteractionView, CreatedAt: now},
+	}))
+	params := Params{ProductRepo: productRepo, InteractionRepo: interactionRepo}
+
+	products, customErr := NewCoOccurrence(params).Recommend(ctx, "gamer", 10)
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{4, 1}, ids(products))
+
+	// the purchased products are left out
+	assert.Nil(t, interactionRepo.CreateInteractions(ctx, []domain.Interaction{
+		{UID: "gamer", ProductID: 4, Type: domain.InteractionPurchase, CreatedAt: now},
+	}))
+	products, customErr = NewCoOccurrence(params).Recommend(ctx, "gamer", 10)
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{1}, ids(products))
+
+	// filled with the popular ones
+	products, customErr = WithFallback(NewCoOccurrence(params), NewPopularity(params)).Recommend(ctx, "gamer", 3)
+	assert.Nil(t, customErr)
+	assert.Equal(t, []int{1, 3, 2}, ids(products))
+}
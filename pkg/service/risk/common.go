@@ -0,0 +1,15 @@
+package risk
+
+var (
+	service *LoginRiskService
+)
+
+// GetService returns the login risk service, nil if login risk is not enabled
+func GetService() *LoginRiskService {
+	return service
+}
+
+// InitService initializes the login risk service
+func InitService(s *LoginRiskService) {
+	service = s
+}
@@ -0,0 +1,141 @@
+package risk
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/code"
+	"github.com/Yu-Qi/GoAuth/pkg/service/accounts"
+)
+
+const (
+	// historySize is the number of previous sessions a login is compared with
+	historySize = 20
+	// assessTimeout bounds an assessment which runs after the login request is done
+	assessTimeout = 30 * time.Second
+)
+
+// LoginRiskParams is the parameters for creating a LoginRiskService
+type LoginRiskParams struct {
+	Rules []domain.LoginRiskRule
+	// Threshold is the total score from which the user is notified
+	Threshold       int
+	VerificationSvc domain.VerificationCodeService
+	SendEmailSvc    domain.SendEmailService
+	AccountRepo     domain.AccountRepository
+}
+
+// LoginRiskService scores logins with pluggable rules and notifies the user of suspicious ones
+type LoginRiskService struct {
+	rules           []domain.LoginRiskRule
+	threshold       int
+	verificationSvc domain.VerificationCodeService
+	sendEmailSvc    domain.SendEmailService
+	accountRepo     domain.AccountRepository
+}
+
+// NewLoginRiskService creates a LoginRiskService
+func NewLoginRiskService(params *LoginRiskParams) *LoginRiskService {
+	return &LoginRiskService{
+		rules:           params.Rules,
+		threshold:       params.Threshold,
+		verificationSvc: params.VerificationSvc,
+		sendEmailSvc:    params.SendEmailSvc,
+		accountRepo:     params.AccountRepo,
+	}
+}
+
+// Assessment is the result of assessing a login
+type Assessment struct {
+	Score   int
+	Reasons []string
+}
+
+// Score scores a login against the given previous sessions
+func (s *LoginRiskService) Score(ctx context.Context, attempt *domain.LoginAttempt, history []domain.Session) *Assessment {
+	assessment := &Assessment{}
+	for _, rule := range s.rules {
+		score, reason := rule.Evaluate(ctx, attempt, history)
+		if score <= 0 {
+			continue
+		}
+		assessment.Score += score
+		assessment.Reasons = append(assessment.Reasons, reason)
+		logrus.WithFields(logrus.Fields{
+			"uid":   attempt.UID,
+			"jti":   attempt.JTI,
+			"rule":  rule.Name(),
+			"score": score,
+		}).Debug("Login risk rule matched")
+	}
+	return assessment
+}
+
+// Assess scores a login against the previous sessions of the account, and emails the user if it is suspicious
+func (s *LoginRiskService) Assess(ctx context.Context, attempt *domain.LoginAttempt) (*Assessment, *code.CustomError) {
+	sessions, customErr := s.accountRepo.ListRecentSessions(ctx, attempt.UID, historySize+1)
+	if customErr != nil {
+		return nil, customErr
+	}
+	history := make([]domain.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.JTI != attempt.JTI {
+			history = append(history, session)
+		}
+	}
+
+	assessment := s.Score(ctx, attempt, history)
+	if assessment.Score < s.threshold {
+		return assessment, nil
+	}
+
+	if err := s.notify(ctx, attempt, assessment); err != nil {
+		return assessment, code.NewCustomError(code.SendEmailError, http.StatusInternalServerError, err)
+	}
+	return assessment, nil
+}
+
+// AssessAsync assesses a login in the background so that the login response is not delayed
+func (s *LoginRiskService) AssessAsync(attempt *domain.LoginAttempt) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), assessTimeout)
+		defer cancel()
+		if _, customErr := s.Assess(ctx, attempt); customErr != nil {
+			logrus.WithFields(logrus.Fields{
+				"uid":   attempt.UID,
+				"jti":   attempt.JTI,
+				"error": customErr.Error.Error(),
+			}).Error("AssessAsync, Assess")
+		}
+	}()
+}
+
+func (s *LoginRiskService) notify(ctx context.Context, attempt *domain.LoginAttempt, assessment *Assessment) error {
+	notMeCode, err := accounts.GenerateNotMeCode(attempt.JTI, s.verificationSvc)
+	if err != nil {
+		return err
+	}
+	// the email is in the language of the account, the default one if it can not be found
+	locale := ""
+	if account, customErr := s.accountRepo.GetAccount(ctx, attempt.UID); customErr == nil {
+		locale = account.Locale
+	}
+
+	return s.sendEmailSvc.SendEmail(ctx, &domain.EmailMessage{
+		ID:       domain.EmailTemplateNewSignIn + "/" + attempt.JTI,
+		To:       attempt.Email,
+		Template: domain.EmailTemplateNewSignIn,
+		Locale:   locale,
+		Data: map[string]interface{}{
+			"Code":    notMeCode,
+			"Reasons": assessment.Reasons,
+			"Time":    attempt.At.UTC().Format(time.RFC1123),
+			"IP":      attempt.IP,
+			"Device":  attempt.UserAgent,
+		},
+	})
+}
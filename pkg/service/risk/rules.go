@@ -0,0 +1,144 @@
+package risk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+const (
+	earthRadiusKm = 6371.0
+)
+
+// DeviceFingerprint returns a stable fingerprint of the device which sent the user agent
+func DeviceFingerprint(userAgent string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(userAgent), " "))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewDeviceRule scores a login from a device fingerprint never seen on the account
+type NewDeviceRule struct {
+	Score int
+}
+
+// Name .
+func (r *NewDeviceRule) Name() string {
+	return "new_device"
+}
+
+// Evaluate .
+func (r *NewDeviceRule) Evaluate(ctx context.Context, attempt *domain.LoginAttempt, history []domain.Session) (int, string) {
+	if len(history) == 0 {
+		return 0, ""
+	}
+	fingerprint := DeviceFingerprint(attempt.UserAgent)
+	for _, session := range history {
+		if DeviceFingerprint(session.UserAgent) == fingerprint {
+			return 0, ""
+		}
+	}
+	return r.Score, "sign-in from a new device"
+}
+
+// NewIPRangeRule scores a login from an ip range never seen on the account.
+// The range is the /24 network for IPv4 and the /48 network for IPv6.
+type NewIPRangeRule struct {
+	Score int
+}
+
+// Name .
+func (r *NewIPRangeRule) Name() string {
+	return "new_ip_range"
+}
+
+// Evaluate .
+func (r *NewIPRangeRule) Evaluate(ctx context.Context, attempt *domain.LoginAttempt, history []domain.Session) (int, string) {
+	if len(history) == 0 {
+		return 0, ""
+	}
+	ipRange := IPRange(attempt.IP)
+	if ipRange == "" {
+		return 0, ""
+	}
+	for _, session := range history {
+		if IPRange(session.IP) == ipRange {
+			return 0, ""
+		}
+	}
+	return r.Score, fmt.Sprintf("sign-in from a new network (%s)", attempt.IP)
+}
+
+// IPRange returns the /24 network of an IPv4 or the /48 network of an IPv6, or empty if ip is invalid
+func IPRange(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+// ImpossibleTravelRule scores a login whose distance from the previous login
+// could not be travelled at MaxSpeedKmh in the elapsed time
+type ImpossibleTravelRule struct {
+	Score       int
+	MaxSpeedKmh float64
+	Locator     domain.GeoIPLocator
+}
+
+// Name .
+func (r *ImpossibleTravelRule) Name() string {
+	return "impossible_travel"
+}
+
+// Evaluate .
+func (r *ImpossibleTravelRule) Evaluate(ctx context.Context, attempt *domain.LoginAttempt, history []domain.Session) (int, string) {
+	current, ok := r.locate(attempt.IP)
+	if !ok {
+		return 0, ""
+	}
+	// history is the latest first, compare with the latest locatable login
+	for _, session := range history {
+		previous, ok := r.locate(session.IP)
+		if !ok {
+			continue
+		}
+		distance := HaversineKm(previous, current)
+		hours := attempt.At.Sub(session.CreatedAt).Hours()
+		if hours <= 0 {
+			hours = 1.0 / 60
+		}
+		if distance/hours > r.MaxSpeedKmh {
+			return r.Score, fmt.Sprintf("sign-in %.0f km away from the previous sign-in %.1f hours ago", distance, hours)
+		}
+		return 0, ""
+	}
+	return 0, ""
+}
+
+func (r *ImpossibleTravelRule) locate(ip string) (*domain.GeoLocation, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || r.Locator == nil {
+		return nil, false
+	}
+	return r.Locator.Locate(parsed)
+}
+
+// HaversineKm returns the great-circle distance between two locations in kilometers
+func HaversineKm(a, b *domain.GeoLocation) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(b.Latitude - a.Latitude)
+	dLon := toRad(b.Longitude - a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Latitude))*math.Cos(toRad(b.Latitude))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,115 @@
+package risk
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+	"github.com/Yu-Qi/GoAuth/pkg/geoip"
+)
+
+const testGeoIPCSV = `network,geoname_id,latitude,longitude,accuracy_radius
+1.2.3.0/24,1,25.0330,121.5654,10
+5.6.0.0/16,2,40.7128,-74.0060,10
+2001:db8::/32,3,51.5074,-0.1278,10
+`
+
+func TestRules(t *testing.T) {
+	locator, err := geoip.NewCSVLocator(strings.NewReader(testGeoIPCSV))
+	assert.Nil(t, err)
+
+	now := time.Now()
+	history := []domain.Session{
+		{UserAgent: "Mozilla/5.0 (iPhone)", IP: "1.2.3.4", CreatedAt: now.Add(-time.Hour)},
+	}
+	tests := []struct {
+		name    string
+		rule    domain.LoginRiskRule
+		attempt *domain.LoginAttempt
+		history []domain.Session
+		want    int
+	}{
+		{
+			name:    "Known device",
+			rule:    &NewDeviceRule{Score: 40},
+			attempt: &domain.LoginAttempt{UserAgent: "mozilla/5.0  (iphone)", At: now},
+			history: history,
+			want:    0,
+		},
+		{
+			name:    "New device",
+			rule:    &NewDeviceRule{Score: 40},
+			attempt: &domain.LoginAttempt{UserAgent: "curl/8.0", At: now},
+			history: history,
+			want:    40,
+		},
+		{
+			name:    "First login is not a new device",
+			rule:    &NewDeviceRule{Score: 40},
+			attempt: &domain.LoginAttempt{UserAgent: "curl/8.0", At: now},
+			want:    0,
+		},
+		{
+			name:    "Same ip range",
+			rule:    &NewIPRangeRule{Score: 30},
+			attempt: &domain.LoginAttempt{IP: "1.2.3.200", At: now},
+			history: history,
+			want:    0,
+		},
+		{
+			name:    "New ip range",
+			rule:    &NewIPRangeRule{Score: 30},
+			attempt: &domain.LoginAttempt{IP: "1.2.4.1", At: now},
+			history: history,
+			want:    30,
+		},
+		{
+			name:    "Impossible travel",
+			rule:    &ImpossibleTravelRule{Score: 100, MaxSpeedKmh: 1000, Locator: locator},
+			attempt: &domain.LoginAttempt{IP: "5.6.7.8", At: now},
+			history: history,
+			want:    100,
+		},
+		{
+			name:    "Possible travel",
+			rule:    &ImpossibleTravelRule{Score: 100, MaxSpeedKmh: 1000, Locator: locator},
+			attempt: &domain.LoginAttempt{IP: "5.6.7.8", At: now.Add(24 * time.Hour)},
+			history: history,
+			want:    0,
+		},
+		{
+			name:    "Unknown location",
+			rule:    &ImpossibleTravelRule{Score: 100, MaxSpeedKmh: 1000, Locator: locator},
+			attempt: &domain.LoginAttempt{IP: "9.9.9.9", At: now},
+			history: history,
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := tt.rule.Evaluate(context.Background(), tt.attempt, tt.history)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	svc := NewLoginRiskService(&LoginRiskParams{
+		Rules: []domain.LoginRiskRule{
+			&NewDeviceRule{Score: 40},
+			&NewIPRangeRule{Score: 30},
+		},
+		Threshold: 50,
+	})
+	history := []domain.Session{
+		{UserAgent: "Mozilla/5.0 (iPhone)", IP: "1.2.3.4"},
+	}
+	assessment := svc.Score(context.Background(), &domain.LoginAttempt{UserAgent: "curl/8.0", IP: "2001:db8::1"}, history)
+	assert.Equal(t, 70, assessment.Score)
+	assert.Equal(t, 2, len(assessment.Reasons))
+}
@@ -0,0 +1,17 @@
+package sms
+
+import "github.com/Yu-Qi/GoAuth/domain"
+
+var (
+	service domain.SendSMSService
+)
+
+// GetService returns the sms service
+func GetService() domain.SendSMSService {
+	return service
+}
+
+// InitService initializes the sms service
+func InitService(s domain.SendSMSService) {
+	service = s
+}
@@ -0,0 +1,76 @@
+package sms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+// maxErrorBodyLength bounds the response body kept in the error of a failed request
+const maxErrorBodyLength = 512
+
+// HTTPGatewayConfig is the config of HTTPSMSService
+type HTTPGatewayConfig struct {
+	// URL receives a POST of {"from": From, "to": "+886912345678", "text": "..."}
+	URL string
+	// APIKey is sent as a bearer token, it is optional
+	APIKey string
+	// From is the sender id or number, it is optional
+	From string
+	// Client is optional, it should have a timeout
+	Client *http.Client
+}
+
+// HTTPSMSService sends text messages through an SMS gateway with a JSON over HTTP API
+type HTTPSMSService struct {
+	cfg HTTPGatewayConfig
+}
+
+// NewHTTPSMSService creates a HTTPSMSService
+func NewHTTPSMSService(cfg HTTPGatewayConfig) *HTTPSMSService {
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &HTTPSMSService{cfg: cfg}
+}
+
+type gatewayRequest struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+	Text string `json:"text"`
+}
+
+// SendSMS sends a text message, it fails unless the gateway responds 2xx
+func (s *HTTPSMSService) SendSMS(msg *domain.SMSMessage) error {
+	body, err := json.Marshal(gatewayRequest{
+		From: s.cfg.From,
+		To:   msg.To,
+		Text: msg.Text,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+	}
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyLength))
+		return fmt.Errorf("sms gateway responded %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package sms
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+func TestHTTPSMSService(t *testing.T) {
+	status := http.StatusOK
+	var got gatewayRequest
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	svc := NewHTTPSMSService(HTTPGatewayConfig{URL: server.URL, APIKey: "key", From: "GoAuth"})
+	assert.Nil(t, svc.SendSMS(&domain.SMSMessage{To: "+886912345678", Text: "123456"}))
+	assert.Equal(t, "Bearer key", authorization)
+	assert.Equal(t, gatewayRequest{From: "GoAuth", To: "+886912345678", Text: "123456"}, got)
+
+	status = http.StatusBadGateway
+	assert.NotNil(t, svc.SendSMS(&domain.SMSMessage{To: "+886912345678", Text: "123456"}))
+}
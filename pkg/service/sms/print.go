@@ -0,0 +1,20 @@
+package sms
+
+import (
+	"fmt"
+
+	"github.com/Yu-Qi/GoAuth/domain"
+)
+
+// PrintSMSService is a service that prints the text message to the console
+type PrintSMSService struct{}
+
+func NewPrintSMSService() *PrintSMSService {
+	return &PrintSMSService{}
+}
+
+// SendSMS prints the text message to the console
+func (p PrintSMSService) SendSMS(msg *domain.SMSMessage) error {
+	fmt.Printf("SMS: %s\nText: %s\n", msg.To, msg.Text)
+	return nil
+}
@@ -0,0 +1,43 @@
+package smtpsink
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// Handler returns the HTTP API of the sink:
+//
+//	GET    /messages       lists the received messages
+//	GET    /messages/{id}  gets a message
+//	DELETE /messages       deletes all messages
+func (s *Sink) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /messages", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Messages())
+	})
+	mux.HandleFunc("GET /messages/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "invalid id"})
+			return
+		}
+		m, ok := s.Message(id)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "message not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, m)
+	})
+	mux.HandleFunc("DELETE /messages", func(w http.ResponseWriter, r *http.Request) {
+		s.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
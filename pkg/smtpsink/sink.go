@@ -0,0 +1,299 @@
+package smtpsink
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	hostname = "goauth-smtp-sink"
+	// maxMessages keeps the sink from growing without bound in a long running dev server
+	maxMessages = 1000
+)
+
+// Message is an email received by the sink
+type Message struct {
+	ID         int       `json:"id"`
+	Username   string    `json:"username,omitempty"`
+	From       string    `json:"from"`
+	To         []string  `json:"to"`
+	Subject    string    `json:"subject"`
+	Data       string    `json:"data"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// Sink is an in-process SMTP server which accepts every email, keeps it in memory and never delivers it.
+// Any credentials are accepted. It is meant for development and tests only.
+type Sink struct {
+	mu          sync.Mutex
+	messages    []Message
+	nextID      int
+	connections int
+
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// New creates a Sink
+func New() *Sink {
+	return &Sink{nextID: 1, conns: map[net.Conn]struct{}{}}
+}
+
+// Start listens on addr and serves in the background, addr may use port 0. It returns the listening address.
+func (s *Sink) Start(addr string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s.listener = listener
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.connections++
+			s.conns[conn] = struct{}{}
+			s.mu.Unlock()
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+			}()
+		}
+	}()
+	return listener.Addr(), nil
+}
+
+// Close stops listening, closes the open connections and waits for them to end
+func (s *Sink) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	err := s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// Messages returns the received messages, the oldest first
+func (s *Sink) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message{}, s.messages...)
+}
+
+// Message returns a received message by id
+func (s *Sink) Message(id int) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.messages {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Message{}, false
+}
+
+// Reset deletes the received messages
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+}
+
+// Connections returns the number of connections accepted so far
+func (s *Sink) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections
+}
+
+func (s *Sink) store(m Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m.ID = s.nextID
+	s.nextID++
+	m.ReceivedAt = time.Now()
+	s.messages = append(s.messages, m)
+	if len(s.messages) > maxMessages {
+		s.messages = s.messages[len(s.messages)-maxMessages:]
+	}
+}
+
+// session is the state of one SMTP connection
+type session struct {
+	username string
+	from     string
+	to       []string
+}
+
+func (s *Sink) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	reply := func(format string, args ...interface{}) bool {
+		return tp.PrintfLine(format, args...) == nil
+	}
+
+	if !reply("220 %s ESMTP ready", hostname) {
+		return
+	}
+	sess := &session{}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			reply("250 %s", hostname)
+		case "EHLO":
+			reply("250-%s", hostname)
+			reply("250-AUTH PLAIN LOGIN")
+			reply("250 8BITMIME")
+		case "AUTH":
+			username, err := readAuth(tp, arg)
+			if err != nil {
+				reply("501 %s", err.Error())
+				continue
+			}
+			sess.username = username
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			sess.from = parsePath(arg)
+			sess.to = nil
+			reply("250 OK")
+		case "RCPT":
+			sess.to = append(sess.to, parsePath(arg))
+			reply("250 OK")
+		case "DATA":
+			if sess.from == "" || len(sess.to) == 0 {
+				reply("503 need MAIL and RCPT first")
+				continue
+			}
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			s.store(Message{
+				Username: sess.username,
+				From:     sess.from,
+				To:       sess.to,
+				Subject:  subjectOf(data),
+				Data:     string(data),
+			})
+			sess.from, sess.to = "", nil
+			reply("250 OK queued")
+		case "RSET":
+			sess.from, sess.to = "", nil
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// readAuth runs the AUTH PLAIN or AUTH LOGIN exchange and returns the username
+func readAuth(tp *textproto.Conn, arg string) (string, error) {
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	readResponse := func(challenge string) (string, error) {
+		if err := tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(challenge))); err != nil {
+			return "", err
+		}
+		line, err := tp.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		decoded, err := base64.StdEncoding.DecodeString(line)
+		return string(decoded), err
+	}
+
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		var response string
+		if initial != "" {
+			decoded, err := base64.StdEncoding.DecodeString(initial)
+			if err != nil {
+				return "", err
+			}
+			response = string(decoded)
+		} else {
+			var err error
+			if response, err = readResponse(""); err != nil {
+				return "", err
+			}
+		}
+		// authzid \0 authcid \0 password
+		parts := strings.Split(response, "\x00")
+		if len(parts) != 3 {
+			return "", fmt.Errorf("malformed PLAIN response")
+		}
+		return parts[1], nil
+	case "LOGIN":
+		username, err := readResponse("Username:")
+		if err != nil {
+			return "", err
+		}
+		if _, err := readResponse("Password:"); err != nil {
+			return "", err
+		}
+		return username, nil
+	}
+	return "", fmt.Errorf("unsupported mechanism %s", mechanism)
+}
+
+// parsePath parses "FROM:<user@host> SIZE=1" or "TO:<user@host>"
+func parsePath(arg string) string {
+	_, path, _ := strings.Cut(arg, ":")
+	path = strings.TrimSpace(path)
+	if i := strings.IndexByte(path, ' '); i >= 0 {
+		path = path[:i]
+	}
+	return strings.Trim(path, "<>")
+}
+
+func subjectOf(data []byte) string {
+	msg, err := mail.ReadMessage(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return ""
+	}
+	subject := msg.Header.Get("Subject")
+	decoded, err := new(mime.WordDecoder).DecodeHeader(subject)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err.Error(),
+		}).Debug("smtpsink, DecodeHeader")
+		return subject
+	}
+	return decoded
+}
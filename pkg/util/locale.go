@@ -0,0 +1,13 @@
+package util
+
+import "golang.org/x/text/language"
+
+// ParseLocale returns the canonical BCP 47 tag of a locale such as "zh-tw", or of the most preferred
+// language of an Accept-Language header. It returns empty for an invalid or empty locale.
+func ParseLocale(s string) string {
+	tags, _, err := language.ParseAcceptLanguage(s)
+	if err != nil || len(tags) == 0 || tags[0] == language.Und {
+		return ""
+	}
+	return tags[0].String()
+}
@@ -4,3 +4,12 @@ package util
 func Ptr[T any](t T) *T {
 	return &t
 }
+
+// Deref returns the value of a pointer, or the zero value if it is nil.
+func Deref[T any](t *T) T {
+	if t == nil {
+		var zero T
+		return zero
+	}
+	return *t
+}
@@ -32,6 +32,30 @@ func PostForTest(url string, body map[string]interface{}, handleFuncs ...gin.Han
 	return
 }
 
+// PostWithHeaderForTest sends a POST request to the given URL with the given header and body. Put the route handler functions to last handleFuncs
+func PostWithHeaderForTest(url string, headers http.Header, body map[string]interface{}, handleFuncs ...gin.HandlerFunc) (httpStatus int, responseBody []byte, err error) {
+	jsonStr, err := json.Marshal(body)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonStr))
+	if err != nil {
+		return
+	}
+	req.Header = headers
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	r := gin.Default()
+	r.POST(url, handleFuncs...)
+	r.ServeHTTP(w, req)
+
+	httpStatus = w.Code
+	responseBody = w.Body.Bytes()
+	return
+}
+
 // GetWithHeaderForTest sends a GET request to the given URL with the given header. Put the route handler functions to last handleFuncs
 func GetWithHeaderForTest(url string, headers http.Header, handleFuncs ...gin.HandlerFunc) (httpStatus int, responseBody []byte, err error) {
 	req, err := http.NewRequest("GET", url, nil)
@@ -50,3 +74,62 @@ func GetWithHeaderForTest(url string, headers http.Header, handleFuncs ...gin.Ha
 	responseBody = w.Body.Bytes()
 	return
 }
+
+// RequestWithHeaderForTest sends a request with the given method and header to the given URL, which is served by the given route. Put the route handler functions to last handleFuncs
+func RequestWithHeaderForTest(method, route, url string, headers http.Header, handleFuncs ...gin.HandlerFunc) (httpStatus int, responseBody []byte, err error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return
+	}
+	req.Header = headers
+
+	w := httptest.NewRecorder()
+	r := gin.Default()
+	r.Handle(method, route, handleFuncs...)
+
+	r.ServeHTTP(w, req)
+
+	httpStatus = w.Code
+	responseBody = w.Body.Bytes()
+	return
+}
+
+// RequestWithBodyForTest sends a request with the given method, header and body to the given URL, which is served by the given route. Put the route handler functions to last handleFuncs
+func RequestWithBodyForTest(method, route, url string, headers http.Header, body map[string]interface{}, handleFuncs ...gin.HandlerFunc) (httpStatus int, responseBody []byte, err error) {
+	jsonStr, err := json.Marshal(body)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(jsonStr))
+	if err != nil {
+		return
+	}
+	req.Header = headers
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	r := gin.Default()
+	r.Handle(method, route, handleFuncs...)
+	r.ServeHTTP(w, req)
+
+	httpStatus = w.Code
+	responseBody = w.Body.Bytes()
+	return
+}
+
+// RecordWithHeaderForTest is RequestWithHeaderForTest returning the whole response, such as the response headers
+func RecordWithHeaderForTest(method, route, url string, headers http.Header, handleFuncs ...gin.HandlerFunc) (*httptest.ResponseRecorder, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = headers
+
+	w := httptest.NewRecorder()
+	r := gin.Default()
+	r.Handle(method, route, handleFuncs...)
+
+	r.ServeHTTP(w, req)
+	return w, nil
+}